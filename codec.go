@@ -0,0 +1,126 @@
+package transformer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+// Format
+// name of a data format handled by a Codec, e.g. "json"
+type Format string
+
+// Formats provided by the packages of this module
+const (
+	FormatJson Format = "json"
+	FormatXml  Format = "xml"
+	FormatYaml Format = "yaml"
+	FormatToml Format = "toml"
+)
+
+// Codec
+// converts between a data format and the node tree.
+// Decode reads a whole document from r, Encode writes n to w
+// and Detect reports whether data looks like the codec's format.
+type Codec interface {
+	Decode(r io.Reader) (*node.Node, error)
+	Encode(w io.Writer, n *node.Node) error
+	Detect(data []byte) bool
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[Format]Codec)
+	aliases  = make(map[Format]Format)
+)
+
+// Register
+// makes a codec available under the given format name.
+// Packages usually call it from init, so importing a codec package
+// (even with a blank import) is enough to use its format.
+// It panics if the codec is nil or the format is already registered.
+func Register(format Format, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	if codec == nil {
+		panic("transformer: Register codec is nil")
+	}
+	format = normalizeFormat(format)
+	if format == "" {
+		panic("transformer: Register format is empty")
+	}
+	if _, dup := codecs[format]; dup {
+		panic("transformer: Register called twice for format " + string(format))
+	}
+	if _, dup := aliases[format]; dup {
+		panic("transformer: Register format is already an alias: " + string(format))
+	}
+	codecs[format] = codec
+}
+
+// RegisterAlias
+// makes an alternative name, e.g. "yml", resolve to a registered format.
+// Aliases are accepted by Lookup and Convert but are not listed by Formats.
+// It panics if the format is not registered or the alias is already taken.
+func RegisterAlias(alias, format Format) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	alias, format = normalizeFormat(alias), normalizeFormat(format)
+	if _, ok := codecs[format]; !ok {
+		panic("transformer: RegisterAlias for unknown format " + string(format))
+	}
+	if _, dup := codecs[alias]; dup || alias == "" {
+		panic("transformer: RegisterAlias alias is already a format: " + string(alias))
+	}
+	if _, dup := aliases[alias]; dup {
+		panic("transformer: RegisterAlias called twice for alias " + string(alias))
+	}
+	aliases[alias] = format
+}
+
+// Lookup
+// returns the codec registered for the given format.
+// Format names are case-insensitive and may start with a dot, so file
+// extensions like ".YML" can be passed directly.
+func Lookup(format Format) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	name := normalizeFormat(format)
+	if target, ok := aliases[name]; ok {
+		name = target
+	}
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("transformer: unknown format %q", format)
+	}
+	return codec, nil
+}
+
+// Formats
+// returns the sorted names of all registered formats, without aliases
+func Formats() []Format {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	formats := make([]Format, 0, len(codecs))
+	for format := range codecs {
+		formats = append(formats, format)
+	}
+	sort.Slice(formats, func(i, j int) bool {
+		return formats[i] < formats[j]
+	})
+	return formats
+}
+
+// normalizeFormat
+// lower-case format name without a leading dot
+func normalizeFormat(format Format) Format {
+	return Format(strings.ToLower(strings.TrimPrefix(strings.TrimSpace(string(format)), ".")))
+}
@@ -0,0 +1,141 @@
+package transformer_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/transformer"
+	"github.com/mstgnz/transformer/node"
+	_ "github.com/mstgnz/transformer/tjson"
+	_ "github.com/mstgnz/transformer/txml"
+	_ "github.com/mstgnz/transformer/tyaml"
+)
+
+// kvCodec is a minimal third-party codec for "key=value" lines
+type kvCodec struct{}
+
+func (kvCodec) Decode(r io.Reader) (*node.Node, error) {
+	root := node.NewNode("root")
+	root.Value = &node.Value{Type: node.TypeObject}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %q", scanner.Text())
+		}
+		child := node.NewNode(key)
+		child.Value = &node.Value{Type: node.TypeString, Worth: value}
+		if err := root.AddToEnd(child); err != nil {
+			return nil, err
+		}
+	}
+	return root, scanner.Err()
+}
+
+func (kvCodec) Encode(w io.Writer, n *node.Node) error {
+	for current := n.Value.Node; current != nil; current = current.Next {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", current.Key, current.Value.Worth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (kvCodec) Detect(data []byte) bool {
+	return strings.Contains(string(data), "=")
+}
+
+func init() {
+	transformer.Register("kv", kvCodec{})
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  transformer.Format
+		wantErr bool
+	}{
+		{name: "json", format: transformer.FormatJson},
+		{name: "xml", format: transformer.FormatXml},
+		{name: "yaml", format: transformer.FormatYaml},
+		{name: "yml alias", format: "yml"},
+		{name: "extension with dot", format: ".JSON"},
+		{name: "third-party codec", format: "kv"},
+		{name: "unknown format", format: "csv", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, err := transformer.Lookup(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Lookup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && codec == nil {
+				t.Error("Lookup() returned nil codec")
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	got := transformer.Formats()
+	want := []transformer.Format{"json", "kv", "xml", "yaml"}
+	if len(got) != len(want) {
+		t.Fatalf("Formats() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Formats() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	tests := []struct {
+		name   string
+		format transformer.Format
+		codec  transformer.Codec
+	}{
+		{name: "nil codec", format: "nil", codec: nil},
+		{name: "empty format", format: "", codec: kvCodec{}},
+		{name: "duplicate format", format: "JSON", codec: kvCodec{}},
+		{name: "format taken by alias", format: "yml", codec: kvCodec{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Register() did not panic")
+				}
+			}()
+			transformer.Register(tt.format, tt.codec)
+		})
+	}
+}
+
+func TestRegisterAliasPanics(t *testing.T) {
+	tests := []struct {
+		name   string
+		alias  transformer.Format
+		format transformer.Format
+	}{
+		{name: "unknown format", alias: "jsn", format: "jsonx"},
+		{name: "alias is a format", alias: "xml", format: "json"},
+		{name: "duplicate alias", alias: "yml", format: "yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("RegisterAlias() did not panic")
+				}
+			}()
+			transformer.RegisterAlias(tt.alias, tt.format)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package transformer
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+// Option
+// configures a call to Convert
+type Option func(*options)
+
+type options struct {
+	decoder    Codec
+	encoder    Codec
+	transforms []func(*node.Node) error
+}
+
+// WithDecoder
+// decode the input with the given codec instead of the registered one,
+// e.g. to pass a codec configured with non-default options
+func WithDecoder(codec Codec) Option {
+	return func(o *options) {
+		o.decoder = codec
+	}
+}
+
+// WithEncoder
+// encode the output with the given codec instead of the registered one
+func WithEncoder(codec Codec) Option {
+	return func(o *options) {
+		o.encoder = codec
+	}
+}
+
+// WithTransform
+// run fn on the decoded tree before it is encoded.
+// Transforms run in the order they were given.
+func WithTransform(fn func(*node.Node) error) Option {
+	return func(o *options) {
+		o.transforms = append(o.transforms, fn)
+	}
+}
+
+// Convert
+// decode data in the from format and encode it in the to format
+func Convert(data []byte, from, to Format, opts ...Option) ([]byte, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var err error
+	if o.decoder == nil {
+		if o.decoder, err = Lookup(from); err != nil {
+			return nil, err
+		}
+	}
+	if o.encoder == nil {
+		if o.encoder, err = Lookup(to); err != nil {
+			return nil, err
+		}
+	}
+
+	n, err := o.decoder.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("transformer: decode %s: %w", from, err)
+	}
+
+	for _, fn := range o.transforms {
+		if err := fn(n); err != nil {
+			return nil, fmt.Errorf("transformer: transform: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := o.encoder.Encode(&buf, n); err != nil {
+		return nil, fmt.Errorf("transformer: encode %s: %w", to, err)
+	}
+	return buf.Bytes(), nil
+}
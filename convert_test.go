@@ -0,0 +1,236 @@
+package transformer_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/transformer"
+	"github.com/mstgnz/transformer/node"
+	"github.com/mstgnz/transformer/tjson"
+	"github.com/mstgnz/transformer/txml"
+	"github.com/mstgnz/transformer/tyaml"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		from    transformer.Format
+		to      transformer.Format
+		opts    []transformer.Option
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "json to yaml",
+			data: `{"name": "John", "age": 30}`,
+			from: "json",
+			to:   "yaml",
+			want: "name: John\nage: 30\n",
+		},
+		{
+			name: "yaml to json",
+			data: "name: John\nage: 30\n",
+			from: "yml",
+			to:   "json",
+			want: `{"name":"John","age":30}`,
+		},
+		{
+			name: "xml repeated elements to json",
+			data: `<root><port>80</port><port>443</port><host>a</host></root>`,
+			from: "xml",
+			to:   "json",
+			want: `{"port":[80,443],"host":"a"}`,
+		},
+		{
+			name: "xml to json",
+			data: `<root><name>John</name></root>`,
+			from: "xml",
+			to:   "json",
+			want: `{"name":"John"}`,
+		},
+		{
+			name: "third-party codec",
+			data: "name=John\ncity=Ankara\n",
+			from: "kv",
+			to:   "json",
+			want: `{"name":"John","city":"Ankara"}`,
+		},
+		{
+			name: "with transform",
+			data: `{"name": "John", "age": 30}`,
+			from: "json",
+			to:   "kv",
+			opts: []transformer.Option{
+				transformer.WithTransform(func(n *node.Node) error {
+					return n.Value.Node.Next.Delete()
+				}),
+			},
+			want: "name=John\n",
+		},
+		{
+			name: "with encoder",
+			data: "name=John\n",
+			from: "kv",
+			to:   "unknown",
+			opts: []transformer.Option{transformer.WithEncoder(tjson.Codec{})},
+			want: `{"name":"John"}`,
+		},
+		{
+			name: "failing transform",
+			data: `{}`,
+			from: "json",
+			to:   "yaml",
+			opts: []transformer.Option{
+				transformer.WithTransform(func(*node.Node) error {
+					return errors.New("boom")
+				}),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid input",
+			data:    `{"name": `,
+			from:    "json",
+			to:      "yaml",
+			wantErr: true,
+		},
+		{
+			name:    "unknown format",
+			data:    `{}`,
+			from:    "json",
+			to:      "csv",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := transformer.Convert([]byte(tt.data), tt.from, tt.to, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Convert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("Convert() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvert_Patch(t *testing.T) {
+	patch, err := tjson.DecodeJson([]byte(`[
+		{"op": "test", "path": "/spec/image", "value": "nginx"},
+		{"op": "replace", "path": "/spec/replicas", "value": 3},
+		{"op": "add", "path": "/spec/pull", "value": "Always"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := node.PatchFromNode(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := map[transformer.Format]string{
+		"json": `{"spec": {"replicas": 1, "image": "nginx"}}`,
+		"yaml": "spec:\n  replicas: 1\n  image: nginx\n",
+		"xml":  `<spec><replicas>1</replicas><image>nginx</image></spec>`,
+	}
+	want := `{"spec":{"replicas":3,"image":"nginx","pull":"Always"}}`
+
+	for from, data := range sources {
+		t.Run(string(from), func(t *testing.T) {
+			got, err := transformer.Convert([]byte(data), from, "json",
+				transformer.WithTransform(func(n *node.Node) error {
+					return node.ApplyPatch(n, ops)
+				}))
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if string(got) != want {
+				t.Errorf("Convert() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestMerge_Formats(t *testing.T) {
+	base, err := tjson.DecodeJson([]byte(`{"name": "api", "env": {"LOG": "info", "DEBUG": "1"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := tyaml.DecodeYaml([]byte("env:\n  LOG: warn\n  DEBUG: null\nreplicas: 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node.MergePatch(base, overlay); err != nil {
+		t.Fatalf("MergePatch() error = %v", err)
+	}
+	got, err := tjson.NodeToJson(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"name":"api","env":{"LOG":"warn"},"replicas":2}`; string(got) != want {
+		t.Errorf("MergePatch() = %s, want %s", got, want)
+	}
+}
+
+func TestConvert_NumberPrecision(t *testing.T) {
+	data := `{"id":9007199254740993,"big":123456789012345678901234567890,"total":12.50,"rate":1e-7}`
+
+	current := []byte(data)
+	from := transformer.Format("json")
+	for _, to := range []transformer.Format{"yaml", "xml", "json"} {
+		got, err := transformer.Convert(current, from, to)
+		if err != nil {
+			t.Fatalf("Convert(%s -> %s) error = %v", from, to, err)
+		}
+		current, from = got, to
+	}
+
+	want := data
+	if string(current) != want {
+		t.Errorf("round trip = %s, want %s", current, want)
+	}
+}
+
+func TestDecode_Formats(t *testing.T) {
+	type Server struct {
+		Name string `json:"name" xml:"name,attr"`
+		Port int    `json:"port"`
+	}
+	type Config struct {
+		App struct {
+			Debug   bool     `json:"debug"`
+			Servers []Server `json:"server"`
+		} `json:"app"`
+	}
+
+	sources := map[transformer.Format]string{
+		"json": `{"app": {"debug": true, "server": [{"name": "a", "port": 80}, {"name": "b", "port": 443}]}}`,
+		"yaml": "app:\n  debug: true\n  server:\n    - name: a\n      port: 80\n    - name: b\n      port: 443\n",
+		"xml":  `<app><debug>true</debug><server name="a"><port>80</port></server><server name="b"><port>443</port></server></app>`,
+	}
+	decoders := map[transformer.Format]func([]byte) (*node.Node, error){
+		"json": tjson.DecodeJson,
+		"yaml": tyaml.DecodeYaml,
+		"xml":  txml.DecodeXml,
+	}
+
+	for from, data := range sources {
+		t.Run(string(from), func(t *testing.T) {
+			n, err := decoders[from]([]byte(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cfg Config
+			if err := node.Decode(n, &cfg); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !cfg.App.Debug || len(cfg.App.Servers) != 2 || cfg.App.Servers[1] != (Server{Name: "b", Port: 443}) {
+				t.Errorf("Decode() = %+v", cfg)
+			}
+		})
+	}
+}
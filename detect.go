@@ -0,0 +1,141 @@
+package transformer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+// Sniffer
+// optional interface for codecs that can rate how likely data is
+// in their format. Sniff returns a confidence between 0 and 1;
+// codecs without it score 0.5 when Detect reports a match.
+type Sniffer interface {
+	Sniff(data []byte) float64
+}
+
+// Detection
+// a format guess with its confidence between 0 and 1
+type Detection struct {
+	Format     Format
+	Confidence float64
+}
+
+// Detect
+// returns the most likely format of data and its confidence.
+// It returns an empty format and zero confidence if no codec matches.
+func Detect(data []byte) (Format, float64) {
+	detections := DetectAll(data)
+	if len(detections) == 0 {
+		return "", 0
+	}
+	return detections[0].Format, detections[0].Confidence
+}
+
+// DetectAll
+// rates data against every registered codec and returns the matching
+// formats ranked by confidence, best first. Byte order marks are
+// removed and UTF-16/UTF-32 input is transcoded before sniffing.
+func DetectAll(data []byte) []Detection {
+	data = stripBOM(data)
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	var detections []Detection
+	for format, codec := range codecs {
+		var confidence float64
+		if sniffer, ok := codec.(Sniffer); ok {
+			confidence = sniffer.Sniff(data)
+		} else if codec.Detect(data) {
+			confidence = 0.5
+		}
+		if confidence > 0 {
+			detections = append(detections, Detection{Format: format, Confidence: confidence})
+		}
+	}
+
+	sort.Slice(detections, func(i, j int) bool {
+		if detections[i].Confidence != detections[j].Confidence {
+			return detections[i].Confidence > detections[j].Confidence
+		}
+		return detections[i].Format < detections[j].Format
+	})
+	return detections
+}
+
+// DecodeAny
+// detects the format of data and decodes it with the matching codec.
+// Candidates are tried in ranked order until one decodes successfully,
+// the format that succeeded is returned with the node.
+func DecodeAny(data []byte) (*node.Node, Format, error) {
+	detections := DetectAll(data)
+	if len(detections) == 0 {
+		return nil, "", fmt.Errorf("transformer: unable to detect format")
+	}
+
+	data = stripBOM(data)
+
+	var firstErr error
+	for _, detection := range detections {
+		codec, err := Lookup(detection.Format)
+		if err != nil {
+			return nil, "", err
+		}
+		n, err := codec.Decode(bytes.NewReader(data))
+		if err == nil {
+			return n, detection.Format, nil
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("transformer: decode %s: %w", detection.Format, err)
+		}
+	}
+	return nil, "", firstErr
+}
+
+// stripBOM
+// removes a leading byte order mark, converting UTF-16 and UTF-32 input to UTF-8
+func stripBOM(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return data[3:]
+	case bytes.HasPrefix(data, []byte{0x00, 0x00, 0xFE, 0xFF}):
+		return decodeUTF32(data[4:], binary.BigEndian)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE, 0x00, 0x00}):
+		return decodeUTF32(data[4:], binary.LittleEndian)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], binary.BigEndian)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], binary.LittleEndian)
+	}
+	return data
+}
+
+// decodeUTF16
+// convert UTF-16 bytes without BOM to UTF-8
+func decodeUTF16(data []byte, order binary.ByteOrder) []byte {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// decodeUTF32
+// convert UTF-32 bytes without BOM to UTF-8
+func decodeUTF32(data []byte, order binary.ByteOrder) []byte {
+	buf := make([]byte, 0, len(data)/4)
+	for i := 0; i+4 <= len(data); i += 4 {
+		r := rune(order.Uint32(data[i:]))
+		if !utf8.ValidRune(r) {
+			r = utf8.RuneError
+		}
+		buf = utf8.AppendRune(buf, r)
+	}
+	return buf
+}
@@ -0,0 +1,93 @@
+package transformer_test
+
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/mstgnz/transformer"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           []byte
+		want           transformer.Format
+		wantConfidence float64
+	}{
+		{name: "json object", data: []byte(`{"name": "John"}`), want: "json", wantConfidence: 1},
+		{name: "json array", data: []byte(" \n[1, 2, 3]"), want: "json", wantConfidence: 1},
+		{name: "json scalar", data: []byte(`42`), want: "json", wantConfidence: 0.6},
+		{name: "xml with declaration", data: []byte(`<?xml version="1.0"?><a>1</a>`), want: "xml", wantConfidence: 1},
+		{name: "xml without declaration", data: []byte(`<a><b>1</b></a>`), want: "xml", wantConfidence: 0.9},
+		{name: "yaml document marker", data: []byte("---\nname: John\n"), want: "yaml", wantConfidence: 0.95},
+		{name: "yaml mapping", data: []byte("name: John\nage: 30\n"), want: "yaml", wantConfidence: 0.8},
+		{name: "yaml flow mapping", data: []byte("{name: John}"), want: "yaml", wantConfidence: 0.8},
+		{name: "utf-8 bom", data: append([]byte{0xEF, 0xBB, 0xBF}, `{"a": 1}`...), want: "json", wantConfidence: 1},
+		{name: "utf-16 bom", data: utf16LE(`<a>1</a>`), want: "xml", wantConfidence: 0.9},
+		{name: "empty input", data: []byte{}, want: "", wantConfidence: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, confidence := transformer.Detect(tt.data)
+			if got != tt.want || confidence != tt.wantConfidence {
+				t.Errorf("Detect() = %q, %v, want %q, %v", got, confidence, tt.want, tt.wantConfidence)
+			}
+		})
+	}
+}
+
+func TestDetectAllRanking(t *testing.T) {
+	got := transformer.DetectAll([]byte(`{"name": "John"}`))
+	if len(got) != 2 {
+		t.Fatalf("DetectAll() = %v, want json and yaml", got)
+	}
+	if got[0].Format != "json" || got[1].Format != "yaml" {
+		t.Errorf("DetectAll() = %v, want json ranked before yaml", got)
+	}
+	if got[0].Confidence <= got[1].Confidence {
+		t.Errorf("DetectAll() confidences not descending: %v", got)
+	}
+}
+
+func TestDecodeAny(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		wantFormat transformer.Format
+		wantKey    string
+		wantErr    bool
+	}{
+		{name: "json", data: []byte(`{"name": "John"}`), wantFormat: "json", wantKey: "name"},
+		{name: "xml", data: []byte(`<root><name>John</name></root>`), wantFormat: "xml", wantKey: "name"},
+		{name: "yaml", data: []byte("name: John\n"), wantFormat: "yaml", wantKey: "name"},
+		{name: "bom prefixed yaml", data: append([]byte{0xEF, 0xBB, 0xBF}, "name: John\n"...), wantFormat: "yaml", wantKey: "name"},
+		{name: "undetectable", data: []byte("<broken"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, format, err := transformer.DecodeAny(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeAny() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if format != tt.wantFormat {
+				t.Errorf("DecodeAny() format = %q, want %q", format, tt.wantFormat)
+			}
+			if n.Value.Node == nil || n.Value.Node.Key != tt.wantKey {
+				t.Errorf("DecodeAny() first key is not %q", tt.wantKey)
+			}
+		})
+	}
+}
+
+func utf16LE(s string) []byte {
+	data := []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		data = append(data, byte(u), byte(u>>8))
+	}
+	return data
+}
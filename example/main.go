@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 
+	"github.com/mstgnz/transformer"
 	"github.com/mstgnz/transformer/node"
 	"github.com/mstgnz/transformer/tjson"
 	"github.com/mstgnz/transformer/txml"
@@ -32,8 +33,8 @@ func main() {
 	fmt.Println("JSON Node:")
 	jsonNode.Print()
 
-	// Convert Node to XML
-	xmlBytes, err := txml.NodeToXml(jsonNode)
+	// Convert JSON to XML through the codec registry
+	xmlBytes, err := transformer.Convert(jsonData, transformer.FormatJson, transformer.FormatXml)
 	if err != nil {
 		fmt.Printf("Error converting to XML: %v\n", err)
 		return
@@ -42,28 +43,18 @@ func main() {
 	fmt.Println("\nGenerated XML:")
 	fmt.Println(string(xmlBytes))
 
-	// Parse XML back to Node
-	xmlNode, err := txml.DecodeXml(xmlBytes)
-	if err != nil {
-		fmt.Printf("Error decoding XML: %v\n", err)
-		return
-	}
-
-	fmt.Println("\nXML Node:")
-	xmlNode.Print()
-
-	// Convert Node to YAML
-	yamlBytes, err := tyaml.NodeToYaml(xmlNode)
+	// Convert XML to YAML
+	yamlBytes, err := transformer.Convert(xmlBytes, transformer.FormatXml, transformer.FormatYaml)
 	if err != nil {
 		fmt.Printf("Error converting to YAML: %v\n", err)
 		return
 	}
 
 	fmt.Println("\nGenerated YAML:")
-	fmt.Println(yamlBytes)
+	fmt.Println(string(yamlBytes))
 
 	// Parse YAML back to Node
-	yamlNode, err := tyaml.DecodeYaml([]byte(yamlBytes))
+	yamlNode, err := tyaml.DecodeYaml(yamlBytes)
 	if err != nil {
 		fmt.Printf("Error decoding YAML: %v\n", err)
 		return
@@ -75,33 +66,31 @@ func main() {
 	// Demonstrate node manipulation
 	fmt.Println("\nDemonstrating node manipulation:")
 
-	// Create a new node
+	// Create a new node and set values by path, the value types are inferred
 	root := node.NewNode("root")
-
-	// Add some child nodes
-	person := node.NewNode("person")
-	person.Value = &node.Value{
-		Type: node.TypeObject,
+	if err := root.Set("person.name", "Alice"); err != nil {
+		fmt.Printf("Error setting name: %v\n", err)
+		return
 	}
-
-	name := node.NewNode("name")
-	name.Value = &node.Value{
-		Type:  node.TypeString,
-		Worth: "Alice",
+	if err := root.Set("person.age", 25); err != nil {
+		fmt.Printf("Error setting age: %v\n", err)
+		return
 	}
-
-	age := node.NewNode("age")
-	age.Value = &node.Value{
-		Type:  node.TypeNumber,
-		Worth: "25",
+	root.Set("person.tags", []string{"admin", "dev"})
+
+	// Values can also be built with the constructors
+	person := root.GetNodeByPath("root.person")
+	active := node.NewNode("active")
+	active.AddToValue(node.NewBool(true))
+	person.AddToEnd(active)
+
+	// Typed accessors read them back
+	if age, err := root.GetByPath("person.age"); err == nil {
+		if years, err := age.Int64(); err == nil {
+			fmt.Printf("Alice is %d years old\n", years)
+		}
 	}
 
-	// Build the tree
-	root.AddToValue(&node.Value{Type: node.TypeObject})
-	root.AddToStart(person)
-	person.AddToStart(name)
-	person.AddToEnd(age)
-
 	fmt.Println("Created node structure:")
 	root.Print()
 
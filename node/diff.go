@@ -0,0 +1,546 @@
+package node
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ChangeType is the kind of a Change reported by Diff
+type ChangeType string
+
+// Kinds of changes reported by Diff
+const (
+	ChangeAdded    ChangeType = "added"    // the path only exists in the new tree
+	ChangeRemoved  ChangeType = "removed"  // the path only exists in the old tree
+	ChangeModified ChangeType = "modified" // the value or its type changed
+	ChangeMoved    ChangeType = "moved"    // an equal value changed its position
+)
+
+// Change is a single difference between two trees.
+// Path is the JSON Pointer of the change in the new tree, or in the old
+// tree for removals. For moves, From holds the old location; a member
+// that only changed its position among its siblings has From == Path.
+type Change struct {
+	Type     ChangeType
+	Path     string
+	From     string
+	OldValue *Value // nil for additions
+	NewValue *Value // nil for removals
+}
+
+// String returns a one-line description of the change
+func (c Change) String() string {
+	switch c.Type {
+	case ChangeAdded:
+		return fmt.Sprintf("added %s: %s", c.Path, valueJSON(c.NewValue))
+	case ChangeRemoved:
+		return fmt.Sprintf("removed %s: %s", c.Path, valueJSON(c.OldValue))
+	case ChangeMoved:
+		if c.From == c.Path {
+			return fmt.Sprintf("moved %s within its object", c.Path)
+		}
+		return fmt.Sprintf("moved %s to %s", c.From, c.Path)
+	default:
+		return fmt.Sprintf("modified %s: %s (%s) -> %s (%s)", c.Path,
+			valueJSON(c.OldValue), typeLabel(c.OldValue), valueJSON(c.NewValue), typeLabel(c.NewValue))
+	}
+}
+
+// typeLabel returns the type of a value, preceded by its tag if it has one
+func typeLabel(v *Value) string {
+	if v.Tag != "" {
+		return v.Tag + " " + v.Type.String()
+	}
+	return v.Type.String()
+}
+
+// DiffOption configures Diff
+type DiffOption func(*diffOptions)
+
+// diffOptions holds the settings of a Diff call
+type diffOptions struct {
+	ignoreKeyOrder   bool
+	ignoreArrayOrder bool
+	ignorePaths      [][]string
+}
+
+// IgnoreKeyOrder stops Diff from reporting object members that only
+// changed their position
+func IgnoreKeyOrder() DiffOption {
+	return func(o *diffOptions) {
+		o.ignoreKeyOrder = true
+	}
+}
+
+// IgnoreArrayOrder compares arrays as multisets: items are matched by
+// value regardless of position and only unmatched items are reported
+func IgnoreArrayOrder() DiffOption {
+	return func(o *diffOptions) {
+		o.ignoreArrayOrder = true
+	}
+}
+
+// IgnorePaths skips the given JSON Pointers and everything below them.
+// A "*" token matches any single member or index, e.g.
+// "/items/*/metadata/uid". Invalid pointers are ignored.
+func IgnorePaths(pointers ...string) DiffOption {
+	return func(o *diffOptions) {
+		for _, pointer := range pointers {
+			if tokens, err := ParsePointer(pointer); err == nil {
+				o.ignorePaths = append(o.ignorePaths, tokens)
+			}
+		}
+	}
+}
+
+// Diff compares the values of a and b and returns their differences.
+// Object members are matched by key and arrays by position, with items
+// that only moved reported as moves. Numbers are compared numerically.
+// The result is empty when the trees are equal under the given options.
+func Diff(a, b *Node, opts ...DiffOption) []Change {
+	o := &diffOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	from, to := &Value{Type: TypeNull}, &Value{Type: TypeNull}
+	if a != nil && a.Value != nil {
+		from = a.Value
+	}
+	if b != nil && b.Value != nil {
+		to = b.Value
+	}
+	return o.diff(nil, from, to, nil)
+}
+
+// ignored reports whether path matches one of the ignored pointers
+func (o *diffOptions) ignored(path []string) bool {
+	for _, ignore := range o.ignorePaths {
+		if len(ignore) > len(path) {
+			continue
+		}
+		matched := true
+		for i, token := range ignore {
+			if token != "*" && token != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// diff appends the changes between from and to at path
+func (o *diffOptions) diff(path []string, from, to *Value, changes []Change) []Change {
+	if o.ignored(path) {
+		return changes
+	}
+
+	switch {
+	case from.Tag != to.Tag:
+		return append(changes, Change{Type: ChangeModified, Path: FormatPointer(path), OldValue: from, NewValue: to})
+	case from.Type == TypeObject && to.Type == TypeObject:
+		return o.diffMembers(path, from, to, changes)
+	case from.Type == TypeArray && to.Type == TypeArray:
+		if o.ignoreArrayOrder {
+			return o.diffUnordered(path, from, to, changes)
+		}
+		return o.diffItems(path, from, to, changes)
+	case from.Equal(to):
+		return changes
+	default:
+		return append(changes, Change{Type: ChangeModified, Path: FormatPointer(path), OldValue: from, NewValue: to})
+	}
+}
+
+// diffMembers compares two objects member by member. Members sharing a
+// key are paired by occurrence.
+func (o *diffOptions) diffMembers(path []string, from, to *Value, changes []Change) []Change {
+	fromMembers, fromOrder := indexMembers(from)
+	toMembers, toOrder := indexMembers(to)
+
+	var common []memberID
+	for _, id := range fromOrder {
+		current := fromMembers[id]
+		memberPath := appendToken(path, id.key)
+		if other, ok := toMembers[id]; ok {
+			common = append(common, id)
+			changes = o.diff(memberPath, current.Value, other.Value, changes)
+		} else if !o.ignored(memberPath) {
+			changes = append(changes, Change{Type: ChangeRemoved, Path: FormatPointer(memberPath), OldValue: current.Value})
+		}
+	}
+
+	var order []memberID
+	for _, id := range toOrder {
+		memberPath := appendToken(path, id.key)
+		if _, ok := fromMembers[id]; ok {
+			order = append(order, id)
+		} else if !o.ignored(memberPath) {
+			changes = append(changes, Change{Type: ChangeAdded, Path: FormatPointer(memberPath), NewValue: toMembers[id].Value})
+		}
+	}
+
+	if o.ignoreKeyOrder {
+		return changes
+	}
+
+	// Members outside the longest common ordering are the ones that moved
+	kept := lcs(len(common), len(order), func(i, j int) bool { return common[i] == order[j] })
+	stayed := make(map[int]bool, len(kept))
+	for _, pair := range kept {
+		stayed[pair[1]] = true
+	}
+	for j, id := range order {
+		memberPath := appendToken(path, id.key)
+		if stayed[j] || o.ignored(memberPath) {
+			continue
+		}
+		pointer := FormatPointer(memberPath)
+		changes = append(changes, Change{
+			Type:     ChangeMoved,
+			Path:     pointer,
+			From:     pointer,
+			OldValue: fromMembers[id].Value,
+			NewValue: toMembers[id].Value,
+		})
+	}
+	return changes
+}
+
+// diffItems compares two arrays by position. Items outside the longest
+// common subsequence that appear on both sides are moves, items left at
+// the same index on both sides are compared recursively and the rest
+// are additions and removals.
+func (o *diffOptions) diffItems(path []string, from, to *Value, changes []Change) []Change {
+	kept := lcs(len(from.Array), len(to.Array), func(i, j int) bool {
+		return from.Array[i].Elem().Equal(to.Array[j].Elem())
+	})
+	oldKept := make(map[int]bool, len(kept))
+	newKept := make(map[int]bool, len(kept))
+	for _, pair := range kept {
+		oldKept[pair[0]], newKept[pair[1]] = true, true
+	}
+
+	var removed, added []int
+	for i := range from.Array {
+		if !oldKept[i] {
+			removed = append(removed, i)
+		}
+	}
+	for j := range to.Array {
+		if !newKept[j] {
+			added = append(added, j)
+		}
+	}
+
+	// Equal items on both sides moved
+	var moves []Change
+	for ai := 0; ai < len(added); ai++ {
+		j := added[ai]
+		for ri, i := range removed {
+			if from.Array[i].Elem().Equal(to.Array[j].Elem()) {
+				moves = append(moves, Change{
+					Type:     ChangeMoved,
+					Path:     FormatPointer(appendToken(path, strconv.Itoa(j))),
+					From:     FormatPointer(appendToken(path, strconv.Itoa(i))),
+					OldValue: from.Array[i].Elem(),
+					NewValue: to.Array[j].Elem(),
+				})
+				removed = append(removed[:ri], removed[ri+1:]...)
+				added = append(added[:ai], added[ai+1:]...)
+				ai--
+				break
+			}
+		}
+	}
+
+	// Items changed in place
+	addedAt := make(map[int]bool, len(added))
+	for _, j := range added {
+		addedAt[j] = true
+	}
+	inPlace := make(map[int]bool)
+	for _, i := range removed {
+		if addedAt[i] {
+			inPlace[i] = true
+			changes = o.diff(appendToken(path, strconv.Itoa(i)), from.Array[i].Elem(), to.Array[i].Elem(), changes)
+		}
+	}
+
+	for _, i := range removed {
+		itemPath := appendToken(path, strconv.Itoa(i))
+		if !inPlace[i] && !o.ignored(itemPath) {
+			changes = append(changes, Change{Type: ChangeRemoved, Path: FormatPointer(itemPath), OldValue: from.Array[i].Elem()})
+		}
+	}
+	for _, j := range added {
+		itemPath := appendToken(path, strconv.Itoa(j))
+		if !inPlace[j] && !o.ignored(itemPath) {
+			changes = append(changes, Change{Type: ChangeAdded, Path: FormatPointer(itemPath), NewValue: to.Array[j].Elem()})
+		}
+	}
+	for _, move := range moves {
+		if tokens, _ := ParsePointer(move.Path); !o.ignored(tokens) {
+			changes = append(changes, move)
+		}
+	}
+	return changes
+}
+
+// diffUnordered compares two arrays as multisets
+func (o *diffOptions) diffUnordered(path []string, from, to *Value, changes []Change) []Change {
+	matched := make([]bool, len(from.Array))
+	var added []int
+	for j, item := range to.Array {
+		found := false
+		for i, old := range from.Array {
+			if !matched[i] && old.Elem().Equal(item.Elem()) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			added = append(added, j)
+		}
+	}
+
+	for i, old := range from.Array {
+		itemPath := appendToken(path, strconv.Itoa(i))
+		if !matched[i] && !o.ignored(itemPath) {
+			changes = append(changes, Change{Type: ChangeRemoved, Path: FormatPointer(itemPath), OldValue: old.Elem()})
+		}
+	}
+	for _, j := range added {
+		itemPath := appendToken(path, strconv.Itoa(j))
+		if !o.ignored(itemPath) {
+			changes = append(changes, Change{Type: ChangeAdded, Path: FormatPointer(itemPath), NewValue: to.Array[j].Elem()})
+		}
+	}
+	return changes
+}
+
+// lcs returns the index pairs of a longest common subsequence of two
+// sequences of lengths n and m, with equal reporting matching elements.
+// Common leading and trailing elements are matched directly and the rest
+// is split as in Hirschberg's algorithm, so memory stays linear.
+func lcs(n, m int, equal func(i, j int) bool) [][2]int {
+	var pairs [][2]int
+	lcsRange(0, n, 0, m, equal, &pairs)
+	return pairs
+}
+
+// lcsRange appends the pairs of a longest common subsequence of the
+// elements i0..i1 and j0..j1 to pairs, in order
+func lcsRange(i0, i1, j0, j1 int, equal func(i, j int) bool, pairs *[][2]int) {
+	for i0 < i1 && j0 < j1 && equal(i0, j0) {
+		*pairs = append(*pairs, [2]int{i0, j0})
+		i0++
+		j0++
+	}
+	suffix := 0
+	for i0 < i1 && j0 < j1 && equal(i1-1, j1-1) {
+		i1--
+		j1--
+		suffix++
+	}
+	defer func() {
+		for k := 0; k < suffix; k++ {
+			*pairs = append(*pairs, [2]int{i1 + k, j1 + k})
+		}
+	}()
+
+	switch {
+	case i0 == i1 || j0 == j1:
+	case i1-i0 == 1:
+		for j := j0; j < j1; j++ {
+			if equal(i0, j) {
+				*pairs = append(*pairs, [2]int{i0, j})
+				break
+			}
+		}
+	default:
+		// Split the second sequence where the halves of the first one
+		// together match the most
+		mid := (i0 + i1) / 2
+		head := lcsLengths(i0, mid, j0, j1, equal, false)
+		tail := lcsLengths(mid, i1, j0, j1, equal, true)
+		split := j0
+		for k := range head {
+			if head[k]+tail[k] > head[split-j0]+tail[split-j0] {
+				split = j0 + k
+			}
+		}
+		lcsRange(i0, mid, j0, split, equal, pairs)
+		lcsRange(mid, i1, split, j1, equal, pairs)
+	}
+}
+
+// lcsLengths returns, for every k from 0 to j1-j0, the length of a longest
+// common subsequence of the elements i0..i1 and j0..j0+k, or j0+k..j1 when
+// reverse is set, keeping two rows of the table at a time
+func lcsLengths(i0, i1, j0, j1 int, equal func(i, j int) bool, reverse bool) []int {
+	width := j1 - j0
+	prev, row := make([]int, width+1), make([]int, width+1)
+	for step := 0; step < i1-i0; step++ {
+		if !reverse {
+			i := i0 + step
+			for k := 1; k <= width; k++ {
+				if equal(i, j0+k-1) {
+					row[k] = prev[k-1] + 1
+				} else {
+					row[k] = max(prev[k], row[k-1])
+				}
+			}
+		} else {
+			i := i1 - 1 - step
+			for k := width - 1; k >= 0; k-- {
+				if equal(i, j0+k) {
+					row[k] = prev[k+1] + 1
+				} else {
+					row[k] = max(prev[k], row[k+1])
+				}
+			}
+		}
+		prev, row = row, prev
+	}
+	return prev
+}
+
+// WriteUnified writes changes in a unified diff like layout, one hunk
+// per change with "-" lines for old values and "+" lines for new ones
+func WriteUnified(w io.Writer, changes []Change) error {
+	buf := bufio.NewWriter(w)
+	if len(changes) > 0 {
+		buf.WriteString("--- a\n+++ b\n")
+	}
+
+	for _, c := range changes {
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+		switch c.Type {
+		case ChangeMoved:
+			if c.From == c.Path {
+				fmt.Fprintf(buf, "@@ %s moved within its object @@\n", path)
+			} else {
+				fmt.Fprintf(buf, "@@ %s moved from %s @@\n", path, c.From)
+			}
+		default:
+			fmt.Fprintf(buf, "@@ %s %s @@\n", path, c.Type)
+		}
+
+		if c.OldValue != nil && c.Type != ChangeMoved {
+			fmt.Fprintf(buf, "-%s\n", valueJSON(c.OldValue))
+		}
+		if c.NewValue != nil && c.Type != ChangeMoved {
+			fmt.Fprintf(buf, "+%s\n", valueJSON(c.NewValue))
+		}
+	}
+	return buf.Flush()
+}
+
+// jsonChange is the JSON form of a Change written by WriteJSON
+type jsonChange struct {
+	Type     ChangeType      `json:"type"`
+	Path     string          `json:"path"`
+	From     string          `json:"from,omitempty"`
+	OldType  string          `json:"oldType,omitempty"`
+	NewType  string          `json:"newType,omitempty"`
+	OldValue json.RawMessage `json:"oldValue,omitempty"`
+	NewValue json.RawMessage `json:"newValue,omitempty"`
+}
+
+// WriteJSON writes changes as a JSON array of objects with "type", "path",
+// "from", "oldType", "newType", "oldValue" and "newValue" members, for
+// consumption by CI tooling
+func WriteJSON(w io.Writer, changes []Change) error {
+	out := make([]jsonChange, len(changes))
+	for i, c := range changes {
+		out[i] = jsonChange{Type: c.Type, Path: c.Path, From: c.From}
+		if c.OldValue != nil {
+			out[i].OldType = c.OldValue.Type.String()
+			out[i].OldValue = json.RawMessage(valueJSON(c.OldValue))
+		}
+		if c.NewValue != nil {
+			out[i].NewType = c.NewValue.Type.String()
+			out[i].NewValue = json.RawMessage(valueJSON(c.NewValue))
+		}
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// valueJSON returns v as compact JSON. Object members keep their order;
+// numbers that are not valid JSON literals are written as strings.
+func valueJSON(v *Value) string {
+	var b strings.Builder
+	writeValueJSON(&b, v)
+	return b.String()
+}
+
+// writeValueJSON appends the JSON form of v to b
+func writeValueJSON(b *strings.Builder, v *Value) {
+	if v == nil {
+		b.WriteString("null")
+		return
+	}
+
+	switch v.Type {
+	case TypeObject:
+		b.WriteByte('{')
+		for current := v.Node; current != nil; current = current.Next {
+			if current != v.Node {
+				b.WriteByte(',')
+			}
+			writeJSONString(b, current.Key)
+			b.WriteByte(':')
+			writeValueJSON(b, current.Value)
+		}
+		b.WriteByte('}')
+	case TypeArray:
+		b.WriteByte('[')
+		for i, item := range v.Array {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeValueJSON(b, item.Elem())
+		}
+		b.WriteByte(']')
+	case TypeNumber:
+		if json.Valid([]byte(v.Worth)) {
+			b.WriteString(v.Worth)
+		} else {
+			writeJSONString(b, v.Worth)
+		}
+	case TypeBoolean:
+		if truth, err := v.Bool(); err == nil {
+			b.WriteString(strconv.FormatBool(truth))
+		} else {
+			writeJSONString(b, v.Worth)
+		}
+	case TypeNull:
+		b.WriteString("null")
+	default:
+		writeJSONString(b, v.Worth)
+	}
+}
+
+// writeJSONString appends s as a JSON string literal to b
+func writeJSONString(b *strings.Builder, s string) {
+	data, _ := json.Marshal(s)
+	b.Write(data)
+}
@@ -0,0 +1,233 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// keyedTree builds a tree whose object members keep the given order,
+// alternating keys and values: keyedTree("b", 1, "a", 2)
+func keyedTree(pairs ...any) *Node {
+	root := NewNode("root")
+	root.Value = &Value{Type: TypeObject}
+	for i := 0; i < len(pairs); i += 2 {
+		member := NewNode(pairs[i].(string))
+		member.AddToValue(fromAny(pairs[i+1]))
+		root.AddToEnd(member)
+	}
+	return root
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *Node
+		opts []DiffOption
+		want []string
+	}{
+		{
+			name: "equal",
+			a:    newTree(map[string]any{"a": 1, "b": []any{1, "x"}}),
+			b:    newTree(map[string]any{"a": 1.0, "b": []any{1, "x"}}),
+			want: nil,
+		},
+		{
+			name: "members",
+			a:    newTree(map[string]any{"keep": 1, "drop": true, "change": "x"}),
+			b:    newTree(map[string]any{"keep": 1, "change": "y", "new": nil}),
+			want: []string{
+				`modified /change: "x" (string) -> "y" (string)`,
+				`removed /drop: true`,
+				`added /new: null`,
+			},
+		},
+		{
+			name: "type change",
+			a:    newTree(map[string]any{"port": "80"}),
+			b:    newTree(map[string]any{"port": 80}),
+			want: []string{`modified /port: "80" (string) -> 80 (number)`},
+		},
+		{
+			name: "nested",
+			a:    newTree(map[string]any{"spec": map[string]any{"containers": []any{map[string]any{"image": "nginx:1.24"}}}}),
+			b:    newTree(map[string]any{"spec": map[string]any{"containers": []any{map[string]any{"image": "nginx:1.27"}}}}),
+			want: []string{`modified /spec/containers/0/image: "nginx:1.24" (string) -> "nginx:1.27" (string)`},
+		},
+		{
+			name: "key order",
+			a:    keyedTree("a", 1, "b", 2, "c", 3),
+			b:    keyedTree("b", 2, "c", 3, "a", 1),
+			want: []string{`moved /a within its object`},
+		},
+		{
+			// Mixed content as the lossless XML decoder keeps it: <a>x<b/>y</a>
+			name: "repeated keys",
+			a:    keyedTree("a", keyedTree("#text", "x", "b", nil, "#text", "y").Value),
+			b:    keyedTree("a", keyedTree("#text", "x", "b", nil, "#text", "z", "#text", "w").Value),
+			want: []string{
+				`modified /a/#text: "y" (string) -> "z" (string)`,
+				`added /a/#text: "w"`,
+			},
+		},
+		{
+			name: "tag changed",
+			a:    newTree(map[string]any{"x": tagged("!foo", map[string]any{"k": 1})}),
+			b:    newTree(map[string]any{"x": tagged("!bar", map[string]any{"k": 1})}),
+			want: []string{`modified /x: {"k":1} (!foo object) -> {"k":1} (!bar object)`},
+		},
+		{
+			name: "ignore key order",
+			a:    keyedTree("a", 1, "b", 2),
+			b:    keyedTree("b", 2, "a", 1),
+			opts: []DiffOption{IgnoreKeyOrder()},
+			want: nil,
+		},
+		{
+			name: "array insert and remove",
+			a:    newTree([]any{"a", "b", "c"}),
+			b:    newTree([]any{"a", "c", "d", "e"}),
+			want: []string{`removed /1: "b"`, `added /2: "d"`, `added /3: "e"`},
+		},
+		{
+			name: "array tail removed",
+			a:    newTree([]any{1, 2, 3, 4}),
+			b:    newTree([]any{1, 2}),
+			want: []string{`removed /2: 3`, `removed /3: 4`},
+		},
+		{
+			name: "array move",
+			a:    newTree([]any{"x", "y", "z"}),
+			b:    newTree([]any{"y", "z", "x"}),
+			want: []string{`moved /0 to /2`},
+		},
+		{
+			name: "ignore array order",
+			a:    newTree([]any{"x", "y", "z", "y"}),
+			b:    newTree([]any{"y", "z", "x", "w"}),
+			opts: []DiffOption{IgnoreArrayOrder()},
+			want: []string{`removed /3: "y"`, `added /3: "w"`},
+		},
+		{
+			name: "ignore paths",
+			a:    newTree(map[string]any{"metadata": map[string]any{"uid": "1", "name": "a"}, "items": []any{map[string]any{"id": 1, "v": 1}}}),
+			b:    newTree(map[string]any{"metadata": map[string]any{"uid": "2", "name": "b"}, "items": []any{map[string]any{"id": 2, "v": 1}}}),
+			opts: []DiffOption{IgnorePaths("/metadata/uid", "/items/*/id")},
+			want: []string{`modified /metadata/name: "a" (string) -> "b" (string)`},
+		},
+		{
+			name: "root",
+			a:    newTree("a"),
+			b:    newTree([]any{"a"}),
+			want: []string{`modified : "a" (string) -> ["a"] (array)`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range Diff(tt.a, tt.b, tt.opts...) {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteUnified(t *testing.T) {
+	a := newTree(map[string]any{"replicas": 1, "labels": map[string]any{"app": "web"}, "ports": []any{80, 443}})
+	b := newTree(map[string]any{"replicas": 3, "labels": map[string]any{}, "ports": []any{443, 80}})
+
+	var buf bytes.Buffer
+	if err := WriteUnified(&buf, Diff(a, b)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `--- a
++++ b
+@@ /labels/app removed @@
+-"web"
+@@ /ports/1 moved from /ports/0 @@
+@@ /replicas modified @@
+-1
++3
+`
+	if buf.String() != want {
+		t.Errorf("WriteUnified() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteUnified(&buf, nil); err != nil || buf.Len() != 0 {
+		t.Errorf("WriteUnified(nil) = %q, %v, want no output", buf.String(), err)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	a := newTree(map[string]any{"a": 1, "b": []any{"x"}})
+	b := newTree(map[string]any{"a": "1", "c": map[string]any{"d": true}})
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, Diff(a, b)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON: %v\n%s", err, buf.String())
+	}
+	want := []map[string]any{
+		{"type": "modified", "path": "/a", "oldType": "number", "newType": "string", "oldValue": 1.0, "newValue": "1"},
+		{"type": "removed", "path": "/b", "oldType": "array", "oldValue": []any{"x"}},
+		{"type": "added", "path": "/c", "newType": "object", "newValue": map[string]any{"d": true}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteJSON() = %v, want %v", got, want)
+	}
+}
+
+func TestLcs(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"", ""},
+		{"abc", ""},
+		{"abc", "abc"},
+		{"abcbdab", "bdcaba"},
+		{"xaby", "xbay"},
+		{"aaaa", "aa"},
+		{"abcdefghij", "bcdxyzghij"},
+		{"thequickbrownfox", "aquickredfoxjumps"},
+	}
+
+	for _, tt := range tests {
+		equal := func(i, j int) bool { return tt.a[i] == tt.b[j] }
+		pairs := lcs(len(tt.a), len(tt.b), equal)
+
+		// The full table gives the expected length
+		table := make([][]int, len(tt.a)+1)
+		for i := range table {
+			table[i] = make([]int, len(tt.b)+1)
+		}
+		for i := len(tt.a) - 1; i >= 0; i-- {
+			for j := len(tt.b) - 1; j >= 0; j-- {
+				if equal(i, j) {
+					table[i][j] = table[i+1][j+1] + 1
+				} else {
+					table[i][j] = max(table[i+1][j], table[i][j+1])
+				}
+			}
+		}
+		if len(pairs) != table[0][0] {
+			t.Errorf("lcs(%q, %q) has %d pairs, want %d", tt.a, tt.b, len(pairs), table[0][0])
+		}
+		for k, pair := range pairs {
+			if !equal(pair[0], pair[1]) {
+				t.Errorf("lcs(%q, %q) pairs unequal elements %v", tt.a, tt.b, pair)
+			}
+			if k > 0 && (pair[0] <= pairs[k-1][0] || pair[1] <= pairs[k-1][1]) {
+				t.Errorf("lcs(%q, %q) pairs are not increasing: %v", tt.a, tt.b, pairs)
+			}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package node
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ArrayStrategy decides how Merge combines two arrays at the same path
+type ArrayStrategy struct {
+	append bool
+	key    string
+}
+
+// Array strategies for MergeOptions
+var (
+	// ArrayReplace replaces the destination array with the source array
+	ArrayReplace = ArrayStrategy{}
+	// ArrayAppend appends the source items to the destination array
+	ArrayAppend = ArrayStrategy{append: true}
+)
+
+// MergeByKey merges object items that have the same value for the given
+// member, e.g. containers by "name". Source items without a match are
+// appended, as are items that are not objects or lack the member.
+func MergeByKey(key string) ArrayStrategy {
+	return ArrayStrategy{key: key}
+}
+
+// String returns the name of the strategy
+func (s ArrayStrategy) String() string {
+	switch {
+	case s.key != "":
+		return fmt.Sprintf("merge by %q", s.key)
+	case s.append:
+		return "append"
+	default:
+		return "replace"
+	}
+}
+
+// ConflictFunc resolves two values at the same path that cannot be merged,
+// i.e. differing scalars or values of different types. path is a JSON
+// Pointer. The returned value is stored at path; returning dst keeps the
+// destination value and an error aborts the merge.
+type ConflictFunc func(path string, dst, src *Value) (*Value, error)
+
+// MergeOptions configures Merge. The zero value deep merges objects,
+// replaces arrays, keeps null values and lets the source win conflicts.
+type MergeOptions struct {
+	ArrayStrategy ArrayStrategy // how arrays present on both sides are combined
+	NullDeletes   bool          // a null source member deletes the destination member
+	ConflictFunc  ConflictFunc  // resolves conflicting values, the source wins when nil
+}
+
+// Merge deep merges the value of src into the value of dst.
+// Objects are merged member by member: members only in src are appended,
+// members on both sides are merged recursively. Arrays are combined
+// according to opts.ArrayStrategy and any other differing values are a
+// conflict. src is not modified, and dst is left unchanged on error.
+// Trees from different formats can be merged, e.g. a YAML overlay onto a
+// JSON base.
+func Merge(dst, src *Node, opts MergeOptions) error {
+	if dst == nil {
+		return fmt.Errorf("node is nil")
+	}
+	if src == nil || src.Value == nil {
+		return nil
+	}
+
+	base := &Value{Type: TypeNull}
+	if dst.Value != nil {
+		base = dst.Value.Clone()
+	}
+
+	merged, err := opts.merge(nil, base, src.Value)
+	if err != nil {
+		return err
+	}
+	return dst.AddToValue(merged)
+}
+
+// MergePatch applies an RFC 7386 JSON Merge Patch to the value of target:
+// objects are merged recursively, null members delete, and anything else,
+// arrays included, replaces the target value.
+func MergePatch(target, patch *Node) error {
+	return Merge(target, patch, MergeOptions{NullDeletes: true})
+}
+
+// merge combines src into dst, which it may modify, and returns the result
+func (o MergeOptions) merge(path []string, dst, src *Value) (*Value, error) {
+	if dst == nil {
+		return o.prune(src.Clone()), nil
+	}
+
+	switch {
+	case dst.Type == TypeObject && src.Type == TypeObject:
+		return o.mergeMembers(path, dst, src)
+	case dst.Type == TypeArray && src.Type == TypeArray:
+		return o.mergeItems(path, dst, src)
+	}
+
+	if dst.Equal(src) {
+		return dst, nil
+	}
+	if o.ConflictFunc != nil {
+		resolved, err := o.ConflictFunc(FormatPointer(path), dst, src)
+		if err != nil {
+			return nil, fmt.Errorf("merge conflict at %q: %v", FormatPointer(path), err)
+		}
+		if resolved == nil {
+			return nil, fmt.Errorf("merge conflict at %q: resolved value is nil", FormatPointer(path))
+		}
+		if resolved == dst {
+			return dst, nil
+		}
+		return o.prune(resolved.Clone()), nil
+	}
+	return o.prune(src.Clone()), nil
+}
+
+// mergeMembers merges the members of src into the object dst
+func (o MergeOptions) mergeMembers(path []string, dst, src *Value) (*Value, error) {
+	for current := src.Node; current != nil; current = current.Next {
+		if current.Value == nil {
+			continue
+		}
+
+		existing, _, err := child(dst, current.Key)
+		if current.Value.Type == TypeNull && o.NullDeletes {
+			if err == nil {
+				unlink(dst, existing)
+			}
+			continue
+		}
+
+		if err != nil {
+			appendMember(dst, current.Key, o.prune(current.Value.Clone()))
+			continue
+		}
+
+		merged, err := o.merge(appendToken(path, current.Key), existing.Value, current.Value)
+		if err != nil {
+			return nil, err
+		}
+		existing.AddToValue(merged)
+	}
+	return dst, nil
+}
+
+// mergeItems combines the arrays dst and src according to the strategy
+func (o MergeOptions) mergeItems(path []string, dst, src *Value) (*Value, error) {
+	if !o.ArrayStrategy.append && o.ArrayStrategy.key == "" {
+		return src.Clone(), nil
+	}
+
+	for _, item := range src.Array {
+		elem := item.Elem()
+		if index := o.ArrayStrategy.match(dst, elem); index >= 0 {
+			merged, err := o.merge(appendToken(path, strconv.Itoa(index)), dst.Array[index].Elem(), elem)
+			if err != nil {
+				return nil, err
+			}
+			dst.Array[index] = NewItem(index, merged)
+			continue
+		}
+		dst.Array = append(dst.Array, NewItem(len(dst.Array), elem.Clone()))
+	}
+	return dst, nil
+}
+
+// match returns the index of the item in array that has the same merge key
+// as item, or -1 if the strategy does not merge by key or nothing matches
+func (s ArrayStrategy) match(array *Value, item *Value) int {
+	if s.key == "" || item == nil || item.Type != TypeObject {
+		return -1
+	}
+	_, want, err := child(item, s.key)
+	if err != nil {
+		return -1
+	}
+
+	for i, candidate := range array.Array {
+		elem := candidate.Elem()
+		if elem == nil || elem.Type != TypeObject {
+			continue
+		}
+		if _, got, err := child(elem, s.key); err == nil && got.Equal(want) {
+			return i
+		}
+	}
+	return -1
+}
+
+// prune removes null object members from v when nulls delete, so that
+// values added by a merge patch never carry deletion markers
+func (o MergeOptions) prune(v *Value) *Value {
+	if !o.NullDeletes || v == nil || v.Type != TypeObject {
+		return v
+	}
+	for current := v.Node; current != nil; {
+		next := current.Next
+		if current.Value == nil || current.Value.Type == TypeNull {
+			unlink(v, current)
+		} else {
+			o.prune(current.Value)
+		}
+		current = next
+	}
+	return v
+}
+
+// appendMember adds a member to the end of the object value v
+func appendMember(v *Value, key string, value *Value) {
+	member := &Node{Key: key}
+	member.AddToValue(value)
+
+	if v.Node == nil {
+		v.Node = member
+		return
+	}
+	last := v.Node
+	for last.Next != nil {
+		last = last.Next
+	}
+	member.Parent = last.Parent
+	last.Next = member
+	member.Prev = last
+}
@@ -0,0 +1,184 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Test cases from RFC 7386, Appendix A
+	tests := []struct {
+		target, patch, want any
+	}{
+		{map[string]any{"a": "b"}, map[string]any{"a": "c"}, map[string]any{"a": "c"}},
+		{map[string]any{"a": "b"}, map[string]any{"b": "c"}, map[string]any{"a": "b", "b": "c"}},
+		{map[string]any{"a": "b"}, map[string]any{"a": nil}, map[string]any{}},
+		{map[string]any{"a": "b", "b": "c"}, map[string]any{"a": nil}, map[string]any{"b": "c"}},
+		{map[string]any{"a": []any{"b"}}, map[string]any{"a": "c"}, map[string]any{"a": "c"}},
+		{map[string]any{"a": "c"}, map[string]any{"a": []any{"b"}}, map[string]any{"a": []any{"b"}}},
+		{
+			map[string]any{"a": map[string]any{"b": "c"}},
+			map[string]any{"a": map[string]any{"b": "d", "c": nil}},
+			map[string]any{"a": map[string]any{"b": "d"}},
+		},
+		{map[string]any{"a": []any{map[string]any{"b": "c"}}}, map[string]any{"a": []any{1}}, map[string]any{"a": []any{1}}},
+		{[]any{"a", "b"}, []any{"c", "d"}, []any{"c", "d"}},
+		{map[string]any{"a": "b"}, []any{"c"}, []any{"c"}},
+		{map[string]any{"a": "foo"}, nil, nil},
+		{map[string]any{"a": "foo"}, "bar", "bar"},
+		{map[string]any{"e": nil}, map[string]any{"a": 1}, map[string]any{"e": nil, "a": 1}},
+		{[]any{1, 2}, map[string]any{"a": "b", "c": nil}, map[string]any{"a": "b"}},
+		{map[string]any{}, map[string]any{"a": map[string]any{"bb": map[string]any{"ccc": nil}}}, map[string]any{"a": map[string]any{"bb": map[string]any{}}}},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			target, patch := newTree(tt.target), newTree(tt.patch)
+			if err := MergePatch(target, patch); err != nil {
+				t.Fatalf("MergePatch() error = %v", err)
+			}
+			if !target.Value.Equal(fromAny(tt.want)) {
+				t.Errorf("MergePatch() = %s, want %s", target, newTree(tt.want))
+			}
+			if !patch.Value.Equal(fromAny(tt.patch)) {
+				t.Errorf("MergePatch() modified the patch: %s", patch)
+			}
+			if err := target.Validate(); err != nil {
+				t.Errorf("Validate() after MergePatch() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := map[string]any{
+		"replicas": 1,
+		"labels":   map[string]any{"app": "web", "tier": "frontend"},
+		"args":     []any{"--verbose"},
+		"containers": []any{
+			map[string]any{"name": "web", "image": "nginx:1.24", "ports": []any{80}},
+			map[string]any{"name": "sidecar", "image": "envoy"},
+		},
+	}
+	overlay := map[string]any{
+		"replicas": 3,
+		"labels":   map[string]any{"tier": nil, "env": "prod"},
+		"args":     []any{"--debug"},
+		"containers": []any{
+			map[string]any{"name": "web", "image": "nginx:1.27"},
+			map[string]any{"name": "metrics", "image": "exporter"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		opts MergeOptions
+		want any
+	}{
+		{
+			name: "replace arrays, keep nulls",
+			opts: MergeOptions{},
+			want: map[string]any{
+				"replicas":   3,
+				"labels":     map[string]any{"app": "web", "tier": nil, "env": "prod"},
+				"args":       []any{"--debug"},
+				"containers": overlay["containers"],
+			},
+		},
+		{
+			name: "append arrays, nulls delete",
+			opts: MergeOptions{ArrayStrategy: ArrayAppend, NullDeletes: true},
+			want: map[string]any{
+				"replicas": 3,
+				"labels":   map[string]any{"app": "web", "env": "prod"},
+				"args":     []any{"--verbose", "--debug"},
+				"containers": []any{
+					map[string]any{"name": "web", "image": "nginx:1.24", "ports": []any{80}},
+					map[string]any{"name": "sidecar", "image": "envoy"},
+					map[string]any{"name": "web", "image": "nginx:1.27"},
+					map[string]any{"name": "metrics", "image": "exporter"},
+				},
+			},
+		},
+		{
+			name: "merge by key",
+			opts: MergeOptions{ArrayStrategy: MergeByKey("name"), NullDeletes: true},
+			want: map[string]any{
+				"replicas": 3,
+				"labels":   map[string]any{"app": "web", "env": "prod"},
+				"args":     []any{"--verbose", "--debug"},
+				"containers": []any{
+					map[string]any{"name": "web", "image": "nginx:1.27", "ports": []any{80}},
+					map[string]any{"name": "sidecar", "image": "envoy"},
+					map[string]any{"name": "metrics", "image": "exporter"},
+				},
+			},
+		},
+		{
+			name: "conflict keeps destination",
+			opts: MergeOptions{ConflictFunc: func(path string, dst, src *Value) (*Value, error) {
+				return dst, nil
+			}},
+			want: map[string]any{
+				"replicas":   1,
+				"labels":     map[string]any{"app": "web", "tier": "frontend", "env": "prod"},
+				"args":       []any{"--debug"},
+				"containers": overlay["containers"],
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := newTree(base)
+			if err := Merge(dst, newTree(overlay), tt.opts); err != nil {
+				t.Fatalf("Merge() error = %v", err)
+			}
+			if !dst.Value.Equal(fromAny(tt.want)) {
+				t.Errorf("Merge() = %s, want %s", dst, newTree(tt.want))
+			}
+			if err := dst.Validate(); err != nil {
+				t.Errorf("Validate() after Merge() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestMerge_ConflictPaths(t *testing.T) {
+	dst := newTree(map[string]any{"a": map[string]any{"b": 1, "c": "x"}, "list": []any{map[string]any{"k": "1", "v": true}}})
+	src := newTree(map[string]any{"a": map[string]any{"b": 2, "c": "x"}, "list": []any{map[string]any{"k": "1", "v": false}}})
+
+	var paths []string
+	err := Merge(dst, src, MergeOptions{
+		ArrayStrategy: MergeByKey("k"),
+		ConflictFunc: func(path string, dst, src *Value) (*Value, error) {
+			paths = append(paths, path)
+			return src, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(paths, ","); got != "/a/b,/list/0/v" {
+		t.Errorf("conflict paths = %s, want /a/b,/list/0/v", got)
+	}
+}
+
+func TestMerge_ConflictError(t *testing.T) {
+	dst := newTree(map[string]any{"a": 1, "b": 1})
+	src := newTree(map[string]any{"a": 2, "b": "x"})
+
+	err := Merge(dst, src, MergeOptions{ConflictFunc: func(path string, dst, src *Value) (*Value, error) {
+		if dst.Type != src.Type {
+			return nil, fmt.Errorf("type mismatch")
+		}
+		return src, nil
+	}})
+	if err == nil || !strings.Contains(err.Error(), `"/b"`) {
+		t.Fatalf("Merge() error = %v, want conflict at /b", err)
+	}
+	if !dst.Value.Equal(fromAny(map[string]any{"a": 1, "b": 1})) {
+		t.Errorf("Merge() modified dst on error: %s", dst)
+	}
+}
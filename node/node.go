@@ -17,12 +17,14 @@ type ValueType int
 // Constants representing different value types that can be stored in a node.
 // These types align with common data format types (JSON, XML, YAML) for easy conversion.
 const (
-	TypeNull    ValueType = iota // Represents a null/nil value
-	TypeObject                   // Represents an object/map type that can contain child nodes
-	TypeArray                    // Represents an array/slice type that can contain multiple values
-	TypeString                   // Represents a string value
-	TypeNumber                   // Represents a numeric value
-	TypeBoolean                  // Represents a boolean value
+	TypeNull      ValueType = iota // Represents a null/nil value
+	TypeObject                     // Represents an object/map type that can contain child nodes
+	TypeArray                      // Represents an array/slice type that can contain multiple values
+	TypeString                     // Represents a string value
+	TypeNumber                     // Represents a numeric value
+	TypeBoolean                    // Represents a boolean value
+	TypeTimestamp                  // Represents a date or time, with Worth holding its text, e.g. a YAML !!timestamp
+	TypeBinary                     // Represents binary data, with Worth holding it in base64, e.g. a YAML !!binary
 )
 
 // String returns the string representation of a ValueType.
@@ -42,6 +44,10 @@ func (t ValueType) String() string {
 		return "number"
 	case TypeBoolean:
 		return "boolean"
+	case TypeTimestamp:
+		return "timestamp"
+	case TypeBinary:
+		return "binary"
 	default:
 		return "unknown"
 	}
@@ -51,10 +57,31 @@ func (t ValueType) String() string {
 // It contains the type of the value and the actual data,
 // which can be a primitive value, an object (node), or an array.
 type Value struct {
-	Type  ValueType // The type of the value (null, object, array, string, number, boolean)
-	Worth string    // The actual value as a string (for primitive types)
-	Node  *Node     // Reference to a child node (for object types)
-	Array []*Value  // Array of values (for array types)
+	Type   ValueType  // The type of the value (null, object, array, string, number, boolean)
+	Worth  string     // The actual value as a string (for primitive types)
+	Node   *Node      // Reference to a child node (for object types)
+	Array  []*Value   // Array of values (for array types)
+	Kind   NumberKind // Kind of the number literal in Worth (for number types)
+	Anchor string     // Name of the anchor defined on the value, e.g. a YAML &anchor
+	Alias  string     // Name of the anchor the value refers to; it shares that value's content
+	Tag    string     // Explicit tag outside the core types, e.g. a YAML !Ref
+
+	Comments Comments // Comments attached to the value, e.g. after a YAML scalar or on a list item
+}
+
+// Comments holds the comments attached to a node or value by formats that
+// support them, without the comment markers' surrounding blank lines.
+// Head comments precede the entry, Line comments follow it on the same
+// line and Foot comments close it.
+type Comments struct {
+	Head string
+	Line string
+	Foot string
+}
+
+// IsZero reports whether no comment is set
+func (c Comments) IsZero() bool {
+	return c.Head == "" && c.Line == "" && c.Foot == ""
 }
 
 // Node represents a single node in the tree structure.
@@ -66,6 +93,8 @@ type Node struct {
 	Parent *Node  // Reference to the parent node
 	Next   *Node  // Reference to the next sibling node
 	Prev   *Node  // Reference to the previous sibling node
+
+	Comments Comments // Comments around the member, or around the document for a root node
 }
 
 // NewNode creates a new Node with the given key.
@@ -152,7 +181,7 @@ func (n *Node) AddToEnd(node *Node) error {
 
 // AddToValue adds a value to the current node.
 // This method is used to set or update the value of a node.
-// If the value contains a node, the parent reference of that node is updated.
+// If the value contains nodes, their parent references are updated.
 // Returns an error if either the current node or the value to add is nil.
 func (n *Node) AddToValue(value *Value) error {
 	if n == nil {
@@ -164,8 +193,9 @@ func (n *Node) AddToValue(value *Value) error {
 	}
 
 	n.Value = value
-	if value.Node != nil {
-		value.Node.Parent = n
+	// Objects hold members, scalars may hold XML attributes
+	for current := value.Node; current != nil; current = current.Next {
+		current.Parent = n
 	}
 
 	return nil
@@ -246,7 +276,7 @@ func (n *Node) print(indent int) {
 				item.Node.print(indent + 1)
 			} else {
 				switch item.Type {
-				case TypeString:
+				case TypeString, TypeTimestamp, TypeBinary:
 					fmt.Printf("%s  \"%s\"", indentStr, item.Worth)
 				case TypeNull:
 					fmt.Printf("%s  null", indentStr)
@@ -260,7 +290,7 @@ func (n *Node) print(indent int) {
 		}
 		fmt.Printf("\n%s]\n", indentStr)
 
-	case TypeString:
+	case TypeString, TypeTimestamp, TypeBinary:
 		fmt.Printf("\"%s\"\n", n.Value.Worth)
 
 	case TypeNumber:
@@ -406,35 +436,34 @@ func (n *Node) GetNode(key string) []*Node {
 }
 
 // GetNodeByPath searches for a node using a path-like key.
-// The path format is dot-separated, e.g., "root.child.grandchild".
-// It traverses the tree following the path components.
-// Returns the first matching node or nil if not found.
+// The path format is dot-separated, e.g., "root.child.grandchild", and starts
+// with the key of the node itself. Array items are addressed with [n] and keys
+// containing dots can be quoted, see ParsePath for the full syntax.
+// Returns the matching node itself, or nil if not found. Scalar array items
+// have no node of their own and cannot be returned; use GetByPath instead.
 func (n *Node) GetNodeByPath(path string) *Node {
 	if n == nil || path == "" {
 		return nil
 	}
 
-	parts := strings.Split(path, ".")
-	current := n
+	tokens, err := ParsePath(path)
+	if err != nil || len(tokens) == 0 {
+		return nil
+	}
 
-	for _, part := range parts {
-		found := false
-		for current != nil {
-			if current.Key == part {
-				found = true
-				if current.Value != nil && current.Value.Type == TypeObject {
-					current = current.Value.Node
-				}
-				break
-			}
-			current = current.Next
-		}
-		if !found {
-			return nil
-		}
+	current := n
+	for current != nil && current.Key != tokens[0] {
+		current = current.Next
+	}
+	if current == nil {
+		return nil
 	}
 
-	return current
+	owner, _, err := current.walk(tokens[1:])
+	if err != nil {
+		return nil
+	}
+	return owner
 }
 
 // FindNodes searches for nodes that match the given predicate function.
@@ -539,12 +568,19 @@ func (n *Node) Clone() *Node {
 		return nil
 	}
 	clone := &Node{
-		Key: n.Key,
+		Key:      n.Key,
+		Comments: n.Comments,
 	}
 	if n.Value != nil {
 		clone.Value = &Value{
-			Type:  n.Value.Type,
-			Worth: n.Value.Worth,
+			Type:   n.Value.Type,
+			Worth:  n.Value.Worth,
+			Kind:   n.Value.Kind,
+			Anchor: n.Value.Anchor,
+			Alias:  n.Value.Alias,
+			Tag:    n.Value.Tag,
+
+			Comments: n.Value.Comments,
 		}
 		if n.Value.Node != nil {
 			clone.Value.Node = n.Value.Node.Clone()
@@ -554,8 +590,14 @@ func (n *Node) Clone() *Node {
 			for i, v := range n.Value.Array {
 				if v != nil {
 					clone.Value.Array[i] = &Value{
-						Type:  v.Type,
-						Worth: v.Worth,
+						Type:   v.Type,
+						Worth:  v.Worth,
+						Kind:   v.Kind,
+						Anchor: v.Anchor,
+						Alias:  v.Alias,
+						Tag:    v.Tag,
+
+						Comments: v.Comments,
 					}
 					if v.Node != nil {
 						clone.Value.Array[i].Node = v.Node.Clone()
@@ -674,3 +716,131 @@ func (n *Node) Type() ValueType {
 	}
 	return n.Value.Type
 }
+
+// Equal reports whether two values hold the same data.
+// Unlike Node.Equal it ignores keys of the owning nodes and the order of
+// object members, except among members sharing a key, compares numbers by
+// their numeric value and looks through the wrapper nodes of object array
+// items.
+func (v *Value) Equal(other *Value) bool {
+	if v == nil || other == nil {
+		return v == other
+	}
+	if v.Type != other.Type || v.Tag != other.Tag {
+		return false
+	}
+
+	switch v.Type {
+	case TypeNull:
+		return true
+	case TypeNumber:
+		if v.Worth == other.Worth {
+			return true
+		}
+		cmp, ok := compareNumbers(v.Worth, other.Worth)
+		return ok && cmp == 0
+	case TypeObject:
+		return membersEqual(v.Node, other.Node)
+	case TypeArray:
+		if len(v.Array) != len(other.Array) {
+			return false
+		}
+		for i := range v.Array {
+			if !v.Array[i].Elem().Equal(other.Array[i].Elem()) {
+				return false
+			}
+		}
+		return true
+	default:
+		// XML-derived scalars can carry attributes as members
+		return v.Worth == other.Worth && membersEqual(v.Node, other.Node)
+	}
+}
+
+// membersEqual reports whether two member lists hold the same keys with
+// equal values, regardless of their order. Members sharing a key, as XML
+// and YAML trees may hold, are compared in their order.
+func membersEqual(a, b *Node) bool {
+	values := make(map[string][]*Value)
+	for current := b; current != nil; current = current.Next {
+		values[current.Key] = append(values[current.Key], current.Value)
+	}
+	for current := a; current != nil; current = current.Next {
+		rest := values[current.Key]
+		if len(rest) == 0 || !current.Value.Equal(rest[0]) {
+			return false
+		}
+		values[current.Key] = rest[1:]
+	}
+	for _, rest := range values {
+		if len(rest) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// memberID identifies an object member by its key and the number of
+// members with the same key before it, which pairs members sharing a key
+// by occurrence
+type memberID struct {
+	key        string
+	occurrence int
+}
+
+// indexMembers returns the members of an object by their identity, and
+// the identities in member order
+func indexMembers(v *Value) (map[memberID]*Node, []memberID) {
+	members := make(map[memberID]*Node)
+	var order []memberID
+	counts := make(map[string]int)
+	for current := v.Node; current != nil; current = current.Next {
+		id := memberID{key: current.Key, occurrence: counts[current.Key]}
+		counts[current.Key]++
+		members[id] = current
+		order = append(order, id)
+	}
+	return members, order
+}
+
+// Clone returns a deep copy of the value.
+// Members of a cloned object have no parent until the value is added to
+// a node with AddToValue; nested members point to their cloned owners.
+func (v *Value) Clone() *Value {
+	if v == nil {
+		return nil
+	}
+
+	clone := &Value{
+		Type:   v.Type,
+		Worth:  v.Worth,
+		Kind:   v.Kind,
+		Anchor: v.Anchor,
+		Alias:  v.Alias,
+		Tag:    v.Tag,
+
+		Comments: v.Comments,
+	}
+	if v.Array != nil {
+		clone.Array = make([]*Value, len(v.Array))
+		for i, item := range v.Array {
+			clone.Array[i] = item.Clone()
+		}
+	}
+
+	var last *Node
+	for current := v.Node; current != nil; current = current.Next {
+		member := &Node{Key: current.Key, Comments: current.Comments}
+		if current.Value != nil {
+			member.AddToValue(current.Value.Clone())
+		}
+		if last == nil {
+			clone.Node = member
+		} else {
+			last.Next = member
+			member.Prev = last
+		}
+		last = member
+	}
+	return clone
+}
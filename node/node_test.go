@@ -1317,3 +1317,115 @@ func TestNode_Type(t *testing.T) {
 		})
 	}
 }
+
+func TestValue_Equal(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *Value
+		other *Value
+		want  bool
+	}{
+		{
+			name:  "Same string",
+			value: &Value{Type: TypeString, Worth: "a"},
+			other: &Value{Type: TypeString, Worth: "a"},
+			want:  true,
+		},
+		{
+			name:  "Different types",
+			value: &Value{Type: TypeString, Worth: "1"},
+			other: &Value{Type: TypeNumber, Worth: "1"},
+			want:  false,
+		},
+		{
+			name:  "Numbers compared numerically",
+			value: &Value{Type: TypeNumber, Worth: "1.0"},
+			other: &Value{Type: TypeNumber, Worth: "1"},
+			want:  true,
+		},
+		{
+			name: "Objects ignore member order",
+			value: NewObject(
+				&Node{Key: "a", Value: NewNumber(1)},
+				&Node{Key: "b", Value: NewArray(NewString("x"), newTree(map[string]any{"c": true}).Value)},
+			),
+			other: NewObject(
+				&Node{Key: "b", Value: NewArray(NewString("x"), newTree(map[string]any{"c": true}).Value)},
+				&Node{Key: "a", Value: NewNumber(1)},
+			),
+			want: true,
+		},
+		{
+			name: "Duplicate keys compare in order",
+			value: NewObject(
+				&Node{Key: "a", Value: NewNumber(1)},
+				&Node{Key: "b", Value: NewNumber(2)},
+				&Node{Key: "a", Value: NewNumber(3)},
+			),
+			other: NewObject(
+				&Node{Key: "a", Value: NewNumber(1)},
+				&Node{Key: "a", Value: NewNumber(3)},
+				&Node{Key: "b", Value: NewNumber(2)},
+			),
+			want: true,
+		},
+		{
+			name: "Duplicate keys in another order",
+			value: NewObject(
+				&Node{Key: "a", Value: NewNumber(1)},
+				&Node{Key: "a", Value: NewNumber(3)},
+			),
+			other: NewObject(
+				&Node{Key: "a", Value: NewNumber(3)},
+				&Node{Key: "a", Value: NewNumber(1)},
+			),
+			want: false,
+		},
+		{
+			name: "Duplicate keys against a single one",
+			value: NewObject(
+				&Node{Key: "a", Value: NewNumber(1)},
+				&Node{Key: "a", Value: NewNumber(1)},
+				&Node{Key: "b", Value: NewNumber(2)},
+			),
+			other: NewObject(
+				&Node{Key: "a", Value: NewNumber(1)},
+				&Node{Key: "b", Value: NewNumber(2)},
+				&Node{Key: "c", Value: NewNumber(3)},
+			),
+			want: false,
+		},
+		{
+			name:  "Objects with extra member",
+			value: newTree(map[string]any{"a": 1}).Value,
+			other: newTree(map[string]any{"a": 1, "b": 2}).Value,
+			want:  false,
+		},
+		{
+			name:  "Arrays compare in order",
+			value: fromAny([]any{1, 2}),
+			other: fromAny([]any{2, 1}),
+			want:  false,
+		},
+		{
+			name:  "Nil values",
+			value: nil,
+			other: nil,
+			want:  true,
+		},
+		{
+			name:  "One nil value",
+			value: &Value{Type: TypeNull},
+			other: nil,
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.value.Equal(tt.other); got != tt.want {
+				t.Errorf("Value.Equal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
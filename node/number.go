@@ -0,0 +1,208 @@
+package node
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// NumberKind classifies the literal of a number value, so that numbers
+// which do not fit a float64 can be handled without losing precision
+type NumberKind int
+
+// Kinds of number literals
+const (
+	NumberUnknown NumberKind = iota // not classified, e.g. a Value built by hand
+	NumberInt                       // integer within the int64 range
+	NumberUint                      // integer beyond int64 but within uint64
+	NumberFloat                     // fraction or exponent that float64 represents faithfully
+	NumberDecimal                   // fraction or exponent that float64 would round
+	NumberBig                       // integer beyond the 64-bit ranges
+)
+
+// String returns the name of the number kind
+func (k NumberKind) String() string {
+	switch k {
+	case NumberInt:
+		return "int"
+	case NumberUint:
+		return "uint"
+	case NumberFloat:
+		return "float"
+	case NumberDecimal:
+		return "decimal"
+	case NumberBig:
+		return "big"
+	default:
+		return "unknown"
+	}
+}
+
+// IsNumberLiteral reports whether s is a number in JSON syntax, the form
+// in which number values are stored and written by all encoders
+func IsNumberLiteral(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	switch {
+	case i < len(s) && s[i] == '0':
+		i++
+	case i < len(s) && s[i] >= '1' && s[i] <= '9':
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	default:
+		return false
+	}
+
+	if i < len(s) && s[i] == '.' {
+		i++
+		if i == len(s) || !isDigit(s[i]) {
+			return false
+		}
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	}
+
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if i == len(s) || !isDigit(s[i]) {
+			return false
+		}
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	}
+
+	return i == len(s)
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// ClassifyNumber returns the kind of a number literal in JSON syntax,
+// or NumberUnknown if s is not one
+func ClassifyNumber(s string) NumberKind {
+	if !IsNumberLiteral(s) {
+		return NumberUnknown
+	}
+
+	if !strings.ContainsAny(s, ".eE") {
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return NumberInt
+		}
+		if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return NumberUint
+		}
+		return NumberBig
+	}
+
+	// A float is faithful when its shortest form denotes the same number
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return NumberDecimal
+	}
+	if cmp, ok := compareNumbers(s, strconv.FormatFloat(f, 'g', -1, 64)); !ok || cmp != 0 {
+		return NumberDecimal
+	}
+	return NumberFloat
+}
+
+// ParseNumber creates a number value from a literal in JSON syntax.
+// The literal is stored as it is, so it is written back unchanged.
+func ParseNumber(literal string) (*Value, error) {
+	kind := ClassifyNumber(literal)
+	if kind == NumberUnknown {
+		return nil, fmt.Errorf("invalid number %q", literal)
+	}
+	return &Value{Type: TypeNumber, Worth: literal, Kind: kind}, nil
+}
+
+// Uint64 returns a number value as uint64. Negative numbers, numbers with
+// a fractional part and numbers beyond the uint64 range are an error.
+func (v *Value) Uint64() (uint64, error) {
+	if err := v.expect(TypeNumber); err != nil {
+		return 0, err
+	}
+	if u, err := strconv.ParseUint(v.Worth, 10, 64); err == nil {
+		return u, nil
+	}
+	i, err := v.BigInt()
+	if err != nil || !i.IsUint64() {
+		return 0, fmt.Errorf("number %s is not a uint64", v.Worth)
+	}
+	return i.Uint64(), nil
+}
+
+// BigInt returns an integral number value of any size.
+// Numbers with a fractional part are an error.
+func (v *Value) BigInt() (*big.Int, error) {
+	r, err := v.Rat()
+	if err != nil {
+		return nil, err
+	}
+	if !r.IsInt() {
+		return nil, fmt.Errorf("number %s is not an integer", v.Worth)
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+// Rat returns a number value as an exact rational, which keeps decimal
+// amounts such as 0.10 free of binary rounding
+func (v *Value) Rat() (*big.Rat, error) {
+	if err := v.expect(TypeNumber); err != nil {
+		return nil, err
+	}
+	r, ok := parseRat(v.Worth)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", v.Worth)
+	}
+	return r, nil
+}
+
+// NumberKind returns the kind of a number value, classifying its literal
+// when the Kind field was not set by a decoder or constructor
+func (v *Value) NumberKind() NumberKind {
+	if v == nil || v.Type != TypeNumber {
+		return NumberUnknown
+	}
+	if v.Kind != NumberUnknown {
+		return v.Kind
+	}
+	return ClassifyNumber(v.Worth)
+}
+
+// compareNumbers compares two number literals exactly. ok is false if
+// either of them cannot be parsed.
+func compareNumbers(a, b string) (cmp int, ok bool) {
+	x, okA := parseRat(a)
+	y, okB := parseRat(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	return x.Cmp(y), true
+}
+
+// maxExponent bounds the exponent of literals parsed exactly, since
+// big.Rat expands 1e999999999 into a number with a billion digits
+const maxExponent = 4096
+
+// parseRat parses a number literal into an exact rational
+func parseRat(s string) (*big.Rat, bool) {
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil || exp > maxExponent || exp < -maxExponent {
+			return nil, false
+		}
+	}
+	return new(big.Rat).SetString(s)
+}
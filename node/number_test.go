@@ -0,0 +1,119 @@
+package node
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestClassifyNumber(t *testing.T) {
+	tests := []struct {
+		literal string
+		want    NumberKind
+	}{
+		{"0", NumberInt},
+		{"-42", NumberInt},
+		{"9007199254740993", NumberInt},
+		{"9223372036854775807", NumberInt},
+		{"9223372036854775808", NumberUint},
+		{"18446744073709551615", NumberUint},
+		{"18446744073709551616", NumberBig},
+		{"-9223372036854775809", NumberBig},
+		{"1.5", NumberFloat},
+		{"0.1", NumberFloat},
+		{"12.50", NumberFloat},
+		{"1e3", NumberFloat},
+		{"-2.5E-3", NumberFloat},
+		{"0.30000000000000000001", NumberDecimal},
+		{"123456789012345678.25", NumberDecimal},
+		{"1e400", NumberDecimal},
+		{"", NumberUnknown},
+		{"01", NumberUnknown},
+		{"+1", NumberUnknown},
+		{".5", NumberUnknown},
+		{"1.", NumberUnknown},
+		{"1e", NumberUnknown},
+		{"0x1F", NumberUnknown},
+		{"Inf", NumberUnknown},
+		{"NaN", NumberUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.literal, func(t *testing.T) {
+			if got := ClassifyNumber(tt.literal); got != tt.want {
+				t.Errorf("ClassifyNumber(%q) = %v, want %v", tt.literal, got, tt.want)
+			}
+			if got := IsNumberLiteral(tt.literal); got != (tt.want != NumberUnknown) {
+				t.Errorf("IsNumberLiteral(%q) = %v", tt.literal, got)
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	v, err := ParseNumber("9007199254740993")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Type != TypeNumber || v.Worth != "9007199254740993" || v.Kind != NumberInt {
+		t.Errorf("ParseNumber() = %+v", v)
+	}
+
+	if _, err := ParseNumber("0x10"); err == nil {
+		t.Error("ParseNumber(0x10) expected error")
+	}
+}
+
+func TestValue_BigNumbers(t *testing.T) {
+	big1 := &Value{Type: TypeNumber, Worth: "123456789012345678901234567890"}
+	if got := big1.NumberKind(); got != NumberBig {
+		t.Errorf("NumberKind() = %v, want big", got)
+	}
+	i, err := big1.BigInt()
+	if err != nil || i.String() != big1.Worth {
+		t.Errorf("BigInt() = %v, %v", i, err)
+	}
+	if _, err := big1.Int64(); err == nil {
+		t.Error("Int64() of a big number expected error")
+	}
+
+	u, err := (&Value{Type: TypeNumber, Worth: "18446744073709551615"}).Uint64()
+	if err != nil || u != 18446744073709551615 {
+		t.Errorf("Uint64() = %d, %v", u, err)
+	}
+	if _, err := NewNumber(-1).Uint64(); err == nil {
+		t.Error("Uint64() of a negative number expected error")
+	}
+
+	money := &Value{Type: TypeNumber, Worth: "0.10"}
+	r, err := money.Rat()
+	if err != nil || r.Cmp(big.NewRat(1, 10)) != 0 {
+		t.Errorf("Rat() = %v, %v", r, err)
+	}
+	if _, err := money.BigInt(); err == nil {
+		t.Error("BigInt() of a fraction expected error")
+	}
+	if _, err := (&Value{Type: TypeNumber, Worth: "1e999999999"}).Rat(); err == nil {
+		t.Error("Rat() with a huge exponent expected error")
+	}
+}
+
+func TestValue_EqualPrecision(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"9007199254740993", "9007199254740992", false},
+		{"9007199254740993", "9007199254740993.0", true},
+		{"0.1", "0.10", true},
+		{"1e2", "100", true},
+		{"0.30000000000000000001", "0.3", false},
+	}
+
+	for _, tt := range tests {
+		a := &Value{Type: TypeNumber, Worth: tt.a}
+		b := &Value{Type: TypeNumber, Worth: tt.b}
+		if got := a.Equal(b); got != tt.want {
+			t.Errorf("Equal(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RFC 6902 JSON Patch operation names
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpTest    = "test"
+)
+
+// Operation is a single RFC 6902 JSON Patch operation.
+// Path and From are JSON Pointers resolved against the value of the
+// patched node, like GetByPointer. Value is used by add, replace and test.
+type Operation struct {
+	Op    string
+	Path  string
+	From  string
+	Value *Value
+}
+
+// String returns a short description of the operation, used in errors
+func (op Operation) String() string {
+	switch op.Op {
+	case OpMove, OpCopy:
+		return fmt.Sprintf("%s %q to %q", op.Op, op.From, op.Path)
+	default:
+		return fmt.Sprintf("%s %q", op.Op, op.Path)
+	}
+}
+
+// ApplyPatch applies RFC 6902 operations to the value of root.
+// The operations are applied to a copy of the tree; only when all of them
+// succeed the copy replaces root.Value, so a failing operation (including
+// a failed test) leaves root untouched.
+func ApplyPatch(root *Node, ops []Operation) error {
+	if root == nil {
+		return fmt.Errorf("node is nil")
+	}
+
+	doc := &Node{Key: root.Key}
+	if root.Value != nil {
+		doc.AddToValue(root.Value.Clone())
+	} else {
+		doc.Value = &Value{Type: TypeNull}
+	}
+
+	for i, op := range ops {
+		if err := doc.apply(op); err != nil {
+			return fmt.Errorf("patch operation %d (%s): %v", i, op, err)
+		}
+	}
+
+	return root.AddToValue(doc.Value)
+}
+
+// apply performs a single operation on the value of n
+func (n *Node) apply(op Operation) error {
+	path, err := ParsePointer(op.Path)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case OpAdd:
+		if op.Value == nil {
+			return fmt.Errorf("missing value")
+		}
+		return n.add(path, op.Value.Clone())
+
+	case OpRemove:
+		_, err := n.remove(path)
+		return err
+
+	case OpReplace:
+		if op.Value == nil {
+			return fmt.Errorf("missing value")
+		}
+		if _, _, err := n.walk(path); err != nil {
+			return err
+		}
+		if len(path) == 0 {
+			return n.AddToValue(op.Value.Clone())
+		}
+		owner, container, err := n.walk(path[:len(path)-1])
+		if err != nil {
+			return err
+		}
+		return put(owner, container, path[len(path)-1], op.Value.Clone())
+
+	case OpMove:
+		from, err := ParsePointer(op.From)
+		if err != nil {
+			return err
+		}
+		if _, _, err := n.walk(from); err != nil {
+			return err
+		}
+		if op.From == op.Path {
+			return nil
+		}
+		if hasPrefix(path, from) {
+			return fmt.Errorf("cannot move a value into one of its children")
+		}
+		value, err := n.remove(from)
+		if err != nil {
+			return err
+		}
+		return n.add(path, value)
+
+	case OpCopy:
+		from, err := ParsePointer(op.From)
+		if err != nil {
+			return err
+		}
+		_, value, err := n.walk(from)
+		if err != nil {
+			return err
+		}
+		return n.add(path, value.Clone())
+
+	case OpTest:
+		_, value, err := n.walk(path)
+		if err != nil {
+			return err
+		}
+		if !value.Equal(op.Value) {
+			return fmt.Errorf("test failed")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// add inserts value at path. Unlike SetByPointer the parent must exist,
+// array items are inserted rather than replaced and "-" appends.
+func (n *Node) add(path []string, value *Value) error {
+	if len(path) == 0 {
+		return n.AddToValue(value)
+	}
+
+	owner, container, err := n.walk(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1]
+
+	if container == nil || container.Type != TypeArray {
+		return put(owner, container, last, value)
+	}
+
+	index, err := arrayIndex(last, len(container.Array))
+	if err != nil {
+		return err
+	}
+	container.Array = append(container.Array, nil)
+	copy(container.Array[index+1:], container.Array[index:])
+	container.Array[index] = NewItem(index, value)
+	renumber(container)
+	return nil
+}
+
+// remove deletes the value at path and returns it
+func (n *Node) remove(path []string) (*Value, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot remove the document root")
+	}
+
+	_, container, err := n.walk(path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	if container != nil && container.Type == TypeArray {
+		index, err := arrayIndex(last, len(container.Array))
+		if err != nil {
+			return nil, err
+		}
+		if index == len(container.Array) {
+			return nil, fmt.Errorf("index %s out of range", last)
+		}
+		item := container.Array[index]
+		container.Array = append(container.Array[:index], container.Array[index+1:]...)
+		renumber(container)
+		return item.Elem(), nil
+	}
+
+	member, value, err := child(container, last)
+	if err != nil {
+		return nil, err
+	}
+	unlink(container, member)
+	return value, nil
+}
+
+// unlink detaches member from the member list of container
+func unlink(container *Value, member *Node) {
+	if member.Prev != nil {
+		member.Prev.Next = member.Next
+	} else {
+		container.Node = member.Next
+	}
+	if member.Next != nil {
+		member.Next.Prev = member.Prev
+	}
+	member.Parent, member.Next, member.Prev = nil, nil, nil
+}
+
+// renumber keeps the "item<index>" keys of object item wrappers in line
+// with their positions after items were inserted or removed
+func renumber(array *Value) {
+	for i, item := range array.Array {
+		if item == nil || item.Node == nil || !strings.HasPrefix(item.Node.Key, "item") {
+			continue
+		}
+		if _, err := strconv.Atoi(item.Node.Key[len("item"):]); err == nil {
+			item.Node.Key = fmt.Sprintf("item%d", i)
+		}
+	}
+}
+
+// hasPrefix reports whether tokens is a proper descendant of prefix
+func hasPrefix(tokens, prefix []string) bool {
+	if len(tokens) <= len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if tokens[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CreatePatch returns the operations that turn the value of a into the
+// value of b. Members are compared by key regardless of order, and for
+// arrays the common leading and trailing items are skipped so that only
+// the changed range produces operations.
+func CreatePatch(a, b *Node) []Operation {
+	from, to := &Value{Type: TypeNull}, &Value{Type: TypeNull}
+	if a != nil && a.Value != nil {
+		from = a.Value
+	}
+	if b != nil && b.Value != nil {
+		to = b.Value
+	}
+	return diffValues(nil, from, to, nil)
+}
+
+// diffValues appends the operations turning from into to at path
+func diffValues(path []string, from, to *Value, ops []Operation) []Operation {
+	if from.Equal(to) {
+		return ops
+	}
+	if from == nil || from.Type != to.Type || from.Tag != to.Tag ||
+		(from.Type != TypeObject && from.Type != TypeArray) {
+		return append(ops, Operation{Op: OpReplace, Path: FormatPointer(path), Value: to.Clone()})
+	}
+
+	if from.Type == TypeObject {
+		return diffMembers(path, from, to, ops)
+	}
+	return diffItems(path, from, to, ops)
+}
+
+// diffMembers compares two objects member by member. Members sharing a
+// key are paired by occurrence; a pointer only addresses the first of
+// them, so objects whose later occurrences differ are replaced as a whole.
+func diffMembers(path []string, from, to *Value, ops []Operation) []Operation {
+	fromMembers, fromOrder := indexMembers(from)
+	toMembers, toOrder := indexMembers(to)
+	if !repeatsEqual(fromMembers, toMembers) || !repeatsEqual(toMembers, fromMembers) {
+		return append(ops, Operation{Op: OpReplace, Path: FormatPointer(path), Value: to.Clone()})
+	}
+
+	for _, id := range fromOrder {
+		if _, ok := toMembers[id]; !ok && id.occurrence == 0 {
+			ops = append(ops, Operation{Op: OpRemove, Path: FormatPointer(appendToken(path, id.key))})
+		}
+	}
+	for _, id := range toOrder {
+		if id.occurrence > 0 {
+			continue
+		}
+		current := toMembers[id]
+		memberPath := appendToken(path, id.key)
+		if old, ok := fromMembers[id]; ok {
+			ops = diffValues(memberPath, old.Value, current.Value, ops)
+			continue
+		}
+		ops = append(ops, Operation{Op: OpAdd, Path: FormatPointer(memberPath), Value: current.Value.Clone()})
+	}
+	return ops
+}
+
+// repeatsEqual reports whether every member of a that is not the first
+// with its key has an equal counterpart in b
+func repeatsEqual(a, b map[memberID]*Node) bool {
+	for id, member := range a {
+		if id.occurrence == 0 {
+			continue
+		}
+		other, ok := b[id]
+		if !ok || !member.Value.Equal(other.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// diffItems compares two arrays, skipping their common prefix and suffix
+func diffItems(path []string, from, to *Value, ops []Operation) []Operation {
+	start := 0
+	for start < len(from.Array) && start < len(to.Array) &&
+		from.Array[start].Elem().Equal(to.Array[start].Elem()) {
+		start++
+	}
+	endFrom, endTo := len(from.Array), len(to.Array)
+	for endFrom > start && endTo > start &&
+		from.Array[endFrom-1].Elem().Equal(to.Array[endTo-1].Elem()) {
+		endFrom--
+		endTo--
+	}
+
+	// Changed items are patched in place, the rest is removed or inserted
+	common := min(endFrom-start, endTo-start)
+	for i := start; i < start+common; i++ {
+		ops = diffValues(appendToken(path, strconv.Itoa(i)), from.Array[i].Elem(), to.Array[i].Elem(), ops)
+	}
+	for i := endFrom - 1; i >= start+common; i-- {
+		ops = append(ops, Operation{Op: OpRemove, Path: FormatPointer(appendToken(path, strconv.Itoa(i)))})
+	}
+	for i := start + common; i < endTo; i++ {
+		ops = append(ops, Operation{
+			Op:    OpAdd,
+			Path:  FormatPointer(appendToken(path, strconv.Itoa(i))),
+			Value: to.Array[i].Elem().Clone(),
+		})
+	}
+	return ops
+}
+
+// appendToken returns a copy of path extended with token
+func appendToken(path []string, token string) []string {
+	out := make([]string, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, token)
+}
+
+// PatchFromNode reads patch operations from a decoded patch document,
+// an array of objects with "op", "path", "from" and "value" members.
+// This lets patches be written in any supported format:
+//
+//	doc, _ := tjson.DecodeJson(data)
+//	ops, err := node.PatchFromNode(doc)
+func PatchFromNode(n *Node) ([]Operation, error) {
+	if n == nil || n.Value == nil || n.Value.Type != TypeArray {
+		return nil, fmt.Errorf("patch document must be an array")
+	}
+
+	ops := make([]Operation, 0, len(n.Value.Array))
+	for i, item := range n.Value.Array {
+		object := item.Elem()
+		if object == nil || object.Type != TypeObject {
+			return nil, fmt.Errorf("patch operation %d is not an object", i)
+		}
+
+		var op Operation
+		for current := object.Node; current != nil; current = current.Next {
+			switch current.Key {
+			case "op":
+				op.Op = current.Value.Worth
+			case "path":
+				op.Path = current.Value.Worth
+			case "from":
+				op.From = current.Value.Worth
+			case "value":
+				op.Value = current.Value
+			}
+		}
+
+		if op.Op == "" {
+			return nil, fmt.Errorf("patch operation %d has no op", i)
+		}
+		if _, _, err := child(object, "path"); err != nil {
+			return nil, fmt.Errorf("patch operation %d has no path", i)
+		}
+		switch op.Op {
+		case OpAdd, OpReplace, OpTest:
+			if op.Value == nil {
+				return nil, fmt.Errorf("patch operation %d (%s) has no value", i, op.Op)
+			}
+		case OpMove, OpCopy:
+			if _, _, err := child(object, "from"); err != nil {
+				return nil, fmt.Errorf("patch operation %d (%s) has no from", i, op.Op)
+			}
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// PatchToNode builds a patch document from operations, the inverse of
+// PatchFromNode, so that a patch can be encoded with any format encoder.
+func PatchToNode(ops []Operation) *Node {
+	root := NewNode("root")
+	array := &Value{Type: TypeArray, Array: make([]*Value, 0, len(ops))}
+
+	for i, op := range ops {
+		item := NewNode(fmt.Sprintf("item%d", i))
+		item.Value = &Value{Type: TypeObject}
+		addString := func(key, worth string) {
+			member := NewNode(key)
+			member.Value = &Value{Type: TypeString, Worth: worth}
+			item.AddToEnd(member)
+		}
+
+		addString("op", op.Op)
+		if op.Op == OpMove || op.Op == OpCopy {
+			addString("from", op.From)
+		}
+		addString("path", op.Path)
+		if op.Value != nil {
+			member := NewNode("value")
+			member.AddToValue(op.Value.Clone())
+			item.AddToEnd(member)
+		}
+		array.Array = append(array.Array, &Value{Type: TypeObject, Node: item})
+	}
+
+	root.AddToValue(array)
+	return root
+}
@@ -0,0 +1,348 @@
+package node
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     any
+		ops     []Operation
+		want    any
+		wantErr bool
+	}{
+		{
+			name: "add member",
+			doc:  map[string]any{"foo": "bar"},
+			ops:  []Operation{{Op: OpAdd, Path: "/baz", Value: fromAny("qux")}},
+			want: map[string]any{"foo": "bar", "baz": "qux"},
+		},
+		{
+			name: "add replaces existing member",
+			doc:  map[string]any{"foo": "bar"},
+			ops:  []Operation{{Op: OpAdd, Path: "/foo", Value: fromAny(1)}},
+			want: map[string]any{"foo": 1},
+		},
+		{
+			name: "add inserts array item",
+			doc:  map[string]any{"foo": []any{"bar", "baz"}},
+			ops:  []Operation{{Op: OpAdd, Path: "/foo/1", Value: fromAny("qux")}},
+			want: map[string]any{"foo": []any{"bar", "qux", "baz"}},
+		},
+		{
+			name: "add appends with dash",
+			doc:  map[string]any{"foo": []any{"bar"}},
+			ops:  []Operation{{Op: OpAdd, Path: "/foo/-", Value: fromAny(map[string]any{"a": 1})}},
+			want: map[string]any{"foo": []any{"bar", map[string]any{"a": 1}}},
+		},
+		{
+			name: "add replaces document",
+			doc:  map[string]any{"foo": "bar"},
+			ops:  []Operation{{Op: OpAdd, Path: "", Value: fromAny([]any{1})}},
+			want: []any{1},
+		},
+		{
+			name:    "add requires parent",
+			doc:     map[string]any{"foo": "bar"},
+			ops:     []Operation{{Op: OpAdd, Path: "/missing/baz", Value: fromAny(1)}},
+			wantErr: true,
+		},
+		{
+			name:    "add beyond array length",
+			doc:     map[string]any{"foo": []any{"bar"}},
+			ops:     []Operation{{Op: OpAdd, Path: "/foo/2", Value: fromAny(1)}},
+			wantErr: true,
+		},
+		{
+			name: "remove member",
+			doc:  map[string]any{"foo": "bar", "baz": "qux"},
+			ops:  []Operation{{Op: OpRemove, Path: "/baz"}},
+			want: map[string]any{"foo": "bar"},
+		},
+		{
+			name: "remove array item",
+			doc:  map[string]any{"foo": []any{"bar", "qux", "baz"}},
+			ops:  []Operation{{Op: OpRemove, Path: "/foo/1"}},
+			want: map[string]any{"foo": []any{"bar", "baz"}},
+		},
+		{
+			name:    "remove missing member",
+			doc:     map[string]any{"foo": "bar"},
+			ops:     []Operation{{Op: OpRemove, Path: "/baz"}},
+			wantErr: true,
+		},
+		{
+			name: "replace member",
+			doc:  map[string]any{"foo": "bar", "baz": "qux"},
+			ops:  []Operation{{Op: OpReplace, Path: "/baz", Value: fromAny("boo")}},
+			want: map[string]any{"foo": "bar", "baz": "boo"},
+		},
+		{
+			name: "replace array item",
+			doc:  map[string]any{"foo": []any{1, 2}},
+			ops:  []Operation{{Op: OpReplace, Path: "/foo/0", Value: fromAny(map[string]any{"a": 1})}},
+			want: map[string]any{"foo": []any{map[string]any{"a": 1}, 2}},
+		},
+		{
+			name:    "replace missing member",
+			doc:     map[string]any{"foo": "bar"},
+			ops:     []Operation{{Op: OpReplace, Path: "/baz", Value: fromAny(1)}},
+			wantErr: true,
+		},
+		{
+			name: "move member",
+			doc:  map[string]any{"foo": map[string]any{"bar": "baz", "waldo": "fred"}, "qux": map[string]any{"corge": "grault"}},
+			ops:  []Operation{{Op: OpMove, From: "/foo/waldo", Path: "/qux/thud"}},
+			want: map[string]any{"foo": map[string]any{"bar": "baz"}, "qux": map[string]any{"corge": "grault", "thud": "fred"}},
+		},
+		{
+			name: "move array item",
+			doc:  map[string]any{"foo": []any{"all", "grass", "cows", "eat"}},
+			ops:  []Operation{{Op: OpMove, From: "/foo/1", Path: "/foo/3"}},
+			want: map[string]any{"foo": []any{"all", "cows", "eat", "grass"}},
+		},
+		{
+			name:    "move into own child",
+			doc:     map[string]any{"foo": map[string]any{"bar": 1}},
+			ops:     []Operation{{Op: OpMove, From: "/foo", Path: "/foo/bar/baz"}},
+			wantErr: true,
+		},
+		{
+			name: "copy member",
+			doc:  map[string]any{"foo": map[string]any{"bar": 1}},
+			ops:  []Operation{{Op: OpCopy, From: "/foo", Path: "/baz"}},
+			want: map[string]any{"foo": map[string]any{"bar": 1}, "baz": map[string]any{"bar": 1}},
+		},
+		{
+			name: "test passes",
+			doc:  map[string]any{"baz": "qux", "foo": []any{"a", 2, "c"}},
+			ops: []Operation{
+				{Op: OpTest, Path: "/baz", Value: fromAny("qux")},
+				{Op: OpTest, Path: "/foo/1", Value: fromAny(2.0)},
+			},
+			want: map[string]any{"baz": "qux", "foo": []any{"a", 2, "c"}},
+		},
+		{
+			name:    "test fails",
+			doc:     map[string]any{"baz": "qux"},
+			ops:     []Operation{{Op: OpTest, Path: "/baz", Value: fromAny("bar")}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown operation",
+			doc:     map[string]any{"baz": "qux"},
+			ops:     []Operation{{Op: "merge", Path: "/baz"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := newTree(tt.doc)
+			err := ApplyPatch(root, tt.ops)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyPatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := fromAny(tt.want)
+			if tt.wantErr {
+				want = fromAny(tt.doc)
+			}
+			if !root.Value.Equal(want) {
+				t.Errorf("ApplyPatch() result = %s, want %s", root, newTree(tt.want))
+			}
+			if err := root.Validate(); err != nil {
+				t.Errorf("Validate() after ApplyPatch() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyPatch_Atomic(t *testing.T) {
+	root := podTree()
+	before := root.Value
+	ops := []Operation{
+		{Op: OpReplace, Path: "/kind", Value: fromAny("Deployment")},
+		{Op: OpRemove, Path: "/spec/containers/0"},
+		{Op: OpTest, Path: "/kind", Value: fromAny("Pod")},
+	}
+
+	err := ApplyPatch(root, ops)
+	if err == nil {
+		t.Fatal("ApplyPatch() expected error")
+	}
+	if !strings.Contains(err.Error(), "patch operation 2") {
+		t.Errorf("ApplyPatch() error = %v, want it to name operation 2", err)
+	}
+	if root.Value != before || !root.Value.Equal(podTree().Value) {
+		t.Errorf("ApplyPatch() modified the tree on failure: %s", root)
+	}
+}
+
+func TestApplyPatch_ItemKeys(t *testing.T) {
+	root := newTree(map[string]any{"list": []any{map[string]any{"a": 1}, map[string]any{"b": 2}}})
+	ops := []Operation{
+		{Op: OpAdd, Path: "/list/0", Value: fromAny(map[string]any{"c": 3})},
+		{Op: OpRemove, Path: "/list/2"},
+	}
+	if err := ApplyPatch(root, ops); err != nil {
+		t.Fatal(err)
+	}
+
+	list, _ := root.GetByPointer("/list")
+	var keys []string
+	for _, item := range list.Array {
+		keys = append(keys, item.Node.Key)
+	}
+	if want := []string{"item0", "item1"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("item keys = %v, want %v", keys, want)
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b any
+		want []string
+	}{
+		{
+			name: "equal",
+			a:    map[string]any{"a": 1, "b": []any{1, 2}},
+			b:    map[string]any{"b": []any{1, 2}, "a": 1.0},
+			want: nil,
+		},
+		{
+			name: "members",
+			a:    map[string]any{"keep": 1, "drop": 2, "change": "x"},
+			b:    map[string]any{"keep": 1, "change": "y", "new": true},
+			want: []string{`remove "/drop"`, `replace "/change"`, `add "/new"`},
+		},
+		{
+			name: "nested",
+			a:    map[string]any{"spec": map[string]any{"replicas": 1, "image": "nginx"}},
+			b:    map[string]any{"spec": map[string]any{"replicas": 3, "image": "nginx"}},
+			want: []string{`replace "/spec/replicas"`},
+		},
+		{
+			name: "array insert",
+			a:    []any{1, 2, 5},
+			b:    []any{1, 2, 3, 4, 5},
+			want: []string{`add "/2"`, `add "/3"`},
+		},
+		{
+			name: "array remove",
+			a:    []any{1, 2, 3, 4, 5},
+			b:    []any{1, 5},
+			want: []string{`remove "/3"`, `remove "/2"`, `remove "/1"`},
+		},
+		{
+			name: "array item change",
+			a:    []any{map[string]any{"name": "a", "port": 80}, "x"},
+			b:    []any{map[string]any{"name": "a", "port": 8080}, "x"},
+			want: []string{`replace "/0/port"`},
+		},
+		{
+			name: "type change",
+			a:    map[string]any{"a": []any{1}},
+			b:    map[string]any{"a": map[string]any{"b": 1}},
+			want: []string{`replace "/a"`},
+		},
+		{
+			name: "escaped keys",
+			a:    map[string]any{"a/b": 1},
+			b:    map[string]any{"a/b": 2},
+			want: []string{`replace "/a~1b"`},
+		},
+		{
+			// Mixed content as the lossless XML decoder keeps it: <a>x<b/>y</a>
+			name: "repeated key changed",
+			a:    map[string]any{"a": keyedTree("#text", "x", "b", nil, "#text", "y").Value},
+			b:    map[string]any{"a": keyedTree("#text", "x", "b", nil, "#text", "z").Value},
+			want: []string{`replace "/a"`},
+		},
+		{
+			name: "repeated key added",
+			a:    map[string]any{"a": keyedTree("#text", "x", "b", nil).Value},
+			b:    map[string]any{"a": keyedTree("#text", "x", "b", nil, "#text", "y").Value},
+			want: []string{`replace "/a"`},
+		},
+		{
+			name: "tag changed",
+			a:    map[string]any{"x": tagged("!foo", map[string]any{"k": 1}), "y": tagged("!seq", []any{1})},
+			b:    map[string]any{"x": tagged("!bar", map[string]any{"k": 1}), "y": []any{1}},
+			want: []string{`replace "/x"`, `replace "/y"`},
+		},
+		{
+			name: "first of repeated keys changed",
+			a:    keyedTree("#text", "x", "b", 1, "#text", "y").Value,
+			b:    keyedTree("#text", "w", "b", 2, "#text", "y").Value,
+			want: []string{`replace "/#text"`, `replace "/b"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := newTree(tt.a), newTree(tt.b)
+			ops := CreatePatch(a, b)
+
+			var got []string
+			for _, op := range ops {
+				got = append(got, op.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CreatePatch() = %v, want %v", got, tt.want)
+			}
+
+			if err := ApplyPatch(a, ops); err != nil {
+				t.Fatalf("ApplyPatch(CreatePatch()) error = %v", err)
+			}
+			if !a.Value.Equal(b.Value) {
+				t.Errorf("ApplyPatch(CreatePatch()) = %s, want %s", a, b)
+			}
+		})
+	}
+}
+
+// tagged returns the value of data carrying an explicit tag
+func tagged(tag string, data any) *Value {
+	v := fromAny(data)
+	v.Tag = tag
+	return v
+}
+
+func TestPatchFromNode(t *testing.T) {
+	ops := []Operation{
+		{Op: OpAdd, Path: "/a", Value: fromAny(map[string]any{"b": 1})},
+		{Op: OpMove, From: "/a", Path: "/c"},
+		{Op: OpRemove, Path: "/c/b"},
+	}
+
+	got, err := PatchFromNode(PatchToNode(ops))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(ops) {
+		t.Fatalf("PatchFromNode() returned %d operations, want %d", len(got), len(ops))
+	}
+	for i := range ops {
+		if got[i].String() != ops[i].String() || !got[i].Value.Equal(ops[i].Value) {
+			t.Errorf("operation %d = %v, want %v", i, got[i], ops[i])
+		}
+	}
+
+	invalid := []any{
+		map[string]any{"op": "add"},
+		[]any{"add"},
+		[]any{map[string]any{"path": "/a"}},
+		[]any{map[string]any{"op": "add", "path": "/a"}},
+		[]any{map[string]any{"op": "move", "path": "/a"}},
+	}
+	for _, doc := range invalid {
+		if _, err := PatchFromNode(newTree(doc)); err == nil {
+			t.Errorf("PatchFromNode(%s) expected error", newTree(doc))
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParsePointer splits an RFC 6901 JSON Pointer into its reference tokens.
+// The empty pointer refers to the whole document and yields no tokens.
+// The escape sequences "~1" and "~0" are decoded to "/" and "~".
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with /", pointer)
+	}
+
+	parts := strings.Split(pointer[1:], "/")
+	for i, part := range parts {
+		for j := 0; j < len(part); j++ {
+			if part[j] == '~' && (j+1 == len(part) || (part[j+1] != '0' && part[j+1] != '1')) {
+				return nil, fmt.Errorf("invalid JSON pointer %q: bad escape in %q", pointer, part)
+			}
+		}
+		part = strings.ReplaceAll(part, "~1", "/")
+		parts[i] = strings.ReplaceAll(part, "~0", "~")
+	}
+	return parts, nil
+}
+
+// FormatPointer builds an RFC 6901 JSON Pointer from reference tokens,
+// escaping "~" and "/" inside the tokens.
+func FormatPointer(tokens []string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteByte('/')
+		token = strings.ReplaceAll(token, "~", "~0")
+		b.WriteString(strings.ReplaceAll(token, "/", "~1"))
+	}
+	return b.String()
+}
+
+// ParsePath splits a dotted path into its tokens.
+// Besides plain dot-separated keys the syntax supports array indices
+// and quoted segments for keys that contain dots or brackets:
+//
+//	spec.containers[0].image
+//	metadata.labels."app.kubernetes.io/name"
+//	metadata.annotations['example.com/owner']
+func ParsePath(path string) ([]string, error) {
+	var tokens []string
+	i := 0
+	expectKey := true // a key may start at the beginning and after a dot
+
+	for i < len(path) {
+		switch c := path[i]; {
+		case c == '.':
+			if expectKey {
+				return nil, fmt.Errorf("invalid path %q: empty segment at %d", path, i)
+			}
+			expectKey = true
+			i++
+
+		case c == '[':
+			end := i + 1
+			if end < len(path) && (path[end] == '"' || path[end] == '\'') {
+				key, n, err := unquote(path[end:])
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: %v", path, err)
+				}
+				end += n
+				if end >= len(path) || path[end] != ']' {
+					return nil, fmt.Errorf("invalid path %q: missing ] at %d", path, end)
+				}
+				tokens = append(tokens, key)
+			} else {
+				length := strings.IndexByte(path[end:], ']')
+				if length < 0 {
+					return nil, fmt.Errorf("invalid path %q: missing ] at %d", path, i)
+				}
+				index := path[end : end+length]
+				if _, err := strconv.Atoi(index); err != nil && index != "-" {
+					return nil, fmt.Errorf("invalid path %q: bad array index %q", path, index)
+				}
+				end += length
+				tokens = append(tokens, index)
+			}
+			expectKey = false
+			i = end + 1
+
+		case c == '"' || c == '\'':
+			if !expectKey {
+				return nil, fmt.Errorf("invalid path %q: unexpected quote at %d", path, i)
+			}
+			key, n, err := unquote(path[i:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %v", path, err)
+			}
+			tokens = append(tokens, key)
+			expectKey = false
+			i += n
+
+		default:
+			if !expectKey {
+				return nil, fmt.Errorf("invalid path %q: unexpected %q at %d", path, c, i)
+			}
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			tokens = append(tokens, path[i:end])
+			expectKey = false
+			i = end
+		}
+	}
+
+	if expectKey && len(path) > 0 {
+		return nil, fmt.Errorf("invalid path %q: trailing dot", path)
+	}
+	return tokens, nil
+}
+
+// unquote reads a quoted segment at the start of s and returns its content
+// and the number of bytes consumed. A backslash escapes the next character.
+func unquote(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 == len(s) {
+				return "", 0, fmt.Errorf("unterminated escape")
+			}
+			i++
+			b.WriteByte(s[i])
+		case quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted segment")
+}
+
+// GetByPointer returns the value addressed by an RFC 6901 JSON Pointer,
+// e.g. "/spec/containers/0/image". The pointer is resolved against the
+// value of n, so the empty pointer returns n.Value itself.
+func (n *Node) GetByPointer(pointer string) (*Value, error) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return n.getByTokens(tokens)
+}
+
+// SetByPointer stores value at the location addressed by an RFC 6901 JSON
+// Pointer. Missing intermediate objects and arrays are created: a missing
+// container becomes an array when the next token is an index or "-" and an
+// object otherwise. The last token may name a new object member, an
+// existing array index, or "-" (or the array length) to append.
+func (n *Node) SetByPointer(pointer string, value *Value) error {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	return n.setByTokens(tokens, value)
+}
+
+// GetByPath returns the value addressed by a dotted path as accepted by
+// ParsePath, e.g. "spec.containers[0].image". Like GetByPointer, the path
+// is resolved against the value of n and does not include n's own key.
+func (n *Node) GetByPath(path string) (*Value, error) {
+	tokens, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return n.getByTokens(tokens)
+}
+
+// SetByPath stores value at the location addressed by a dotted path,
+// creating intermediate objects and arrays like SetByPointer.
+func (n *Node) SetByPath(path string, value *Value) error {
+	tokens, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+	return n.setByTokens(tokens, value)
+}
+
+// Elem returns the content of an array item.
+// Object items, and scalars holding XML attribute members, are stored
+// behind a wrapper node ("item0", "item1", ...), Elem unwraps them; any
+// other item is returned as it is.
+func (v *Value) Elem() *Value {
+	if v != nil && v.Node != nil && v.Node.Value != nil {
+		return v.Node.Value
+	}
+	return v
+}
+
+// NewItem prepares a value for storage in an array at the given index.
+// Objects, and scalars holding XML attribute members, are wrapped in an
+// "item<index>" node so that their members keep a parent, which is how
+// the format decoders store them.
+func NewItem(index int, value *Value) *Value {
+	if value == nil || (value.Type != TypeObject && value.Node == nil) {
+		return value
+	}
+	wrapper := &Node{Key: fmt.Sprintf("item%d", index)}
+	wrapper.AddToValue(value)
+	return &Value{
+		Type: value.Type,
+		Node: wrapper,
+	}
+}
+
+// keepComments carries the comments of a replaced value over to the value
+// replacing it, unless that one has comments of its own
+func keepComments(old, value *Value) {
+	if old != nil && value.Comments.IsZero() {
+		value.Comments = old.Comments
+	}
+}
+
+// getByTokens resolves reference tokens against the value of n
+func (n *Node) getByTokens(tokens []string) (*Value, error) {
+	if n == nil {
+		return nil, fmt.Errorf("node is nil")
+	}
+
+	_, current, err := n.walk(tokens)
+	return current, err
+}
+
+// walk resolves tokens against the value of n and returns the value found
+// together with the node owning it (nil for scalar array items)
+func (n *Node) walk(tokens []string) (*Node, *Value, error) {
+	owner, current := n, n.Value
+	for i, token := range tokens {
+		var err error
+		owner, current, err = child(current, token)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", FormatPointer(tokens[:i+1]), err)
+		}
+	}
+	return owner, current, nil
+}
+
+// child returns the member or item of v addressed by token and its owner node
+func child(v *Value, token string) (*Node, *Value, error) {
+	if v == nil {
+		return nil, nil, fmt.Errorf("value is nil")
+	}
+
+	switch {
+	case v.Type == TypeObject || (v.Type != TypeArray && v.Node != nil):
+		// XML-derived scalars keep their attributes as members
+		for current := v.Node; current != nil; current = current.Next {
+			if current.Key == token {
+				return current, current.Value, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("key %q not found", token)
+
+	case v.Type == TypeArray:
+		index, err := arrayIndex(token, len(v.Array))
+		if err != nil {
+			return nil, nil, err
+		}
+		if index == len(v.Array) {
+			return nil, nil, fmt.Errorf("index %s out of range", token)
+		}
+		item := v.Array[index]
+		if item != nil && item.Node != nil {
+			return item.Node, item.Elem(), nil
+		}
+		return nil, item, nil
+
+	default:
+		return nil, nil, fmt.Errorf("cannot step into %s value", v.Type)
+	}
+}
+
+// arrayIndex parses an array index token. "-" refers to the position
+// after the last element and is returned as length.
+func arrayIndex(token string, length int) (int, error) {
+	if token == "-" {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if index > length {
+		return 0, fmt.Errorf("index %d out of range", index)
+	}
+	return index, nil
+}
+
+// isIndexToken reports whether token addresses an array position
+func isIndexToken(token string) bool {
+	if token == "-" {
+		return true
+	}
+	_, err := arrayIndex(token, int(^uint(0)>>1))
+	return err == nil
+}
+
+// setByTokens stores value at the location addressed by tokens,
+// creating missing intermediate containers. The path is checked first,
+// so the tree is left unchanged when the call fails.
+func (n *Node) setByTokens(tokens []string, value *Value) error {
+	if n == nil {
+		return fmt.Errorf("node is nil")
+	}
+	if value == nil {
+		return fmt.Errorf("value to set is nil")
+	}
+	if len(tokens) == 0 {
+		return n.AddToValue(value)
+	}
+	if err := checkTokens(n, n.Value, tokens); err != nil {
+		return err
+	}
+
+	owner, current := n, n.Value
+	for i, token := range tokens {
+		if current != nil && current.Type == TypeNull && owner != nil {
+			// A null value can be turned into the container we need
+			if isIndexToken(token) {
+				current.Type, current.Array = TypeArray, []*Value{}
+			} else {
+				current.Type = TypeObject
+			}
+		}
+
+		if i == len(tokens)-1 {
+			if err := put(owner, current, token, value); err != nil {
+				return fmt.Errorf("%s: %v", FormatPointer(tokens), err)
+			}
+			return nil
+		}
+
+		nextOwner, next, err := child(current, token)
+		if err != nil {
+			// Create the missing container, based on the token that follows
+			container := &Value{Type: TypeObject}
+			if isIndexToken(tokens[i+1]) {
+				container = &Value{Type: TypeArray, Array: []*Value{}}
+			}
+			if err := put(owner, current, token, container); err != nil {
+				return fmt.Errorf("%s: %v", FormatPointer(tokens[:i+1]), err)
+			}
+			if token == "-" {
+				token = strconv.Itoa(len(current.Array) - 1)
+			}
+			if nextOwner, next, err = child(current, token); err != nil {
+				return fmt.Errorf("%s: %v", FormatPointer(tokens[:i+1]), err)
+			}
+		}
+		owner, current = nextOwner, next
+	}
+	return nil
+}
+
+// checkTokens returns the error setByTokens would fail with when walking
+// tokens from current, held by owner, without changing anything
+func checkTokens(owner *Node, current *Value, tokens []string) error {
+	for i, token := range tokens {
+		if current != nil && current.Type == TypeNull && owner != nil {
+			current = newContainer(token)
+		}
+
+		if i == len(tokens)-1 {
+			if err := checkPut(owner, current, token); err != nil {
+				return fmt.Errorf("%s: %v", FormatPointer(tokens), err)
+			}
+			return nil
+		}
+
+		nextOwner, next, err := child(current, token)
+		if err != nil {
+			// Everything below a missing container is created empty
+			if err := checkPut(owner, current, token); err != nil {
+				return fmt.Errorf("%s: %v", FormatPointer(tokens[:i+1]), err)
+			}
+			nextOwner, next = NewNode(token), newContainer(tokens[i+1])
+		}
+		owner, current = nextOwner, next
+	}
+	return nil
+}
+
+// newContainer returns the empty container a token steps into, an array
+// for index tokens and an object otherwise
+func newContainer(token string) *Value {
+	if isIndexToken(token) {
+		return &Value{Type: TypeArray, Array: []*Value{}}
+	}
+	return &Value{Type: TypeObject}
+}
+
+// checkPut returns the error put would fail with
+func checkPut(owner *Node, container *Value, token string) error {
+	if container == nil {
+		return fmt.Errorf("value is nil")
+	}
+
+	switch container.Type {
+	case TypeObject:
+		if owner == nil {
+			return fmt.Errorf("object has no owner node")
+		}
+		return nil
+	case TypeArray:
+		_, err := arrayIndex(token, len(container.Array))
+		return err
+	default:
+		return fmt.Errorf("cannot set member of %s value", container.Type)
+	}
+}
+
+// put stores value as the member or item of container addressed by token
+func put(owner *Node, container *Value, token string, value *Value) error {
+	if err := checkPut(owner, container, token); err != nil {
+		return err
+	}
+
+	switch container.Type {
+	case TypeObject:
+		for current := container.Node; current != nil; current = current.Next {
+			if current.Key == token {
+				keepComments(current.Value, value)
+				return current.AddToValue(value)
+			}
+		}
+		member := NewNode(token)
+		if err := member.AddToValue(value); err != nil {
+			return err
+		}
+		return owner.AddToEnd(member)
+
+	case TypeArray:
+		index, err := arrayIndex(token, len(container.Array))
+		if err != nil {
+			return err
+		}
+		if index == len(container.Array) {
+			container.Array = append(container.Array, NewItem(index, value))
+		} else {
+			keepComments(container.Array[index].Elem(), value)
+			container.Array[index] = NewItem(index, value)
+		}
+		return nil
+	}
+	return nil
+}
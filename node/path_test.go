@@ -0,0 +1,367 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// fromAny builds a value tree from Go literals the way the format decoders do.
+// Object keys are sorted, so use keyed helpers when order matters.
+func fromAny(data any) *Value {
+	switch v := data.(type) {
+	case nil:
+		return &Value{Type: TypeNull}
+	case *Value:
+		return v
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		holder := NewNode("holder")
+		holder.Value = &Value{Type: TypeObject}
+		for _, k := range keys {
+			member := NewNode(k)
+			member.AddToValue(fromAny(v[k]))
+			holder.AddToEnd(member)
+		}
+		return holder.Value
+	case []any:
+		arr := &Value{Type: TypeArray, Array: []*Value{}}
+		for i, item := range v {
+			arr.Array = append(arr.Array, NewItem(i, fromAny(item)))
+		}
+		return arr
+	case string:
+		return &Value{Type: TypeString, Worth: v}
+	case int:
+		return &Value{Type: TypeNumber, Worth: strconv.Itoa(v)}
+	case float64:
+		return &Value{Type: TypeNumber, Worth: strconv.FormatFloat(v, 'f', -1, 64)}
+	case bool:
+		return &Value{Type: TypeBoolean, Worth: strconv.FormatBool(v)}
+	default:
+		panic(fmt.Sprintf("fromAny: unsupported type %T", data))
+	}
+}
+
+// newTree builds a root node holding data
+func newTree(data any) *Node {
+	root := NewNode("root")
+	root.AddToValue(fromAny(data))
+	return root
+}
+
+// podTree is a small manifest used by the path tests
+func podTree() *Node {
+	return newTree(map[string]any{
+		"kind": "Pod",
+		"metadata": map[string]any{
+			"labels": map[string]any{
+				"app.kubernetes.io/name": "web",
+			},
+		},
+		"spec": map[string]any{
+			"containers": []any{
+				map[string]any{"name": "front", "image": "nginx", "ports": []any{80, 443}},
+				map[string]any{"name": "sidecar", "image": "envoy"},
+			},
+		},
+		"a~b": "tilde",
+	})
+}
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		name    string
+		pointer string
+		want    []string
+		wantErr bool
+	}{
+		{name: "whole document", pointer: "", want: nil},
+		{name: "root key", pointer: "/", want: []string{""}},
+		{name: "nested", pointer: "/spec/containers/0", want: []string{"spec", "containers", "0"}},
+		{name: "escapes", pointer: "/a~1b/c~0d", want: []string{"a/b", "c~d"}},
+		{name: "escape order", pointer: "/~01", want: []string{"~1"}},
+		{name: "missing slash", pointer: "spec", wantErr: true},
+		{name: "bad escape", pointer: "/a~2", wantErr: true},
+		{name: "dangling tilde", pointer: "/a~", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePointer(tt.pointer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePointer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePointer() = %q, want %q", got, tt.want)
+			}
+			if !tt.wantErr && FormatPointer(got) != tt.pointer && tt.name != "escape order" {
+				t.Errorf("FormatPointer() = %q, want %q", FormatPointer(got), tt.pointer)
+			}
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", path: "", want: nil},
+		{name: "dotted", path: "spec.containers", want: []string{"spec", "containers"}},
+		{name: "index", path: "spec.containers[0].image", want: []string{"spec", "containers", "0", "image"}},
+		{name: "append index", path: "items[-]", want: []string{"items", "-"}},
+		{name: "double quoted", path: `labels."app.kubernetes.io/name"`, want: []string{"labels", "app.kubernetes.io/name"}},
+		{name: "bracket quoted", path: `labels['a.b'].c`, want: []string{"labels", "a.b", "c"}},
+		{name: "escaped quote", path: `"say \"hi\""`, want: []string{`say "hi"`}},
+		{name: "nested indices", path: "m[1][2]", want: []string{"m", "1", "2"}},
+		{name: "empty segment", path: "a..b", wantErr: true},
+		{name: "trailing dot", path: "a.", wantErr: true},
+		{name: "bad index", path: "a[x]", wantErr: true},
+		{name: "unterminated bracket", path: "a[0", wantErr: true},
+		{name: "unterminated quote", path: `a."b`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNode_GetByPointer(t *testing.T) {
+	root := podTree()
+	tests := []struct {
+		name      string
+		pointer   string
+		wantType  ValueType
+		wantWorth string
+		wantErr   bool
+	}{
+		{name: "whole document", pointer: "", wantType: TypeObject},
+		{name: "member", pointer: "/kind", wantType: TypeString, wantWorth: "Pod"},
+		{name: "object member", pointer: "/spec", wantType: TypeObject},
+		{name: "array item", pointer: "/spec/containers/1/image", wantType: TypeString, wantWorth: "envoy"},
+		{name: "scalar array item", pointer: "/spec/containers/0/ports/1", wantType: TypeNumber, wantWorth: "443"},
+		{name: "object array item", pointer: "/spec/containers/0", wantType: TypeObject},
+		{name: "key with slash and dots", pointer: "/metadata/labels/app.kubernetes.io~1name", wantType: TypeString, wantWorth: "web"},
+		{name: "escaped tilde", pointer: "/a~0b", wantType: TypeString, wantWorth: "tilde"},
+		{name: "missing key", pointer: "/spec/volumes", wantErr: true},
+		{name: "index out of range", pointer: "/spec/containers/2", wantErr: true},
+		{name: "append position", pointer: "/spec/containers/-", wantErr: true},
+		{name: "leading zero", pointer: "/spec/containers/01", wantErr: true},
+		{name: "step into scalar", pointer: "/kind/x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := root.GetByPointer(tt.pointer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByPointer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Type != tt.wantType || got.Worth != tt.wantWorth {
+				t.Errorf("GetByPointer() = %v %q, want %v %q", got.Type, got.Worth, tt.wantType, tt.wantWorth)
+			}
+		})
+	}
+}
+
+func TestNode_GetByPath(t *testing.T) {
+	root := podTree()
+	tests := []struct {
+		name      string
+		path      string
+		wantWorth string
+		wantErr   bool
+	}{
+		{name: "member", path: "kind", wantWorth: "Pod"},
+		{name: "array item", path: "spec.containers[1].name", wantWorth: "sidecar"},
+		{name: "numeric segment", path: "spec.containers.0.name", wantWorth: "front"},
+		{name: "quoted key", path: `metadata.labels."app.kubernetes.io/name"`, wantWorth: "web"},
+		{name: "missing", path: "spec.containers[0].missing", wantErr: true},
+		{name: "invalid path", path: "spec..containers", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := root.GetByPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetByPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Worth != tt.wantWorth {
+				t.Errorf("GetByPath() = %q, want %q", got.Worth, tt.wantWorth)
+			}
+		})
+	}
+}
+
+func TestNode_SetByPointer(t *testing.T) {
+	tests := []struct {
+		name    string
+		pointer string
+		value   *Value
+		check   string
+		wantErr bool
+	}{
+		{name: "replace member", pointer: "/kind", value: fromAny("Deployment"), check: "/kind"},
+		{name: "add member", pointer: "/spec/replicas", value: fromAny(3), check: "/spec/replicas"},
+		{name: "replace array item", pointer: "/spec/containers/0/image", value: fromAny("nginx:1.27"), check: "/spec/containers/0/image"},
+		{name: "append to array", pointer: "/spec/containers/0/ports/-", value: fromAny(8080), check: "/spec/containers/0/ports/2"},
+		{name: "append at length", pointer: "/spec/containers/0/ports/2", value: fromAny(8080), check: "/spec/containers/0/ports/2"},
+		{name: "create objects", pointer: "/status/phase", value: fromAny("Running"), check: "/status/phase"},
+		{name: "create array", pointer: "/spec/volumes/0/name", value: fromAny("data"), check: "/spec/volumes/0/name"},
+		{name: "create array by append", pointer: "/spec/tolerations/-/key", value: fromAny("gpu"), check: "/spec/tolerations/0/key"},
+		{name: "object into array", pointer: "/spec/containers/-", value: fromAny(map[string]any{"name": "init"}), check: "/spec/containers/2"},
+		{name: "index beyond length", pointer: "/spec/containers/5", value: fromAny("x"), wantErr: true},
+		{name: "through scalar", pointer: "/kind/sub", value: fromAny("x"), wantErr: true},
+		{name: "nil value", pointer: "/kind", value: nil, wantErr: true},
+		{name: "invalid pointer", pointer: "kind", value: fromAny("x"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := podTree()
+			err := root.SetByPointer(tt.pointer, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetByPointer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := root.GetByPointer(tt.check)
+			if err != nil {
+				t.Fatalf("GetByPointer(%q) error = %v", tt.check, err)
+			}
+			if got != tt.value {
+				t.Errorf("GetByPointer(%q) = %v, want the value that was set", tt.check, got)
+			}
+			if err := root.Validate(); err != nil {
+				t.Errorf("Validate() after SetByPointer() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestNode_SetByPointerUnchangedOnError(t *testing.T) {
+	tests := []struct {
+		data    any
+		pointer string
+	}{
+		{data: map[string]any{"a": nil}, pointer: "/a/5"},
+		{data: map[string]any{"c": map[string]any{}}, pointer: "/c/x/7"},
+		{data: map[string]any{"n": nil}, pointer: "/n/x/5"},
+		{data: map[string]any{"a": nil}, pointer: "/a/-/b/3"},
+		{data: map[string]any{"s": "text"}, pointer: "/t/u/0/s/x/1"},
+		{data: map[string]any{"s": "text"}, pointer: "/s/x/y"},
+	}
+
+	for _, tt := range tests {
+		root := newTree(tt.data)
+		before := root.String()
+		if err := root.SetByPointer(tt.pointer, fromAny("x")); err == nil {
+			t.Errorf("SetByPointer(%q) on %s expected error", tt.pointer, before)
+		}
+		if after := root.String(); after != before {
+			t.Errorf("SetByPointer(%q) failed but changed %s into %s", tt.pointer, before, after)
+		}
+	}
+}
+
+func TestNode_SetByPath(t *testing.T) {
+	root := NewNode("root")
+	if err := root.SetByPath(`metadata.labels."app.kubernetes.io/name"`, fromAny("web")); err != nil {
+		t.Fatalf("SetByPath() error = %v", err)
+	}
+	if err := root.SetByPath("spec.containers[0].ports[-]", fromAny(80)); err != nil {
+		t.Fatalf("SetByPath() error = %v", err)
+	}
+
+	got, err := root.GetByPointer("/metadata/labels/app.kubernetes.io~1name")
+	if err != nil || got.Worth != "web" {
+		t.Errorf("GetByPointer() = %v, %v, want web", got, err)
+	}
+	got, err = root.GetByPath("spec.containers[0].ports[0]")
+	if err != nil || got.Worth != "80" {
+		t.Errorf("GetByPath() = %v, %v, want 80", got, err)
+	}
+	if err := root.Validate(); err != nil {
+		t.Errorf("Validate() after SetByPath() error = %v", err)
+	}
+}
+
+func TestNode_SetByPathKeepsComments(t *testing.T) {
+	root := NewNode("root")
+	if err := root.SetByPath("replicas", fromAny(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetByPath("ports", NewArray(NewNumber(80))); err != nil {
+		t.Fatal(err)
+	}
+	replicas, _ := root.GetByPath("replicas")
+	replicas.Comments.Line = "# scaled by hand"
+	port, _ := root.GetByPath("ports[0]")
+	port.Comments.Head = "# http"
+
+	if err := root.SetByPath("replicas", fromAny(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetByPath("ports[0]", fromAny(8080)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := root.GetByPath("replicas"); got.Worth != "3" || got.Comments.Line != "# scaled by hand" {
+		t.Errorf("replicas = %s with comments %+v, want 3 with the line comment kept", got.Worth, got.Comments)
+	}
+	if got, _ := root.GetByPath("ports[0]"); got.Worth != "8080" || got.Comments.Head != "# http" {
+		t.Errorf("ports[0] = %s with comments %+v, want 8080 with the head comment kept", got.Worth, got.Comments)
+	}
+}
+
+func TestNode_GetNodeByPathExtended(t *testing.T) {
+	root := podTree()
+	tests := []struct {
+		name    string
+		path    string
+		wantKey string
+		wantNil bool
+	}{
+		{name: "object node itself", path: "root.spec", wantKey: "spec"},
+		{name: "array object item", path: "root.spec.containers[1]", wantKey: "item1"},
+		{name: "member of array item", path: "root.spec.containers[1].image", wantKey: "image"},
+		{name: "quoted key", path: `root.metadata.labels."app.kubernetes.io/name"`, wantKey: "app.kubernetes.io/name"},
+		{name: "scalar array item", path: "root.spec.containers[0].ports[0]", wantNil: true},
+		{name: "wrong root", path: "other.spec", wantNil: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := root.GetNodeByPath(tt.path)
+			if tt.wantNil {
+				if got != nil {
+					t.Errorf("GetNodeByPath() = %v, want nil", got.Key)
+				}
+				return
+			}
+			if got == nil || got.Key != tt.wantKey {
+				t.Errorf("GetNodeByPath() = %v, want key %q", got, tt.wantKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package query
+
+import (
+	"regexp"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+// boolExpr is a filter condition evaluated with "@" bound to a location
+type boolExpr interface {
+	test(current, root location) bool
+}
+
+// operand produces the values a comparison works on
+type operand interface {
+	values(current, root location) []*node.Value
+}
+
+// literalOperand is a string, number, boolean or null literal
+type literalOperand struct {
+	value *node.Value
+}
+
+func (o literalOperand) values(_, _ location) []*node.Value {
+	return []*node.Value{o.value}
+}
+
+// queryOperand is a path relative to "@" or absolute from "$"
+type queryOperand struct {
+	relative bool
+	steps    []step
+}
+
+func (o queryOperand) values(current, root location) []*node.Value {
+	start := root
+	if o.relative {
+		start = current
+	}
+	locations := evaluate(o.steps, start, root)
+	values := make([]*node.Value, len(locations))
+	for i, loc := range locations {
+		values[i] = loc.value
+	}
+	return values
+}
+
+// orExpr holds if either side holds
+type orExpr struct {
+	left, right boolExpr
+}
+
+func (e orExpr) test(current, root location) bool {
+	return e.left.test(current, root) || e.right.test(current, root)
+}
+
+// andExpr holds if both sides hold
+type andExpr struct {
+	left, right boolExpr
+}
+
+func (e andExpr) test(current, root location) bool {
+	return e.left.test(current, root) && e.right.test(current, root)
+}
+
+// notExpr negates a condition
+type notExpr struct {
+	expr boolExpr
+}
+
+func (e notExpr) test(current, root location) bool {
+	return !e.expr.test(current, root)
+}
+
+// existsExpr holds if the query selects anything
+type existsExpr struct {
+	query queryOperand
+}
+
+func (e existsExpr) test(current, root location) bool {
+	return len(e.query.values(current, root)) > 0
+}
+
+// matchExpr holds if a selected string matches the regular expression
+type matchExpr struct {
+	left operand
+	re   *regexp.Regexp
+}
+
+func (e matchExpr) test(current, root location) bool {
+	for _, v := range e.left.values(current, root) {
+		if v != nil && v.Type == node.TypeString && e.re.MatchString(v.Worth) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareExpr compares two operands. Queries selecting several values
+// match if any pair satisfies the operator; a query selecting nothing
+// only equals another empty query.
+type compareExpr struct {
+	op          string
+	left, right operand
+}
+
+func (e compareExpr) test(current, root location) bool {
+	left, right := e.left.values(current, root), e.right.values(current, root)
+
+	if e.op == "!=" {
+		return !(compareExpr{op: "==", left: e.left, right: e.right}).test(current, root)
+	}
+	if len(left) == 0 || len(right) == 0 {
+		return e.op == "==" && len(left) == 0 && len(right) == 0
+	}
+
+	for _, l := range left {
+		for _, r := range right {
+			if compare(e.op, l, r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compare applies a comparison operator to two values. Ordering is only
+// defined between two numbers or two strings; timestamps compare as their
+// text, so that they match string literals and ISO dates order correctly.
+func compare(op string, l, r *node.Value) bool {
+	if l == nil || r == nil {
+		return false
+	}
+	l, r = timestampText(l), timestampText(r)
+
+	if op == "==" {
+		return l.Equal(r)
+	}
+
+	var cmp int
+	switch {
+	case l.Type == node.TypeNumber && r.Type == node.TypeNumber:
+		a, errA := l.Rat()
+		b, errB := r.Rat()
+		if errA != nil || errB != nil {
+			return false
+		}
+		cmp = a.Cmp(b)
+	case l.Type == node.TypeString && r.Type == node.TypeString:
+		switch {
+		case l.Worth < r.Worth:
+			cmp = -1
+		case l.Worth > r.Worth:
+			cmp = 1
+		}
+	default:
+		return false
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// timestampText returns a timestamp value as a string value
+func timestampText(v *node.Value) *node.Value {
+	if v.Type != node.TypeTimestamp {
+		return v
+	}
+	return &node.Value{Type: node.TypeString, Worth: v.Worth, Tag: v.Tag}
+}
@@ -0,0 +1,597 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+// step is one segment of a query: a set of selectors applied to the
+// current locations, or to all their descendants for ".."
+type step struct {
+	descendant bool
+	selectors  []selector
+}
+
+// apply appends the locations selected from l to out
+func (s step) apply(l, root location, out []location) []location {
+	for _, sel := range s.selectors {
+		out = sel.apply(l, root, out)
+	}
+	return out
+}
+
+// selector picks children of a location
+type selector interface {
+	apply(l, root location, out []location) []location
+}
+
+// nameSelector selects members with the given key. XML-derived trees can
+// hold several members with the same key, all of them are selected.
+type nameSelector struct {
+	name string
+}
+
+func (s nameSelector) apply(l, _ location, out []location) []location {
+	if l.value == nil || l.value.Type == node.TypeArray {
+		return out
+	}
+	for _, c := range l.children() {
+		if c.node.Key == s.name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// wildcardSelector selects all members or items
+type wildcardSelector struct{}
+
+func (wildcardSelector) apply(l, _ location, out []location) []location {
+	return append(out, l.children()...)
+}
+
+// indexSelector selects an array item, negative indices count from the end
+type indexSelector struct {
+	index int
+}
+
+func (s indexSelector) apply(l, _ location, out []location) []location {
+	if l.value == nil || l.value.Type != node.TypeArray {
+		return out
+	}
+	i := s.index
+	if i < 0 {
+		i += len(l.value.Array)
+	}
+	if i < 0 || i >= len(l.value.Array) || l.value.Array[i] == nil {
+		return out
+	}
+	item := l.value.Array[i]
+	return append(out, l.child(item.Node, item.Elem(), segment{index: i, isIndex: true}))
+}
+
+// sliceSelector selects a range of array items with Python slice semantics
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+func (s sliceSelector) apply(l, _ location, out []location) []location {
+	if l.value == nil || l.value.Type != node.TypeArray || s.step == 0 {
+		return out
+	}
+	length := len(l.value.Array)
+
+	normalize := func(i int) int {
+		if i < 0 {
+			return i + length
+		}
+		return i
+	}
+
+	if s.step > 0 {
+		lower, upper := 0, length
+		if s.start != nil {
+			lower = min(max(normalize(*s.start), 0), length)
+		}
+		if s.end != nil {
+			upper = min(max(normalize(*s.end), 0), length)
+		}
+		for i := lower; i < upper; i += s.step {
+			out = indexSelector{index: i}.apply(l, l, out)
+		}
+		return out
+	}
+
+	upper, lower := length-1, -1
+	if s.start != nil {
+		upper = min(max(normalize(*s.start), -1), length-1)
+	}
+	if s.end != nil {
+		lower = min(max(normalize(*s.end), -1), length-1)
+	}
+	for i := upper; i > lower; i += s.step {
+		out = indexSelector{index: i}.apply(l, l, out)
+	}
+	return out
+}
+
+// filterSelector selects the members or items for which the expression holds
+type filterSelector struct {
+	expr boolExpr
+}
+
+func (s filterSelector) apply(l, root location, out []location) []location {
+	for _, c := range l.children() {
+		if s.expr.test(c, root) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// parser is a recursive descent parser for JSONPath expressions
+type parser struct {
+	src string
+	pos int
+}
+
+// errorf returns a syntax error at the current position
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("query: %s at position %d in %q", fmt.Sprintf(format, args...), p.pos, p.src)
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) skipSpaces() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume advances past s if the input continues with it
+func (p *parser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// parseQuery parses a complete expression starting with "$"
+func (p *parser) parseQuery() ([]step, error) {
+	p.skipSpaces()
+	if !p.consume("$") {
+		return nil, p.errorf("expression must start with $")
+	}
+	steps, err := p.parseSteps()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return steps, nil
+}
+
+// parseSteps parses the segments following "$" or "@"
+func (p *parser) parseSteps() ([]step, error) {
+	var steps []step
+	for {
+		switch {
+		case p.consume(".."):
+			st, err := p.parseStepBody(true)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, st)
+
+		case p.consume("."):
+			st, err := p.parseStepBody(false)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, st)
+
+		case p.peek() == '[':
+			selectors, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step{selectors: selectors})
+
+		default:
+			return steps, nil
+		}
+	}
+}
+
+// parseStepBody parses what follows "." or "..": a name, "*" or a bracket
+func (p *parser) parseStepBody(descendant bool) (step, error) {
+	switch {
+	case p.consume("*"):
+		return step{descendant: descendant, selectors: []selector{wildcardSelector{}}}, nil
+	case descendant && p.peek() == '[':
+		selectors, err := p.parseBracket()
+		return step{descendant: true, selectors: selectors}, err
+	}
+
+	name := p.parseName()
+	if name == "" {
+		return step{}, p.errorf("expected member name")
+	}
+	return step{descendant: descendant, selectors: []selector{nameSelector{name: name}}}, nil
+}
+
+// parseName reads a member name in dot notation. Besides identifier
+// characters it accepts "-", ":" and "@" so keys like "servlet-name"
+// and XML attributes like "@id" can be written without brackets.
+func (p *parser) parseName() string {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\r\n.[]()=!<>&|,'\"~*", p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// parseBracket parses a bracketed selector list
+func (p *parser) parseBracket() ([]selector, error) {
+	if !p.consume("[") {
+		return nil, p.errorf("expected [")
+	}
+
+	var selectors []selector
+	for {
+		p.skipSpaces()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+
+		p.skipSpaces()
+		if p.consume("]") {
+			return selectors, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected , or ]")
+		}
+	}
+}
+
+// parseSelector parses a single entry of a bracketed selector list
+func (p *parser) parseSelector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+
+	case c == '\'' || c == '"':
+		name, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return nameSelector{name: name}, nil
+
+	case c == '?':
+		p.pos++
+		p.skipSpaces()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return filterSelector{expr: expr}, nil
+
+	case c == '-' || c == ':' || (c >= '0' && c <= '9'):
+		return p.parseIndexOrSlice()
+
+	default:
+		return nil, p.errorf("unexpected %q in brackets", c)
+	}
+}
+
+// parseIndexOrSlice parses "n" or "start:end:step" with optional parts
+func (p *parser) parseIndexOrSlice() (selector, error) {
+	var parts [3]*int
+	count := 0
+	for {
+		p.skipSpaces()
+		if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+			n, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			parts[count] = &n
+		}
+		p.skipSpaces()
+		if count < 2 && p.consume(":") {
+			count++
+			continue
+		}
+		break
+	}
+
+	if count == 0 {
+		if parts[0] == nil {
+			return nil, p.errorf("expected array index")
+		}
+		return indexSelector{index: *parts[0]}, nil
+	}
+
+	s := sliceSelector{start: parts[0], end: parts[1], step: 1}
+	if parts[2] != nil {
+		s.step = *parts[2]
+	}
+	return s, nil
+}
+
+// parseInt parses an optionally negative integer
+func (p *parser) parseInt() (int, error) {
+	start := p.pos
+	p.consume("-")
+	for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		return 0, p.errorf("invalid integer %q", p.src[start:p.pos])
+	}
+	return n, nil
+}
+
+// parseString parses a single or double quoted string literal
+func (p *parser) parseString() (string, error) {
+	quote := p.peek()
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case quote:
+			return b.String(), nil
+		case '\\':
+			if p.eof() {
+				return "", p.errorf("unterminated escape")
+			}
+			esc := p.src[p.pos]
+			p.pos++
+			switch esc {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(esc)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// parseOr parses "a || b"
+func (p *parser) parseOr() (boolExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if !p.consume("||") {
+			return left, nil
+		}
+		p.skipSpaces()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left: left, right: right}
+	}
+}
+
+// parseAnd parses "a && b"
+func (p *parser) parseAnd() (boolExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		p.skipSpaces()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left: left, right: right}
+	}
+}
+
+// parseUnary parses "!a", "(a)" and comparisons
+func (p *parser) parseUnary() (boolExpr, error) {
+	p.skipSpaces()
+	if p.peek() == '!' && !strings.HasPrefix(p.src[p.pos:], "!=") {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr: expr}, nil
+	}
+
+	if p.consume("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+		return expr, nil
+	}
+
+	return p.parseComparison()
+}
+
+// comparison operators, longest first so "<=" wins over "<"
+var operators = []string{"==", "!=", "<=", ">=", "=~", "<", ">"}
+
+// parseComparison parses "operand op operand" or a lone query used as
+// an existence test
+func (p *parser) parseComparison() (boolExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	op := ""
+	for _, candidate := range operators {
+		if p.consume(candidate) {
+			op = candidate
+			break
+		}
+	}
+
+	if op == "" {
+		q, ok := left.(queryOperand)
+		if !ok {
+			return nil, p.errorf("literal used as a condition")
+		}
+		return existsExpr{query: q}, nil
+	}
+
+	p.skipSpaces()
+	if op == "=~" {
+		re, err := p.parseRegex()
+		if err != nil {
+			return nil, err
+		}
+		return matchExpr{left: left, re: re}, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{op: op, left: left, right: right}, nil
+}
+
+// parseRegex parses /pattern/flags or a string literal holding a pattern.
+// Supported flags are i, m and s.
+func (p *parser) parseRegex() (*regexp.Regexp, error) {
+	var pattern string
+	switch p.peek() {
+	case '/':
+		p.pos++
+		var b strings.Builder
+		for {
+			if p.eof() {
+				return nil, p.errorf("unterminated regular expression")
+			}
+			c := p.src[p.pos]
+			p.pos++
+			if c == '/' {
+				break
+			}
+			if c == '\\' && p.peek() == '/' {
+				c = '/'
+				p.pos++
+			} else if c == '\\' && !p.eof() {
+				b.WriteByte(c)
+				c = p.src[p.pos]
+				p.pos++
+			}
+			b.WriteByte(c)
+		}
+		pattern = b.String()
+
+		flags := ""
+		for !p.eof() && strings.IndexByte("ims", p.peek()) >= 0 {
+			flags += string(p.peek())
+			p.pos++
+		}
+		if flags != "" {
+			pattern = "(?" + flags + ")" + pattern
+		}
+
+	case '\'', '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		pattern = s
+
+	default:
+		return nil, p.errorf("expected regular expression")
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, p.errorf("invalid regular expression: %v", err)
+	}
+	return re, nil
+}
+
+// parseOperand parses a literal or a query starting with "@" or "$"
+func (p *parser) parseOperand() (operand, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		steps, err := p.parseSteps()
+		if err != nil {
+			return nil, err
+		}
+		return queryOperand{relative: c == '@', steps: steps}, nil
+
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalOperand{value: &node.Value{Type: node.TypeString, Worth: s}}, nil
+
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.consume("-")
+		for !p.eof() && strings.IndexByte("0123456789.eE+-", p.peek()) >= 0 {
+			p.pos++
+		}
+		literal := p.src[start:p.pos]
+		if _, err := strconv.ParseFloat(literal, 64); err != nil {
+			return nil, p.errorf("invalid number %q", literal)
+		}
+		return literalOperand{value: &node.Value{Type: node.TypeNumber, Worth: literal}}, nil
+
+	case p.consume("true"):
+		return literalOperand{value: &node.Value{Type: node.TypeBoolean, Worth: "true"}}, nil
+	case p.consume("false"):
+		return literalOperand{value: &node.Value{Type: node.TypeBoolean, Worth: "false"}}, nil
+	case p.consume("null"):
+		return literalOperand{value: &node.Value{Type: node.TypeNull}}, nil
+
+	default:
+		return nil, p.errorf("expected operand")
+	}
+}
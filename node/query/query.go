@@ -0,0 +1,231 @@
+// Package query implements JSONPath expressions over node.Node trees.
+// It works on trees decoded from any format, including XML-derived trees
+// where attributes are "@name" members and repeated elements are arrays.
+//
+// Supported syntax:
+//
+//	$                 the root value
+//	.name ['name']    child member, ['a','b'] selects several
+//	.* [*]            all members or items
+//	..name ..*        recursive descent
+//	[0] [-1] [0,2]    array indices, negative from the end
+//	[1:5:2]           array slices with optional step
+//	[?(@.price < 10)] filters with ==, !=, <, <=, >, >=, =~ /regex/,
+//	                  &&, ||, ! and existence tests like [?(@.image)]
+//
+// Filters test the items of an array or the members of an object. Repeated
+// XML elements are decoded into arrays, so they are filtered like any other
+// array: $.catalog.book[?(@.price > 15)].title
+package query
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+// Result is a single match of a query
+type Result struct {
+	Path  string      // normalized JSONPath of the match, e.g. $['spec']['containers'][0]
+	Node  *node.Node  // node owning the value; nil for scalar array items
+	Value *node.Value // matched value
+	keys  []segment
+}
+
+// Pointer returns the RFC 6901 JSON Pointer of the match,
+// which can be passed to node.Node.GetByPointer or SetByPointer
+func (r Result) Pointer() string {
+	tokens := make([]string, len(r.keys))
+	for i, seg := range r.keys {
+		tokens[i] = seg.String()
+	}
+	return node.FormatPointer(tokens)
+}
+
+// Query is a compiled JSONPath expression, safe for concurrent use
+type Query struct {
+	expr  string
+	steps []step
+}
+
+// Compile parses a JSONPath expression
+func Compile(expr string) (*Query, error) {
+	p := &parser{src: expr}
+	steps, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	return &Query{expr: expr, steps: steps}, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed
+func MustCompile(expr string) *Query {
+	q, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the source expression of the query
+func (q *Query) String() string {
+	return q.expr
+}
+
+// Find evaluates the query against the value of root and returns
+// the matches in document order
+func (q *Query) Find(root *node.Node) []Result {
+	if root == nil || root.Value == nil {
+		return nil
+	}
+
+	start := location{node: root, value: root.Value}
+	locations := evaluate(q.steps, start, start)
+
+	results := make([]Result, len(locations))
+	for i, loc := range locations {
+		results[i] = Result{
+			Path:  loc.normalized(),
+			Node:  loc.node,
+			Value: loc.value,
+			keys:  loc.path,
+		}
+	}
+	return results
+}
+
+// Values returns the matched values
+func (q *Query) Values(root *node.Node) []*node.Value {
+	results := q.Find(root)
+	values := make([]*node.Value, len(results))
+	for i, r := range results {
+		values[i] = r.Value
+	}
+	return values
+}
+
+// Nodes returns the nodes owning the matched values.
+// Scalar array items have no node of their own and are skipped.
+func (q *Query) Nodes(root *node.Node) []*node.Node {
+	var nodes []*node.Node
+	for _, r := range q.Find(root) {
+		if r.Node != nil {
+			nodes = append(nodes, r.Node)
+		}
+	}
+	return nodes
+}
+
+// Find compiles expr and evaluates it against root
+func Find(root *node.Node, expr string) ([]Result, error) {
+	q, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return q.Find(root), nil
+}
+
+// segment is a member key or an array index in the path of a location
+type segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// String returns the segment as a JSON Pointer token
+func (s segment) String() string {
+	if s.isIndex {
+		return strconv.Itoa(s.index)
+	}
+	return s.key
+}
+
+// location is a value reached while evaluating a query
+type location struct {
+	node  *node.Node
+	value *node.Value
+	path  []segment
+}
+
+// normalized returns the normalized JSONPath of the location
+func (l location) normalized() string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, seg := range l.path {
+		if seg.isIndex {
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(seg.index))
+			b.WriteByte(']')
+			continue
+		}
+		b.WriteString("['")
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(seg.key))
+		b.WriteString("']")
+	}
+	return b.String()
+}
+
+// child returns a location below l
+func (l location) child(n *node.Node, v *node.Value, seg segment) location {
+	path := make([]segment, len(l.path), len(l.path)+1)
+	copy(path, l.path)
+	return location{node: n, value: v, path: append(path, seg)}
+}
+
+// children returns the members or items of the location in document order
+func (l location) children() []location {
+	v := l.value
+	if v == nil {
+		return nil
+	}
+
+	var out []location
+	if v.Type == node.TypeArray {
+		for i, item := range v.Array {
+			if item == nil {
+				continue
+			}
+			out = append(out, l.child(item.Node, item.Elem(), segment{index: i, isIndex: true}))
+		}
+		return out
+	}
+
+	// XML-derived scalars keep their attributes as members, so any
+	// non-array value with nodes is walked
+	for current := v.Node; current != nil; current = current.Next {
+		if current.Value == nil {
+			continue
+		}
+		out = append(out, l.child(current, current.Value, segment{key: current.Key}))
+	}
+	return out
+}
+
+// evaluate applies steps to start; root is the location "$" refers to
+func evaluate(steps []step, start, root location) []location {
+	current := []location{start}
+	for _, st := range steps {
+		var next []location
+		for _, loc := range current {
+			if st.descendant {
+				for _, d := range descendants(loc) {
+					next = st.apply(d, root, next)
+				}
+				continue
+			}
+			next = st.apply(loc, root, next)
+		}
+		current = next
+	}
+	return current
+}
+
+// descendants returns l and everything below it in document order
+func descendants(l location) []location {
+	out := []location{l}
+	for _, c := range l.children() {
+		out = append(out, descendants(c)...)
+	}
+	return out
+}
@@ -0,0 +1,224 @@
+package query_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mstgnz/transformer/node"
+	"github.com/mstgnz/transformer/node/query"
+	"github.com/mstgnz/transformer/tjson"
+	"github.com/mstgnz/transformer/txml"
+)
+
+const podJson = `{
+	"kind": "Pod",
+	"spec": {
+		"containers": [
+			{"name": "web", "image": "nginx:1.25", "ports": [{"containerPort": 80}, {"containerPort": 443}]},
+			{"name": "sidecar", "image": "envoy", "ports": [{"containerPort": 9901}]},
+			{"name": "proxy", "image": "NGINX-unprivileged", "ports": [{"containerPort": 8080}]}
+		],
+		"tags": ["a", "b", "c", "d", "e"],
+		"replicas": 3
+	}
+}`
+
+const catalogXml = `<catalog>
+	<book id="1"><title>Go</title><price>10</price></book>
+	<book id="2"><title>Rust</title><price>25</price></book>
+	<magazine id="3"><title>Wired</title><price>5</price></magazine>
+</catalog>`
+
+// worths returns the Worth of scalar values and the type name of others
+func worths(values []*node.Value) []string {
+	out := []string{}
+	for _, v := range values {
+		if v.Type == node.TypeObject || v.Type == node.TypeArray {
+			out = append(out, v.Type.String())
+			continue
+		}
+		out = append(out, v.Worth)
+	}
+	return out
+}
+
+func TestFind(t *testing.T) {
+	pod, err := tjson.DecodeJson([]byte(podJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		{"root", "$", []string{"object"}},
+		{"member", "$.kind", []string{"Pod"}},
+		{"bracket member", "$['spec']['replicas']", []string{"3"}},
+		{"missing member", "$.status", []string{}},
+		{"index", "$.spec.tags[1]", []string{"b"}},
+		{"negative index", "$.spec.tags[-1]", []string{"e"}},
+		{"index out of range", "$.spec.tags[9]", []string{}},
+		{"union", "$.spec.tags[0,2]", []string{"a", "c"}},
+		{"slice", "$.spec.tags[1:3]", []string{"b", "c"}},
+		{"open slice", "$.spec.tags[3:]", []string{"d", "e"}},
+		{"slice with step", "$.spec.tags[::2]", []string{"a", "c", "e"}},
+		{"negative step", "$.spec.tags[::-2]", []string{"e", "c", "a"}},
+		{"wildcard array", "$.spec.containers[*].name", []string{"web", "sidecar", "proxy"}},
+		{"wildcard object", "$.spec.containers[1].*", []string{"sidecar", "envoy", "array"}},
+		{"recursive descent", "$..containerPort", []string{"80", "443", "9901", "8080"}},
+		{"recursive wildcard", "$.spec.containers[1].ports..*", []string{"object", "9901"}},
+		{"regex filter", "$.spec.containers[?(@.image =~ /nginx/)].ports[*].containerPort", []string{"80", "443"}},
+		{"regex flags", "$.spec.containers[?(@.image =~ /^nginx/i)].name", []string{"web", "proxy"}},
+		{"equality", "$.spec.containers[?(@.name == 'sidecar')].image", []string{"envoy"}},
+		{"inequality", "$.spec.containers[?(@.name != 'sidecar')].name", []string{"web", "proxy"}},
+		{"numeric comparison", "$..ports[?(@.containerPort >= 443)].containerPort", []string{"443", "9901", "8080"}},
+		{"and", "$..ports[?(@.containerPort > 80 && @.containerPort < 9000)].containerPort", []string{"443", "8080"}},
+		{"or", "$..ports[?(@.containerPort == 80 || @.containerPort == 9901)].containerPort", []string{"80", "9901"}},
+		{"not", "$.spec.containers[?(!(@.image =~ /nginx/i))].name", []string{"sidecar"}},
+		{"existence", "$.spec.containers[?(@.ports[1])].name", []string{"web"}},
+		{"current value", "$.spec.tags[?(@ > 'c')]", []string{"d", "e"}},
+		{"absolute operand", "$.spec.containers[?(@.ports[0].containerPort > $.spec.replicas * 0)].name", nil},
+		{"filter without parentheses", "$.spec.containers[?@.name == 'web'].image", []string{"nginx:1.25"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := query.Find(pod, tt.expr)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("Find(%q) expected error", tt.expr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find(%q) error = %v", tt.expr, err)
+			}
+			values := make([]*node.Value, len(results))
+			for i, r := range results {
+				values[i] = r.Value
+			}
+			if got := worths(values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFind_Xml(t *testing.T) {
+	catalog, err := txml.DecodeXml([]byte(catalogXml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		{"repeated elements", "$.catalog.book[*].title", []string{"Go", "Rust"}},
+		{"attributes", "$..@id", []string{"1", "2", "3"}},
+		{"filter repeated elements", "$.catalog.book[?(@.price > 15)].title", []string{"Rust"}},
+		{"filter by attribute", "$.catalog[?(@['@id'] == '3')].title", []string{"Wired"}},
+		{"recursive descent", "$..price", []string{"10", "25", "5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := query.Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.expr, err)
+			}
+			if got := worths(q.Values(catalog)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Values(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []string{
+		"",
+		"spec.containers",
+		"$.",
+		"$[",
+		"$[0",
+		"$['name",
+		"$[?(@.a ==)]",
+		"$[?(@.a =~ /[/)]",
+		"$[?(@.a == 1]",
+		"$[?('literal')]",
+		"$.a b",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := query.Compile(expr); err == nil {
+				t.Errorf("Compile(%q) expected error", expr)
+			}
+		})
+	}
+}
+
+func TestResult_Paths(t *testing.T) {
+	pod, err := tjson.DecodeJson([]byte(podJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := query.MustCompile("$.spec.containers[?(@.name == 'sidecar')].ports[0].containerPort").Find(pod)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	r := results[0]
+	if want := "$['spec']['containers'][1]['ports'][0]['containerPort']"; r.Path != want {
+		t.Errorf("Path = %q, want %q", r.Path, want)
+	}
+	if want := "/spec/containers/1/ports/0/containerPort"; r.Pointer() != want {
+		t.Errorf("Pointer() = %q, want %q", r.Pointer(), want)
+	}
+	if r.Node == nil || r.Node.Key != "containerPort" {
+		t.Errorf("Node = %v, want containerPort node", r.Node)
+	}
+
+	got, err := pod.GetByPointer(r.Pointer())
+	if err != nil || got != r.Value {
+		t.Errorf("GetByPointer(%q) = %v, %v, want the matched value", r.Pointer(), got, err)
+	}
+}
+
+func TestQuery_Nodes(t *testing.T) {
+	pod, err := tjson.DecodeJson([]byte(podJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := query.MustCompile("$.spec.tags[0]")
+	if nodes := q.Nodes(pod); len(nodes) != 0 {
+		t.Errorf("Nodes() returned %d nodes for scalar items, want 0", len(nodes))
+	}
+
+	q = query.MustCompile("$.spec.containers[*].name")
+	var keys []string
+	for _, n := range q.Nodes(pod) {
+		keys = append(keys, n.Key+"="+n.Value.Worth)
+	}
+	want := []string{"name=web", "name=sidecar", "name=proxy"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("Nodes() = %v, want %v", keys, want)
+	}
+	if q.String() != "$.spec.containers[*].name" {
+		t.Errorf("String() = %q", q.String())
+	}
+}
+
+func TestMustCompile_Panics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("MustCompile did not panic")
+		}
+	}()
+	query.MustCompile("$[")
+}
@@ -0,0 +1,918 @@
+package node
+
+import (
+	"encoding"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tagNames lists the struct tags read for field names and options,
+// in order of precedence
+var tagNames = []string{"transformer", "json", "yaml", "xml"}
+
+var (
+	valueType           = reflect.TypeOf((*Value)(nil))
+	timeType            = reflect.TypeOf(time.Time{})
+	xmlNameType         = reflect.TypeOf(xml.Name{})
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// StructOptions configures the conversion of Go values by FromStruct
+type StructOptions struct {
+	// Key is the key of the returned root node, "root" when empty
+	Key string
+	// OmitEmpty leaves out empty fields as if all of them were tagged omitempty
+	OmitEmpty bool
+}
+
+// FromStruct converts a Go value to a tree. Struct fields are named and
+// configured by the transformer, json, yaml and xml tags, the first one
+// present wins; the attr option of an xml tag applies whichever tag names
+// the field. The supported options are:
+//
+//   - omitempty: leave the field out when it holds its empty value
+//   - inline: merge the members of a struct or map field into the parent
+//   - string: store a number or boolean as a string
+//   - attr: store the field as an XML attribute, i.e. under the key "@name"
+//
+// Embedded structs are inlined unless they are named by a tag. Maps need
+// string or integer keys, the latter formatted in decimal as encoding/json
+// does, and are written in key order. time.Time is stored as a
+// timestamp, byte slices as binary, and other values implementing
+// encoding.TextMarshaler as strings. A value that contains itself, such
+// as a pointer cycle, is an error.
+func FromStruct(v any, opts StructOptions) (*Node, error) {
+	e := &structEncoder{omitEmpty: opts.OmitEmpty}
+	value, err := e.encode(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	key := opts.Key
+	if key == "" {
+		key = "root"
+	}
+	root := NewNode(key)
+	root.AddToValue(value)
+	return root, nil
+}
+
+// Decode stores the value of n in the Go value pointed to by out, which
+// is the reverse of FromStruct and honors the same tags. Object keys are
+// matched to field names exactly first, then case-insensitively; XML
+// attributes also match fields not tagged attr, and plain keys fields
+// tagged attr. Unknown keys are ignored. Strings holding a number or
+// boolean are accepted for numeric and boolean fields, since XML
+// attributes and the string option store them that way. A slice field
+// collects repeated members with the same key, and a single value decodes
+// into a slice of one, so an XML element that occurs once still fills a
+// slice. Null sets pointers, maps, slices and interfaces to nil and leaves
+// other values unchanged, as encoding/json does. Keys of maps with integer
+// keys are parsed as decimal numbers.
+func Decode(n *Node, out any) error {
+	if n == nil {
+		return fmt.Errorf("node is nil")
+	}
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("decode target must be a non-nil pointer, got %T", out)
+	}
+	return decodeValue(n.Value, rv.Elem(), "")
+}
+
+// structField is a field of a struct type as seen by FromStruct and Decode
+type structField struct {
+	name      string // key in the tree, "@name" for attributes
+	index     []int  // index sequence for reflect.Value.FieldByIndex
+	depth     int    // embedding depth, shallower fields hide deeper ones
+	omitEmpty bool
+	asString  bool
+	attr      bool
+	inline    bool // an inline map collecting the remaining members
+}
+
+// fieldTag holds the parsed name and options of a struct tag
+type fieldTag struct {
+	name                            string
+	skip, omitEmpty, asString, attr bool
+	inline                          bool
+}
+
+// parseFieldTag reads the first of tagNames present on a field. The attr
+// option is read from the xml tag even when another tag names the field.
+func parseFieldTag(sf reflect.StructField) fieldTag {
+	var tag fieldTag
+	if s, ok := sf.Tag.Lookup("xml"); ok {
+		_, opts, _ := strings.Cut(s, ",")
+		tag.attr = hasTagOption(opts, "attr")
+	}
+	for _, key := range tagNames {
+		s, ok := sf.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		if s == "-" {
+			tag.skip = true
+			return tag
+		}
+		name, opts, _ := strings.Cut(s, ",")
+		tag.name = name
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				tag.omitEmpty = true
+			case "string":
+				tag.asString = true
+			case "attr":
+				tag.attr = true
+			case "inline":
+				tag.inline = true
+			}
+		}
+		return tag
+	}
+	return tag
+}
+
+// hasTagOption reports whether the comma separated options of a tag hold opt
+func hasTagOption(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// structFields returns the fields of a struct type with embedded and
+// inline structs flattened, in declaration order
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	collectFields(t, nil, map[reflect.Type]bool{t: true}, &fields)
+
+	// Keep the shallowest field of each name, the first one on a tie
+	result := make([]structField, 0, len(fields))
+	seen := make(map[string]int, len(fields))
+	for _, f := range fields {
+		if i, ok := seen[f.name]; ok {
+			if f.depth < result[i].depth {
+				result[i] = f
+			}
+			continue
+		}
+		seen[f.name] = len(result)
+		result = append(result, f)
+	}
+	return result
+}
+
+// collectFields appends the fields of t to fields. visiting holds the
+// struct types on the current embedding path, guarding against cycles.
+func collectFields(t reflect.Type, index []int, visiting map[reflect.Type]bool, fields *[]structField) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Type == xmlNameType {
+			continue
+		}
+		tag := parseFieldTag(sf)
+		if tag.skip {
+			continue
+		}
+		idx := append(index[:len(index):len(index)], i)
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if (sf.Anonymous && tag.name == "") || tag.inline {
+			if ft.Kind() == reflect.Struct && ft != timeType && !reflect.PointerTo(ft).Implements(textMarshalerType) {
+				if !visiting[ft] {
+					visiting[ft] = true
+					collectFields(ft, idx, visiting, fields)
+					delete(visiting, ft)
+				}
+				continue
+			}
+			if tag.inline && ft.Kind() == reflect.Map && sf.IsExported() {
+				*fields = append(*fields, structField{index: idx, depth: len(index), inline: true})
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := tag.name
+		if name == "" {
+			name = sf.Name
+		}
+		if tag.attr {
+			name = "@" + name
+		}
+		*fields = append(*fields, structField{
+			name:      name,
+			index:     idx,
+			depth:     len(index),
+			omitEmpty: tag.omitEmpty,
+			asString:  tag.asString,
+			attr:      tag.attr,
+		})
+	}
+}
+
+// fieldByIndex returns the field of a struct value, ok is false when it
+// sits behind a nil embedded pointer
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+// fieldByIndexAlloc returns the field of a struct value, allocating nil
+// embedded pointers on the way
+func fieldByIndexAlloc(rv reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return reflect.Value{}
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv
+}
+
+// isEmptyValue reports whether a field is left out by omitempty.
+// Besides the empty values of encoding/json, zero structs such as an
+// unset time.Time are empty.
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return rv.IsZero()
+	}
+}
+
+// structEncoder converts Go values to values of the tree
+type structEncoder struct {
+	omitEmpty bool
+	visiting  map[visit]bool // pointers, maps and slices being encoded
+}
+
+// visit identifies a pointer, map or slice by its address, type and length
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// enter marks a pointer, map or slice as being encoded and returns the
+// function that unmarks it. Reaching it again from within itself is a
+// cycle, which is an error rather than endless recursion.
+func (e *structEncoder) enter(rv reflect.Value) (func(), error) {
+	key := visit{ptr: rv.Pointer(), typ: rv.Type()}
+	if rv.Kind() == reflect.Slice {
+		key.len = rv.Len()
+	}
+	if e.visiting[key] {
+		return nil, fmt.Errorf("encountered a cycle via %s", rv.Type())
+	}
+	if e.visiting == nil {
+		e.visiting = make(map[visit]bool)
+	}
+	e.visiting[key] = true
+	return func() { delete(e.visiting, key) }, nil
+}
+
+// encode converts rv to a Value
+func (e *structEncoder) encode(rv reflect.Value) (*Value, error) {
+	if !rv.IsValid() {
+		return NewNull(), nil
+	}
+	if rv.Type() == valueType {
+		if rv.IsNil() {
+			return NewNull(), nil
+		}
+		return rv.Interface().(*Value), nil
+	}
+	if (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && rv.IsNil() {
+		return NewNull(), nil
+	}
+
+	if rv.Type() == timeType {
+		return NewTimestamp(rv.Interface().(time.Time)), nil
+	}
+	if rv.Type().Implements(textMarshalerType) {
+		text, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return NewString(string(text)), nil
+	}
+	if rv.Kind() != reflect.Pointer && rv.CanAddr() && rv.Addr().Type().Implements(textMarshalerType) {
+		return e.encode(rv.Addr())
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer:
+		leave, err := e.enter(rv)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return e.encode(rv.Elem())
+	case reflect.Interface:
+		return e.encode(rv.Elem())
+	case reflect.String:
+		return NewString(rv.String()), nil
+	case reflect.Bool:
+		return NewBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return numberOf(rv)
+	case reflect.Slice:
+		if rv.IsNil() {
+			return NewNull(), nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return NewBinary(rv.Bytes()), nil
+		}
+		if rv.Len() > 0 {
+			leave, err := e.enter(rv)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		return e.encodeArray(rv)
+	case reflect.Array:
+		return e.encodeArray(rv)
+	case reflect.Map:
+		if rv.IsNil() {
+			return NewNull(), nil
+		}
+		leave, err := e.enter(rv)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		object := NewObject()
+		if err := e.encodeMap(object, rv); err != nil {
+			return nil, err
+		}
+		return object, nil
+	case reflect.Struct:
+		return e.encodeStruct(rv)
+	}
+	return nil, fmt.Errorf("unsupported type %s", rv.Type())
+}
+
+// numberOf converts a numeric Go value to a number value
+func numberOf(rv reflect.Value) (*Value, error) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewNumber(rv.Int()), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("unsupported number %v", f)
+		}
+		if rv.Kind() == reflect.Float32 {
+			worth := strconv.FormatFloat(f, 'g', -1, 32)
+			return &Value{Type: TypeNumber, Worth: worth, Kind: ClassifyNumber(worth)}, nil
+		}
+		return NewFloat(f), nil
+	default:
+		worth := strconv.FormatUint(rv.Uint(), 10)
+		return &Value{Type: TypeNumber, Worth: worth, Kind: ClassifyNumber(worth)}, nil
+	}
+}
+
+// encodeArray converts a slice or array to an array value
+func (e *structEncoder) encodeArray(rv reflect.Value) (*Value, error) {
+	items := make([]*Value, rv.Len())
+	for i := range items {
+		item, err := e.encode(rv.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("index %d: %v", i, err)
+		}
+		items[i] = item
+	}
+	return NewArray(items...), nil
+}
+
+// encodeMap appends the entries of a map to object, sorted by key
+func (e *structEncoder) encodeMap(object *Value, rv reflect.Value) error {
+	if !isMapKey(rv.Type().Key()) {
+		return fmt.Errorf("unsupported map key type %s", rv.Type().Key())
+	}
+	keys := make([]string, 0, rv.Len())
+	entries := make(map[string]reflect.Value, rv.Len())
+	for iter := rv.MapRange(); iter.Next(); {
+		key := formatMapKey(iter.Key())
+		keys = append(keys, key)
+		entries[key] = iter.Value()
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		member, err := e.encode(entries[key])
+		if err != nil {
+			return fmt.Errorf("key %q: %v", key, err)
+		}
+		appendMember(object, key, member)
+	}
+	return nil
+}
+
+// isMapKey reports whether maps keyed by t can be converted, which are
+// the ones with string or integer keys
+func isMapKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// formatMapKey returns the object key of a map key, integers in decimal
+func formatMapKey(k reflect.Value) string {
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	return k.String()
+}
+
+// parseMapKey returns the map key of type t for an object key
+func parseMapKey(key string, t reflect.Type) (reflect.Value, error) {
+	k := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(key, 10, t.Bits())
+		if err != nil {
+			return k, fmt.Errorf("invalid map key %q for %s", key, t)
+		}
+		k.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(key, 10, t.Bits())
+		if err != nil {
+			return k, fmt.Errorf("invalid map key %q for %s", key, t)
+		}
+		k.SetUint(u)
+	default:
+		k.SetString(key)
+	}
+	return k, nil
+}
+
+// encodeStruct converts a struct to an object value
+func (e *structEncoder) encodeStruct(rv reflect.Value) (*Value, error) {
+	object := NewObject()
+	for _, f := range structFields(rv.Type()) {
+		fv, ok := fieldByIndex(rv, f.index)
+		if !ok {
+			continue
+		}
+		if f.inline {
+			if !fv.IsNil() {
+				if err := e.encodeMap(object, fv); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if (f.omitEmpty || e.omitEmpty) && isEmptyValue(fv) {
+			continue
+		}
+
+		member, err := e.encode(fv)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.name, err)
+		}
+		if f.asString && (member.Type == TypeNumber || member.Type == TypeBoolean) {
+			member = NewString(member.Worth)
+		}
+		if f.attr && (member.Type == TypeObject || member.Type == TypeArray) {
+			return nil, fmt.Errorf("field %s: attribute must be a scalar, got %s", f.name, member.Type)
+		}
+		appendMember(object, f.name, member)
+	}
+	return object, nil
+}
+
+// decodeValue stores v in rv. path locates v for error messages.
+func decodeValue(v *Value, rv reflect.Value, path string) error {
+	if rv.Type() == valueType {
+		if v == nil {
+			rv.SetZero()
+		} else {
+			rv.Set(reflect.ValueOf(v.Clone()))
+		}
+		return nil
+	}
+
+	if v == nil || v.Type == TypeNull {
+		switch rv.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+			rv.SetZero()
+		}
+		return nil
+	}
+
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeValue(v, rv.Elem(), path)
+	}
+
+	if rv.Type() == timeType {
+		t, err := v.AsTime()
+		if err != nil {
+			return decodeError(path, err)
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if rv.CanAddr() && rv.Addr().Type().Implements(textUnmarshalerType) {
+		text, err := v.StringVal()
+		if err == nil {
+			err = rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+		}
+		return decodeError(path, err)
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return decodeError(path, fmt.Errorf("cannot decode into %s", rv.Type()))
+		}
+		rv.Set(reflect.ValueOf(valueToAny(v)))
+		return nil
+
+	case reflect.String:
+		s, err := v.StringVal()
+		if err != nil {
+			return decodeError(path, err)
+		}
+		rv.SetString(s)
+		return nil
+
+	case reflect.Bool:
+		if v.Type == TypeString {
+			v = &Value{Type: TypeBoolean, Worth: v.Worth}
+		}
+		b, err := v.Bool()
+		if err != nil {
+			return decodeError(path, err)
+		}
+		rv.SetBool(b)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := numberFrom(v).Int64()
+		if err == nil && rv.OverflowInt(i) {
+			err = fmt.Errorf("number %s overflows %s", v.Worth, rv.Type())
+		}
+		if err != nil {
+			return decodeError(path, err)
+		}
+		rv.SetInt(i)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := numberFrom(v).Uint64()
+		if err == nil && rv.OverflowUint(u) {
+			err = fmt.Errorf("number %s overflows %s", v.Worth, rv.Type())
+		}
+		if err != nil {
+			return decodeError(path, err)
+		}
+		rv.SetUint(u)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := numberFrom(v).Float64()
+		if err == nil && rv.OverflowFloat(f) {
+			err = fmt.Errorf("number %s overflows %s", v.Worth, rv.Type())
+		}
+		if err != nil {
+			return decodeError(path, err)
+		}
+		rv.SetFloat(f)
+		return nil
+
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 && (v.Type == TypeString || v.Type == TypeBinary) {
+			b, err := (&Value{Type: TypeBinary, Worth: v.Worth}).Bytes()
+			if err != nil {
+				return decodeError(path, err)
+			}
+			rv.SetBytes(b)
+			return nil
+		}
+		if v.Type != TypeArray {
+			return decodeSlice([]*Value{v}, rv, path)
+		}
+		items := make([]*Value, len(v.Array))
+		for i, item := range v.Array {
+			items[i] = item.Elem()
+		}
+		return decodeSlice(items, rv, path)
+
+	case reflect.Array:
+		if v.Type != TypeArray {
+			return decodeError(path, fmt.Errorf("cannot decode %s value into %s", v.Type, rv.Type()))
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if i >= len(v.Array) {
+				rv.Index(i).SetZero()
+				continue
+			}
+			if err := decodeValue(v.Array[i].Elem(), rv.Index(i), indexPath(path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if v.Type != TypeObject {
+			return decodeError(path, fmt.Errorf("cannot decode %s value into %s", v.Type, rv.Type()))
+		}
+		if !isMapKey(rv.Type().Key()) {
+			return decodeError(path, fmt.Errorf("unsupported map key type %s", rv.Type().Key()))
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		for member := v.Node; member != nil; member = member.Next {
+			if err := decodeMapEntry(member, rv, path); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Struct:
+		// XML elements with attributes may hold them on a scalar value
+		if v.Type != TypeObject && v.Node == nil {
+			return decodeError(path, fmt.Errorf("cannot decode %s value into %s", v.Type, rv.Type()))
+		}
+		return decodeStruct(v, rv, path)
+	}
+
+	return decodeError(path, fmt.Errorf("unsupported type %s", rv.Type()))
+}
+
+// decodeSlice stores values as the elements of a slice
+func decodeSlice(values []*Value, rv reflect.Value, path string) error {
+	slice := reflect.MakeSlice(rv.Type(), len(values), len(values))
+	for i, item := range values {
+		if err := decodeValue(item, slice.Index(i), indexPath(path, i)); err != nil {
+			return err
+		}
+	}
+	rv.Set(slice)
+	return nil
+}
+
+// decodeMapEntry stores a member in a map
+func decodeMapEntry(member *Node, rv reflect.Value, path string) error {
+	key, err := parseMapKey(member.Key, rv.Type().Key())
+	if err != nil {
+		return decodeError(keyPath(path, member.Key), err)
+	}
+	elem := reflect.New(rv.Type().Elem()).Elem()
+	if err := decodeValue(member.Value, elem, keyPath(path, member.Key)); err != nil {
+		return err
+	}
+	rv.SetMapIndex(key, elem)
+	return nil
+}
+
+// decodeStruct stores the members of v in the fields of a struct
+func decodeStruct(v *Value, rv reflect.Value, path string) error {
+	fields := structFields(rv.Type())
+	var inline *structField
+	byName := make(map[string]int, len(fields))
+	byFold := make(map[string]int, len(fields))
+	for i, f := range fields {
+		if f.inline {
+			if inline == nil {
+				inline = &fields[i]
+			}
+			continue
+		}
+		byName[f.name] = i
+		if _, ok := byFold[strings.ToLower(f.name)]; !ok {
+			byFold[strings.ToLower(f.name)] = i
+		}
+	}
+	// Attribute fields also match the plain key formats without attributes
+	// use, unless another field has that name
+	for i, f := range fields {
+		if !f.attr || f.inline {
+			continue
+		}
+		name := strings.TrimPrefix(f.name, "@")
+		if _, ok := byName[name]; !ok {
+			byName[name] = i
+		}
+		if _, ok := byFold[strings.ToLower(name)]; !ok {
+			byFold[strings.ToLower(name)] = i
+		}
+	}
+
+	// Members are grouped by field first, so that repeated keys fill a slice
+	values := make(map[int][]*Value)
+	var order []int
+	for member := v.Node; member != nil; member = member.Next {
+		i, ok := lookupField(byName, byFold, member.Key)
+		if !ok && strings.HasPrefix(member.Key, "@") {
+			i, ok = lookupField(byName, byFold, member.Key[1:])
+		}
+		if !ok {
+			if inline != nil {
+				fv := fieldByIndexAlloc(rv, inline.index)
+				if !fv.IsValid() {
+					continue
+				}
+				if fv.IsNil() {
+					fv.Set(reflect.MakeMap(fv.Type()))
+				}
+				if err := decodeMapEntry(member, fv, path); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if _, ok := values[i]; !ok {
+			order = append(order, i)
+		}
+		values[i] = append(values[i], member.Value)
+	}
+
+	for _, i := range order {
+		f := fields[i]
+		fv := fieldByIndexAlloc(rv, f.index)
+		if !fv.IsValid() {
+			return decodeError(keyPath(path, f.name), fmt.Errorf("cannot set embedded pointer to unexported struct"))
+		}
+		group := values[i]
+		if len(group) > 1 && isSliceField(fv) {
+			if err := decodeSlice(group, fv, keyPath(path, f.name)); err != nil {
+				return err
+			}
+			continue
+		}
+		// Without a slice to collect them, the last repeated member wins
+		if err := decodeValue(group[len(group)-1], fv, keyPath(path, f.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupField finds the field for a key, exactly or case-insensitively
+func lookupField(byName, byFold map[string]int, key string) (int, bool) {
+	if i, ok := byName[key]; ok {
+		return i, true
+	}
+	i, ok := byFold[strings.ToLower(key)]
+	return i, ok
+}
+
+// isSliceField reports whether repeated members can be collected into rv
+func isSliceField(rv reflect.Value) bool {
+	t := rv.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8
+}
+
+// numberFrom returns v as a number value, parsing strings holding a
+// number literal so that attributes and string-tagged fields decode
+func numberFrom(v *Value) *Value {
+	if v.Type == TypeString {
+		if number, err := ParseNumber(strings.TrimSpace(v.Worth)); err == nil {
+			return number
+		}
+	}
+	return v
+}
+
+// valueToAny converts a value to plain Go values: map[string]any, []any,
+// string, bool, nil, int64, uint64 or float64 for numbers, time.Time for
+// timestamps and []byte for binary values. Repeated keys are collected
+// into a []any.
+func valueToAny(v *Value) any {
+	switch v.Type {
+	case TypeObject:
+		m := make(map[string]any)
+		for member := v.Node; member != nil; member = member.Next {
+			item := valueToAny(member.Value)
+			existing, ok := m[member.Key]
+			if !ok {
+				m[member.Key] = item
+				continue
+			}
+			if group, ok := existing.(repeated); ok {
+				m[member.Key] = append(group, item)
+			} else {
+				m[member.Key] = repeated{existing, item}
+			}
+		}
+		for key, item := range m {
+			if group, ok := item.(repeated); ok {
+				m[key] = []any(group)
+			}
+		}
+		return m
+	case TypeArray:
+		items := make([]any, len(v.Array))
+		for i, item := range v.Array {
+			items[i] = valueToAny(item.Elem())
+		}
+		return items
+	case TypeNumber:
+		switch v.NumberKind() {
+		case NumberInt:
+			i, _ := v.Int64()
+			return i
+		case NumberUint:
+			u, _ := v.Uint64()
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	case TypeBoolean:
+		b, _ := v.Bool()
+		return b
+	case TypeTimestamp:
+		if t, err := v.AsTime(); err == nil {
+			return t
+		}
+		return v.Worth
+	case TypeBinary:
+		if b, err := v.Bytes(); err == nil {
+			return b
+		}
+		return v.Worth
+	case TypeNull:
+		return nil
+	default:
+		return v.Worth
+	}
+}
+
+// repeated collects the values of repeated keys while converting an
+// object, telling them apart from array values
+type repeated []any
+
+// decodeError prefixes err with the path of the value that failed
+func decodeError(path string, err error) error {
+	if err == nil || path == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %v", path, err)
+}
+
+// keyPath appends an object key to a dotted path
+func keyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// indexPath appends an array index to a dotted path
+func indexPath(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
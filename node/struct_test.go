@@ -0,0 +1,353 @@
+package node
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Meta struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type Port struct {
+	Name string `yaml:"name"`
+	Port int    `yaml:"port"`
+}
+
+type Service struct {
+	Meta
+	ID       uint64         `xml:"id,attr"`
+	Replicas int            `transformer:"replicas" json:"count"`
+	Ratio    float64        `json:"ratio,omitempty"`
+	Debug    bool           `json:"debug,string"`
+	Ports    []Port         `json:"ports"`
+	Addr     netip.Addr     `json:"addr"`
+	Created  time.Time      `json:"created,omitempty"`
+	Timeout  *int           `json:"timeout"`
+	Secret   string         `json:"-"`
+	Extra    map[string]any `yaml:",inline"`
+	internal string
+}
+
+func TestFromStruct(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    any
+		opts    StructOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "service",
+			data: Service{
+				Meta:     Meta{Name: "api"},
+				ID:       7,
+				Replicas: 3,
+				Debug:    true,
+				Ports:    []Port{{Name: "http", Port: 80}},
+				Addr:     netip.MustParseAddr("10.0.0.1"),
+				Secret:   "hidden",
+				Extra:    map[string]any{"zone": "eu", "tier": 1},
+				internal: "hidden",
+			},
+			want: `{"name":"api","@id":7,"replicas":3,"debug":"true","ports":[{"name":"http","port":80}],"addr":"10.0.0.1","timeout":null,"tier":1,"zone":"eu"}`,
+		},
+		{
+			name: "omit empty",
+			data: &Port{Name: "http"},
+			opts: StructOptions{OmitEmpty: true},
+			want: `{"name":"http"}`,
+		},
+		{
+			name: "embedded pointer",
+			data: struct {
+				*Meta
+				Kind string `json:"kind"`
+			}{Kind: "Pod"},
+			want: `{"kind":"Pod"}`,
+		},
+		{
+			name: "bytes and time",
+			data: struct {
+				Data []byte    `json:"data"`
+				At   time.Time `json:"at"`
+			}{[]byte("hi"), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+			want: `{"data":"aGk=","at":"2024-05-01T00:00:00Z"}`,
+		},
+		{
+			name: "xml attr with json name",
+			data: struct {
+				ID   int    `json:"id" xml:"id,attr"`
+				Name string `json:"name" xml:"title"`
+			}{ID: 7, Name: "web"},
+			want: `{"@id":7,"name":"web"}`,
+		},
+		{
+			name: "attribute object",
+			data: struct {
+				M Meta `xml:"m,attr"`
+			}{},
+			wantErr: true,
+		},
+		{
+			name:    "channel",
+			data:    struct{ C chan int }{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromStruct(tt.data, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromStruct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if s := valueJSON(got.Value); s != tt.want {
+				t.Errorf("FromStruct() = %s, want %s", s, tt.want)
+			}
+			if err := got.Validate(); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+		})
+	}
+}
+
+type linked struct {
+	Name string  `json:"name"`
+	Next *linked `json:"next"`
+}
+
+func TestFromStruct_Cycles(t *testing.T) {
+	loop := &linked{Name: "a"}
+	loop.Next = &linked{Name: "b", Next: loop}
+
+	self := map[string]any{}
+	self["self"] = self
+
+	list := []any{nil}
+	list[0] = list
+
+	for name, data := range map[string]any{"pointer": loop, "map": self, "slice": list} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := FromStruct(data, StructOptions{}); err == nil || !strings.Contains(err.Error(), "cycle") {
+				t.Errorf("FromStruct() error = %v, want a cycle error", err)
+			}
+			if _, err := ValueOf(data); err == nil {
+				t.Error("ValueOf() expected error")
+			}
+		})
+	}
+
+	// The same pointer reached twice without a cycle is not one
+	shared := &linked{Name: "shared"}
+	got, err := FromStruct([]*linked{shared, shared}, StructOptions{})
+	if err != nil {
+		t.Fatalf("FromStruct() error = %v", err)
+	}
+	if want := `[{"name":"shared","next":null},{"name":"shared","next":null}]`; valueJSON(got.Value) != want {
+		t.Errorf("FromStruct() = %s, want %s", valueJSON(got.Value), want)
+	}
+}
+
+func TestDecode_RoundTrip(t *testing.T) {
+	timeout := 30
+	in := Service{
+		Meta:     Meta{Name: "api", Labels: map[string]string{"app": "api"}},
+		ID:       18446744073709551615,
+		Replicas: 3,
+		Ratio:    0.25,
+		Debug:    true,
+		Ports:    []Port{{Name: "http", Port: 80}, {Name: "https", Port: 443}},
+		Addr:     netip.MustParseAddr("::1"),
+		Created:  time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC),
+		Timeout:  &timeout,
+		Extra:    map[string]any{"zone": "eu"},
+	}
+
+	n, err := FromStruct(in, StructOptions{Key: "service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Key != "service" {
+		t.Errorf("FromStruct() key = %q, want service", n.Key)
+	}
+
+	var out Service
+	if err := Decode(n, &out); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Decode() = %+v, want %+v", out, in)
+	}
+}
+
+func TestDecode_Xml(t *testing.T) {
+	// Repeated elements are sibling members, attributes are "@" members
+	// and element text is typed by the decoder
+	type Server struct {
+		ID   int    `xml:"id,attr"`
+		Host string `xml:"host"`
+	}
+	type Config struct {
+		Version string   `xml:"version"`
+		Servers []Server `xml:"server"`
+		Tags    []string `xml:"tag"`
+	}
+
+	n := keyedTree(
+		"version", 1.5,
+		"server", map[string]any{"@id": "1", "host": "a"},
+		"server", map[string]any{"@id": "2", "host": "b"},
+		"tag", "solo",
+	)
+
+	var cfg Config
+	if err := Decode(n, &cfg); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	want := Config{
+		Version: "1.5",
+		Servers: []Server{{ID: 1, Host: "a"}, {ID: 2, Host: "b"}},
+		Tags:    []string{"solo"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Decode() = %+v, want %+v", cfg, want)
+	}
+
+	// Attribute fields read the plain keys of formats without attributes
+	type Port struct {
+		Name string `json:"name" xml:"name,attr"`
+		Port int    `json:"port"`
+	}
+	for _, tree := range []*Node{keyedTree("name", "http", "port", 80), keyedTree("@name", "http", "port", 80)} {
+		var port Port
+		if err := Decode(tree, &port); err != nil || port != (Port{Name: "http", Port: 80}) {
+			t.Errorf("Decode() = %+v, %v", port, err)
+		}
+	}
+}
+
+func TestDecode_Any(t *testing.T) {
+	n := keyedTree("name", "api", "port", 80, "ratio", 0.5, "tags", []any{"a", true, nil}, "tag", "x", "tag", "y")
+
+	var out any
+	if err := Decode(n, &out); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	want := map[string]any{
+		"name":  "api",
+		"port":  int64(80),
+		"ratio": 0.5,
+		"tags":  []any{"a", true, nil},
+		"tag":   []any{"x", "y"},
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("Decode() = %#v, want %#v", out, want)
+	}
+}
+
+func TestDecode_MapKeys(t *testing.T) {
+	in := map[int]map[uint16]string{-2: {443: "https"}, 10: {80: "http", 8080: "alt"}}
+	n, err := FromStruct(in, StructOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys are sorted as the text they are written as
+	var got []string
+	for member := n.Value.Node; member != nil; member = member.Next {
+		got = append(got, member.Key)
+	}
+	if !reflect.DeepEqual(got, []string{"-2", "10"}) {
+		t.Errorf("FromStruct() keys = %v, want [-2 10]", got)
+	}
+
+	var out map[int]map[uint16]string
+	if err := Decode(n, &out); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Decode() = %v, want %v", out, in)
+	}
+}
+
+func TestDecode_Null(t *testing.T) {
+	type Config struct {
+		Tags   []string          `json:"tags"`
+		Ports  *[]int            `json:"ports"`
+		Labels map[string]string `json:"labels"`
+		Name   string            `json:"name"`
+	}
+	n := keyedTree("tags", nil, "ports", nil, "labels", nil, "name", nil)
+
+	// Like encoding/json, null clears what can be nil and keeps the rest
+	ports := []int{80}
+	cfg := Config{Tags: []string{"a"}, Ports: &ports, Labels: map[string]string{"a": "b"}, Name: "api"}
+	if err := Decode(n, &cfg); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if cfg.Tags != nil || cfg.Ports != nil || cfg.Labels != nil || cfg.Name != "api" {
+		t.Errorf("Decode() = %#v, want nil tags, ports and labels and name api", cfg)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		tree *Node
+		out  any
+		want string
+	}{
+		{
+			name: "type mismatch",
+			tree: newTree(map[string]any{"ports": []any{map[string]any{"port": "http"}}}),
+			out:  &Service{},
+			want: "ports[0].port: cannot convert string value to number",
+		},
+		{
+			name: "overflow",
+			tree: newTree(300),
+			out:  new(int8),
+			want: "overflows int8",
+		},
+		{
+			name: "object into string",
+			tree: newTree(map[string]any{"name": map[string]any{}}),
+			out:  &Meta{},
+			want: "name: cannot convert object value to string",
+		},
+		{
+			name: "not a pointer",
+			tree: newTree(1),
+			out:  Meta{},
+			want: "non-nil pointer",
+		},
+		{
+			name: "invalid map key",
+			tree: newTree(map[string]any{"1": "a", "x": "b"}),
+			out:  &map[int]string{},
+			want: `x: invalid map key "x" for int`,
+		},
+		{
+			name: "map key overflow",
+			tree: newTree(map[string]any{"300": 1}),
+			out:  &map[uint8]int{},
+			want: `invalid map key "300" for uint8`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Decode(tt.tree, tt.out)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decode() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
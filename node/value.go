@@ -0,0 +1,252 @@
+package node
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewString creates a string value
+func NewString(s string) *Value {
+	return &Value{Type: TypeString, Worth: s}
+}
+
+// NewNumber creates an integer number value
+func NewNumber(i int64) *Value {
+	return &Value{Type: TypeNumber, Worth: strconv.FormatInt(i, 10), Kind: NumberInt}
+}
+
+// NewFloat creates a floating point number value using the shortest
+// representation that round-trips
+func NewFloat(f float64) *Value {
+	worth := strconv.FormatFloat(f, 'g', -1, 64)
+	return &Value{Type: TypeNumber, Worth: worth, Kind: ClassifyNumber(worth)}
+}
+
+// NewBool creates a boolean value
+func NewBool(b bool) *Value {
+	return &Value{Type: TypeBoolean, Worth: strconv.FormatBool(b)}
+}
+
+// NewTimestamp creates a timestamp value holding t in RFC 3339 form
+func NewTimestamp(t time.Time) *Value {
+	return &Value{Type: TypeTimestamp, Worth: t.Format(time.RFC3339Nano)}
+}
+
+// NewBinary creates a binary value holding b in base64
+func NewBinary(b []byte) *Value {
+	return &Value{Type: TypeBinary, Worth: base64.StdEncoding.EncodeToString(b)}
+}
+
+// NewNull creates a null value
+func NewNull() *Value {
+	return &Value{Type: TypeNull}
+}
+
+// NewArray creates an array value holding items.
+// Object items are wrapped like the format decoders do, see NewItem.
+func NewArray(items ...*Value) *Value {
+	array := &Value{Type: TypeArray, Array: make([]*Value, 0, len(items))}
+	for i, item := range items {
+		if item == nil {
+			item = NewNull()
+		}
+		array.Array = append(array.Array, NewItem(i, item))
+	}
+	return array
+}
+
+// NewObject creates an object value holding members.
+// The members are linked in the given order; their parent is set once
+// the object is added to a node with AddToValue.
+func NewObject(members ...*Node) *Value {
+	object := &Value{Type: TypeObject}
+	for _, member := range members {
+		if member == nil {
+			continue
+		}
+		value := member.Value
+		if value == nil {
+			value = NewNull()
+		}
+		appendMember(object, member.Key, value)
+	}
+	return object
+}
+
+// ValueOf converts a Go value to a Value. It accepts nil, *Value, strings,
+// booleans, integer and floating point types, time.Time (stored as a
+// timestamp), byte slices (stored as binary), slices and arrays, maps with
+// string or integer keys, whose members are sorted by key, and structs as
+// described by FromStruct.
+func ValueOf(data any) (*Value, error) {
+	e := &structEncoder{}
+	return e.encode(reflect.ValueOf(data))
+}
+
+// Set stores data at the location addressed by a dotted path, see
+// SetByPath. The type of the value is inferred from data as by ValueOf.
+func (n *Node) Set(path string, data any) error {
+	value, err := ValueOf(data)
+	if err != nil {
+		return err
+	}
+	return n.SetByPath(path, value)
+}
+
+// Int64 returns a number value as int64. Numbers with a fractional part
+// or outside the int64 range are an error.
+func (v *Value) Int64() (int64, error) {
+	if err := v.expect(TypeNumber); err != nil {
+		return 0, err
+	}
+	if i, err := strconv.ParseInt(v.Worth, 10, 64); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(v.Worth, 64)
+	if err != nil || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("number %s is not an int64", v.Worth)
+	}
+	return int64(f), nil
+}
+
+// Float64 returns a number value as float64
+func (v *Value) Float64() (float64, error) {
+	if err := v.expect(TypeNumber); err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(v.Worth, 64)
+	if err != nil {
+		return 0, fmt.Errorf("number %s is not a float64", v.Worth)
+	}
+	return f, nil
+}
+
+// Bool returns a boolean value
+func (v *Value) Bool() (bool, error) {
+	if err := v.expect(TypeBoolean); err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(v.Worth)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean %q", v.Worth)
+	}
+	return b, nil
+}
+
+// StringVal returns the text of a scalar value. Strings are returned as
+// they are, numbers and booleans in their stored form, which keeps values
+// like XML element text usable whatever type the decoder inferred.
+func (v *Value) StringVal() (string, error) {
+	if v == nil {
+		return "", fmt.Errorf("value is nil")
+	}
+	switch v.Type {
+	case TypeString, TypeNumber, TypeBoolean, TypeTimestamp, TypeBinary:
+		return v.Worth, nil
+	default:
+		return "", fmt.Errorf("cannot convert %s value to string", v.Type)
+	}
+}
+
+// timestampLayouts are the forms of a timestamp tried by AsTime: RFC 3339
+// and the date-only form, then the looser forms YAML and TOML allow
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	time.DateOnly,
+	"2006-1-2T15:4:5.999999999Z07:00",
+	"2006-1-2 15:4:5.999999999Z07:00",
+	"2006-1-2T15:4:5.999999999",
+	"2006-1-2 15:4:5.999999999",
+	"2006-1-2",
+	"15:04:05.999999999",
+}
+
+// AsTime parses a string or timestamp value as a time. Without layouts
+// RFC 3339 (with optional fractional seconds) and the date-only form
+// 2006-01-02 are tried, as well as the other forms of a YAML timestamp
+// or TOML datetime, with the T and Z of either in any case.
+func (v *Value) AsTime(layouts ...string) (time.Time, error) {
+	if v == nil || v.Type != TypeTimestamp {
+		if err := v.expect(TypeString); err != nil {
+			return time.Time{}, err
+		}
+	}
+	text := v.Worth
+	if len(layouts) == 0 {
+		// time.Parse only reads an uppercase T and Z
+		layouts, text = timestampLayouts, strings.ToUpper(text)
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as time", v.Worth)
+}
+
+// Bytes returns the data of a binary value
+func (v *Value) Bytes() ([]byte, error) {
+	if err := v.expect(TypeBinary); err != nil {
+		return nil, err
+	}
+	// Encoders may wrap long base64 text over several lines
+	b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v.Worth), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid binary value: %v", err)
+	}
+	return b, nil
+}
+
+// BinaryFormat selects the text form of binary values in formats without
+// a binary type
+type BinaryFormat int
+
+const (
+	BinaryBase64 BinaryFormat = iota // standard base64, as stored in Worth
+	BinaryHex                        // lowercase hexadecimal
+)
+
+// FormatBinary returns the data of a binary value in the given text form
+func (v *Value) FormatBinary(format BinaryFormat) (string, error) {
+	b, err := v.Bytes()
+	if err != nil {
+		return "", err
+	}
+	if format == BinaryHex {
+		return hex.EncodeToString(b), nil
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// FormatTime returns a timestamp value formatted with layout, or as it
+// was read when layout is empty
+func (v *Value) FormatTime(layout string) (string, error) {
+	if err := v.expect(TypeTimestamp); err != nil {
+		return "", err
+	}
+	if layout == "" {
+		return v.Worth, nil
+	}
+	t, err := v.AsTime()
+	if err != nil {
+		return "", err
+	}
+	return t.Format(layout), nil
+}
+
+// expect returns an error unless v is a value of type t
+func (v *Value) expect(t ValueType) error {
+	if v == nil {
+		return fmt.Errorf("value is nil")
+	}
+	if v.Type != t {
+		return fmt.Errorf("cannot convert %s value to %s", v.Type, t)
+	}
+	return nil
+}
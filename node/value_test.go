@@ -0,0 +1,271 @@
+package node
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestValue_Int64(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   *Value
+		want    int64
+		wantErr bool
+	}{
+		{name: "integer", value: NewNumber(42), want: 42},
+		{name: "negative", value: &Value{Type: TypeNumber, Worth: "-7"}, want: -7},
+		{name: "integral float", value: &Value{Type: TypeNumber, Worth: "3.0"}, want: 3},
+		{name: "exponent", value: &Value{Type: TypeNumber, Worth: "1e3"}, want: 1000},
+		{name: "max", value: NewNumber(math.MaxInt64), want: math.MaxInt64},
+		{name: "fraction", value: NewFloat(1.5), wantErr: true},
+		{name: "overflow", value: &Value{Type: TypeNumber, Worth: "9223372036854775808"}, wantErr: true},
+		{name: "string", value: NewString("42"), wantErr: true},
+		{name: "nil", value: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.value.Int64()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Int64() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Int64() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValue_Float64(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   *Value
+		want    float64
+		wantErr bool
+	}{
+		{name: "float", value: NewFloat(2.5), want: 2.5},
+		{name: "integer", value: NewNumber(2), want: 2},
+		{name: "invalid", value: &Value{Type: TypeNumber, Worth: "abc"}, wantErr: true},
+		{name: "boolean", value: NewBool(true), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.value.Float64()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Float64() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Float64() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValue_Bool(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   *Value
+		want    bool
+		wantErr bool
+	}{
+		{name: "true", value: NewBool(true), want: true},
+		{name: "false", value: NewBool(false), want: false},
+		{name: "capitalized", value: &Value{Type: TypeBoolean, Worth: "True"}, want: true},
+		{name: "invalid", value: &Value{Type: TypeBoolean, Worth: "yes"}, wantErr: true},
+		{name: "string", value: NewString("true"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.value.Bool()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Bool() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Bool() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValue_StringVal(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   *Value
+		want    string
+		wantErr bool
+	}{
+		{name: "string", value: NewString("hello"), want: "hello"},
+		{name: "number", value: NewNumber(2134), want: "2134"},
+		{name: "boolean", value: NewBool(false), want: "false"},
+		{name: "null", value: NewNull(), wantErr: true},
+		{name: "array", value: NewArray(), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.value.StringVal()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StringVal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("StringVal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValue_AsTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   *Value
+		layouts []string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "rfc3339", value: NewString("2024-05-01T10:30:00Z"), want: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{name: "fraction", value: NewString("2024-05-01T10:30:00.5Z"), want: time.Date(2024, 5, 1, 10, 30, 0, 5e8, time.UTC)},
+		{name: "date", value: NewString("2024-05-01"), want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "layout", value: NewString("01/05/2024"), layouts: []string{"02/01/2006"}, want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "timestamp", value: NewTimestamp(time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)), want: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{name: "yaml timestamp", value: &Value{Type: TypeTimestamp, Worth: "2001-12-14 21:59:43.10"}, want: time.Date(2001, 12, 14, 21, 59, 43, 1e8, time.UTC)},
+		{name: "lowercase t and z", value: NewString("2024-05-01t10:30:00z"), want: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{name: "toml local datetime", value: &Value{Type: TypeTimestamp, Worth: "1979-05-27T07:32:00"}, want: time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+		{name: "toml local time", value: &Value{Type: TypeTimestamp, Worth: "07:32:00.5"}, want: time.Date(0, 1, 1, 7, 32, 0, 5e8, time.UTC)},
+		{name: "invalid", value: NewString("yesterday"), wantErr: true},
+		{name: "number", value: NewNumber(1714559400), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.value.AsTime(tt.layouts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AsTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("AsTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValue_Binary(t *testing.T) {
+	v := NewBinary([]byte("hello"))
+	if v.Type != TypeBinary || v.Worth != "aGVsbG8=" {
+		t.Fatalf("NewBinary() = %s %q", v.Type, v.Worth)
+	}
+
+	wrapped := &Value{Type: TypeBinary, Worth: "aGVs\nbG8="}
+	if b, err := wrapped.Bytes(); err != nil || string(b) != "hello" {
+		t.Errorf("Bytes() = %q, %v, want hello", b, err)
+	}
+	if got, err := v.FormatBinary(BinaryHex); err != nil || got != "68656c6c6f" {
+		t.Errorf("FormatBinary(BinaryHex) = %q, %v", got, err)
+	}
+	if _, err := NewString("hello").Bytes(); err == nil {
+		t.Error("Bytes() of a string expected error")
+	}
+}
+
+func TestValue_FormatTime(t *testing.T) {
+	v := &Value{Type: TypeTimestamp, Worth: "2024-05-01"}
+	if got, err := v.FormatTime(""); err != nil || got != "2024-05-01" {
+		t.Errorf("FormatTime(\"\") = %q, %v", got, err)
+	}
+	if got, err := v.FormatTime(time.RFC3339); err != nil || got != "2024-05-01T00:00:00Z" {
+		t.Errorf("FormatTime(RFC3339) = %q, %v", got, err)
+	}
+}
+
+func TestValueOf(t *testing.T) {
+	type label string
+
+	tests := []struct {
+		name    string
+		data    any
+		want    *Value
+		wantErr bool
+	}{
+		{name: "nil", data: nil, want: NewNull()},
+		{name: "string", data: "x", want: NewString("x")},
+		{name: "named string", data: label("web"), want: NewString("web")},
+		{name: "int", data: 42, want: NewNumber(42)},
+		{name: "uint64", data: uint64(math.MaxUint64), want: &Value{Type: TypeNumber, Worth: "18446744073709551615"}},
+		{name: "float", data: 0.25, want: NewFloat(0.25)},
+		{name: "bool", data: true, want: NewBool(true)},
+		{name: "time", data: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), want: &Value{Type: TypeTimestamp, Worth: "2024-05-01T00:00:00Z"}},
+		{name: "bytes", data: []byte("hi"), want: &Value{Type: TypeBinary, Worth: "aGk="}},
+		{name: "pointer", data: new(int), want: NewNumber(0)},
+		{name: "slice", data: []any{1, "a", nil}, want: NewArray(NewNumber(1), NewString("a"), NewNull())},
+		{name: "map", data: map[string]int{"b": 2, "a": 1}, want: fromAny(map[string]any{"a": 1, "b": 2})},
+		{name: "nested", data: []map[string]any{{"a": []int{1}}}, want: fromAny([]any{map[string]any{"a": []any{1}}})},
+		{name: "value", data: NewBool(false), want: NewBool(false)},
+		{name: "NaN", data: math.NaN(), wantErr: true},
+		{name: "int map keys", data: map[int]string{10: "b", -1: "a"}, want: fromAny(map[string]any{"-1": "a", "10": "b"})},
+		{name: "map key", data: map[bool]string{true: "a"}, wantErr: true},
+		{name: "func", data: func() {}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValueOf(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValueOf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (!got.Equal(tt.want) || got.Worth != tt.want.Worth) {
+				t.Errorf("ValueOf() = %s, want %s", valueJSON(got), valueJSON(tt.want))
+			}
+		})
+	}
+}
+
+func TestNode_Set(t *testing.T) {
+	root := NewNode("root")
+	sets := []struct {
+		path string
+		data any
+	}{
+		{"metadata.name", "web"},
+		{"spec.replicas", 3},
+		{"spec.paused", false},
+		{"spec.ports", []int{80, 443}},
+		{"spec.ports[-]", 8080},
+		{"spec.selector", map[string]string{"app": "web"}},
+	}
+	for _, s := range sets {
+		if err := root.Set(s.path, s.data); err != nil {
+			t.Fatalf("Set(%q) error = %v", s.path, err)
+		}
+	}
+
+	want := `{"metadata":{"name":"web"},"spec":{"replicas":3,"paused":false,"ports":[80,443,8080],"selector":{"app":"web"}}}`
+	if got := valueJSON(root.Value); got != want {
+		t.Errorf("Set() built %s, want %s", got, want)
+	}
+	if err := root.Validate(); err != nil {
+		t.Errorf("Validate() after Set() error = %v", err)
+	}
+
+	if err := root.Set("spec.bad", make(chan int)); err == nil {
+		t.Error("Set() with unsupported type expected error")
+	}
+}
+
+func TestNewObject(t *testing.T) {
+	name := NewNode("name")
+	name.AddToValue(NewString("web"))
+	port := NewNode("port")
+	port.AddToValue(NewNumber(80))
+
+	root := NewNode("root")
+	root.AddToValue(NewObject(name, port))
+
+	if got, want := valueJSON(root.Value), `{"name":"web","port":80}`; got != want {
+		t.Errorf("NewObject() = %s, want %s", got, want)
+	}
+	if err := root.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
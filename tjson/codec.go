@@ -0,0 +1,51 @@
+package tjson
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/mstgnz/transformer"
+	"github.com/mstgnz/transformer/node"
+)
+
+func init() {
+	transformer.Register(transformer.FormatJson, Codec{})
+}
+
+// Codec implements transformer.Codec for JSON.
+// The zero value encodes with the defaults of NodeToJson.
+type Codec struct {
+	EncodeOptions JsonEncodeOptions
+}
+
+var _ transformer.Codec = Codec{}
+
+// Decode reads JSON from r and decodes it into a Node
+func (Codec) Decode(r io.Reader) (*node.Node, error) {
+	return DecodeJsonReader(r)
+}
+
+// Encode writes the JSON form of n to w
+func (c Codec) Encode(w io.Writer, n *node.Node) error {
+	return EncodeToWithOptions(w, n, c.EncodeOptions)
+}
+
+// Detect reports whether data is valid JSON
+func (Codec) Detect(data []byte) bool {
+	return IsJson(data)
+}
+
+// Sniff rates how likely data is JSON.
+// Objects and arrays are certain matches, bare scalars score lower
+// because they are just as valid in other formats.
+func (Codec) Sniff(data []byte) float64 {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 || !IsJson(data) {
+		return 0
+	}
+	switch trimmed[0] {
+	case '{', '[':
+		return 1
+	}
+	return 0.6
+}
@@ -0,0 +1,46 @@
+package tjson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	var codec Codec
+	n, err := codec.Decode(strings.NewReader(`{"b": 1, "a": [true, null]}`))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, n); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if want := `{"b":1,"a":[true,null]}`; buf.String() != want {
+		t.Errorf("Encode() = %s, want %s", buf.String(), want)
+	}
+}
+
+func TestCodec_Sniff(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want float64
+	}{
+		{name: "object", data: `{"a": 1}`, want: 1},
+		{name: "array with leading space", data: "\n [1]", want: 1},
+		{name: "string scalar", data: `"text"`, want: 0.6},
+		{name: "yaml", data: "a: 1", want: 0},
+		{name: "truncated object", data: `{"a": `, want: 0},
+		{name: "empty", data: "", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Codec{}).Sniff([]byte(tt.data)); got != tt.want {
+				t.Errorf("Sniff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
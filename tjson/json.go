@@ -3,10 +3,17 @@
 package tjson
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/mstgnz/transformer/node"
 )
@@ -28,123 +35,129 @@ func ReadJson(filename string) ([]byte, error) {
 	return data, nil
 }
 
-// DecodeJson decodes JSON bytes into a Node
+// DecodeJson decodes JSON bytes into a Node.
+// The input is read token by token so object keys keep their source order.
 func DecodeJson(data []byte) (*node.Node, error) {
-	var jsonData any
-	if err := json.Unmarshal(data, &jsonData); err != nil {
+	return DecodeJsonReader(bytes.NewReader(data))
+}
+
+// DecodeJsonReader decodes a JSON document read from r into a Node.
+// Tokens are consumed as they arrive, so the input is never buffered as a whole.
+func DecodeJsonReader(r io.Reader) (*node.Node, error) {
+	dec := json.NewDecoder(r)
+	// Keep number literals as written, float64 would round large integers
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	return interfaceToNode("root", jsonData), nil
-}
+	root, err := tokenToNode(dec, "root", tok)
+	if err != nil {
+		return nil, err
+	}
 
-// interfaceToNode converts an any to a Node
-func interfaceToNode(key string, data any) *node.Node {
-	if data == nil {
-		return &node.Node{
-			Key: key,
-			Value: &node.Value{
-				Type: node.TypeNull,
-			},
+	// Only a single top-level value is allowed
+	if _, err := dec.Token(); err != io.EOF {
+		if err != nil {
+			return nil, err
 		}
+		return nil, fmt.Errorf("invalid JSON: unexpected data after top-level value")
 	}
 
-	switch v := data.(type) {
-	case map[string]any:
-		n := &node.Node{
-			Key: key,
-			Value: &node.Value{
-				Type: node.TypeObject,
-			},
+	return root, nil
+}
+
+// tokenToNode converts the value starting with tok into a Node
+func tokenToNode(dec *json.Decoder, key string, tok json.Token) (*node.Node, error) {
+	n := &node.Node{
+		Key: key,
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		n.Value = valueFromInterface(tok)
+		return n, nil
+	}
+
+	switch delim {
+	case '{':
+		n.Value = &node.Value{
+			Type: node.TypeObject,
 		}
 
 		var last *node.Node
-		for k, val := range v {
-			child := interfaceToNode(k, val)
-			if child != nil {
-				child.Parent = n
-				if last == nil {
-					n.Value.Node = child
-				} else {
-					last.Next = child
-					child.Prev = last
-				}
-				last = child
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			childKey, ok := keyTok.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid JSON: object key is not a string")
 			}
-		}
-		return n
-
-	case []any:
-		n := &node.Node{
-			Key: key,
-			Value: &node.Value{
-				Type:  node.TypeArray,
-				Array: make([]*node.Value, len(v)),
-			},
-		}
 
-		for i, item := range v {
-			if item == nil {
-				n.Value.Array[i] = &node.Value{Type: node.TypeNull}
-				continue
+			valTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			child, err := tokenToNode(dec, childKey, valTok)
+			if err != nil {
+				return nil, err
 			}
 
-			switch val := item.(type) {
-			case map[string]any:
-				child := interfaceToNode(fmt.Sprintf("item%d", i), val)
-				if child != nil {
-					n.Value.Array[i] = &node.Value{
-						Type: node.TypeObject,
-						Node: child,
-					}
-				}
-			case []any:
-				child := interfaceToNode(fmt.Sprintf("item%d", i), val)
-				if child != nil {
-					n.Value.Array[i] = child.Value
-				}
-			default:
-				n.Value.Array[i] = valueFromInterface(val)
+			child.Parent = n
+			if last == nil {
+				n.Value.Node = child
+			} else {
+				last.Next = child
+				child.Prev = last
 			}
+			last = child
 		}
-		return n
 
-	case string:
-		return &node.Node{
-			Key: key,
-			Value: &node.Value{
-				Type:  node.TypeString,
-				Worth: v,
-			},
+	case '[':
+		n.Value = &node.Value{
+			Type:  node.TypeArray,
+			Array: make([]*node.Value, 0),
 		}
 
-	case float64:
-		return &node.Node{
-			Key: key,
-			Value: &node.Value{
-				Type:  node.TypeNumber,
-				Worth: strconv.FormatFloat(v, 'f', -1, 64),
-			},
-		}
+		for i := 0; dec.More(); i++ {
+			itemTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
 
-	case bool:
-		return &node.Node{
-			Key: key,
-			Value: &node.Value{
-				Type:  node.TypeBoolean,
-				Worth: strconv.FormatBool(v),
-			},
+			child, err := tokenToNode(dec, fmt.Sprintf("item%d", i), itemTok)
+			if err != nil {
+				return nil, err
+			}
+
+			switch child.Value.Type {
+			case node.TypeObject:
+				n.Value.Array = append(n.Value.Array, &node.Value{
+					Type: node.TypeObject,
+					Node: child,
+				})
+			default:
+				n.Value.Array = append(n.Value.Array, child.Value)
+			}
 		}
 
 	default:
-		return &node.Node{
-			Key: key,
-			Value: &node.Value{
-				Type:  node.TypeString,
-				Worth: fmt.Sprint(v),
-			},
-		}
+		return nil, fmt.Errorf("invalid JSON: unexpected delimiter %q", delim)
 	}
+
+	// Consume the closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return n, nil
 }
 
 // valueFromInterface creates a Value from an any
@@ -159,6 +172,14 @@ func valueFromInterface(data any) *node.Value {
 			Type:  node.TypeString,
 			Worth: v,
 		}
+	case json.Number:
+		if number, err := node.ParseNumber(v.String()); err == nil {
+			return number
+		}
+		return &node.Value{
+			Type:  node.TypeNumber,
+			Worth: v.String(),
+		}
 	case float64:
 		return &node.Value{
 			Type:  node.TypeNumber,
@@ -177,93 +198,321 @@ func valueFromInterface(data any) *node.Value {
 	}
 }
 
-// NodeToJson converts a Node to JSON bytes
+// TagFormat selects how values carrying a tag, such as CloudFormation's
+// YAML !Ref, are written
+type TagFormat int
+
+const (
+	// TagDrop writes the value alone
+	TagDrop TagFormat = iota
+	// TagWrap writes an object with a single member keyed by the tag as
+	// written, e.g. {"!Ref": "Bucket"}
+	TagWrap
+	// TagCloudFormation writes the CloudFormation JSON form of its YAML short
+	// form tags: !Ref as {"Ref": ...}, !Condition as {"Condition": ...} and
+	// other tags as {"Fn::<name>": ...}, with the "resource.attribute" text
+	// of !GetAtt split into a list
+	TagCloudFormation
+)
+
+// JsonEncodeOptions configures NodeToJsonWithOptions.
+// The zero value is the default used by NodeToJson.
+type JsonEncodeOptions struct {
+	// TimeLayout reformats timestamp values with a time layout, e.g.
+	// time.RFC3339; empty writes them as they were read
+	TimeLayout string
+	// Binary selects the text form of binary values
+	Binary node.BinaryFormat
+	// Tags selects how values carrying a tag are written
+	Tags TagFormat
+	// Indent is repeated once per nesting level at the start of every line
+	// of indented output; with Indent and Prefix empty the output is compact
+	Indent string
+	// Prefix starts every line of indented output but the first
+	Prefix string
+	// SortKeys writes object members sorted by key instead of in source order
+	SortKeys bool
+	// NoHTMLEscape writes <, > and & in strings as they are instead of
+	// escaping them as \u003c, \u003e and \u0026
+	NoHTMLEscape bool
+	// ASCII escapes every character outside ASCII in strings, so the output
+	// only holds ASCII characters
+	ASCII bool
+	// TrailingNewline ends the output with a newline
+	TrailingNewline bool
+}
+
+// NodeToJson converts a Node to JSON bytes.
+// Object members are written in the order of the Node.Next chain.
 func NodeToJson(n *node.Node) ([]byte, error) {
+	return NodeToJsonWithOptions(n, JsonEncodeOptions{})
+}
+
+// NodeToJsonWithOptions converts a Node to JSON bytes using the given options
+func NodeToJsonWithOptions(n *node.Node, opts JsonEncodeOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := EncodeToWithOptions(&buf, n, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeTo writes the JSON form of a Node to w.
+// Output is produced while walking the tree instead of being built in memory first.
+func EncodeTo(w io.Writer, n *node.Node) error {
+	return EncodeToWithOptions(w, n, JsonEncodeOptions{})
+}
+
+// EncodeToWithOptions writes the JSON form of a Node to w using the given options
+func EncodeToWithOptions(w io.Writer, n *node.Node, opts JsonEncodeOptions) error {
 	if n == nil {
-		return nil, fmt.Errorf("node is nil")
+		return fmt.Errorf("node is nil")
 	}
 
 	if n.Value == nil {
-		return nil, fmt.Errorf("node value is nil")
+		return fmt.Errorf("node value is nil")
 	}
 
-	data := nodeToInterface(n)
-	return json.Marshal(data)
+	e := &jsonEncoder{w: bufio.NewWriter(w), opts: opts}
+	if err := e.writeValue(n.Value); err != nil {
+		return err
+	}
+	if opts.TrailingNewline {
+		e.w.WriteByte('\n')
+	}
+	return e.w.Flush()
 }
 
-// nodeToInterface converts a Node to a generic any
-func nodeToInterface(n *node.Node) any {
-	if n == nil || n.Value == nil {
-		return nil
+// jsonEncoder writes the JSON form of a tree
+type jsonEncoder struct {
+	w     *bufio.Writer
+	opts  JsonEncodeOptions
+	depth int // nesting level of the value being written
+}
+
+// indented reports whether the output is written on indented lines
+func (e *jsonEncoder) indented() bool {
+	return e.opts.Indent != "" || e.opts.Prefix != ""
+}
+
+// newline starts a line of indented output at the current nesting level
+func (e *jsonEncoder) newline() {
+	if !e.indented() {
+		return
+	}
+	e.w.WriteByte('\n')
+	e.w.WriteString(e.opts.Prefix)
+	for i := 0; i < e.depth; i++ {
+		e.w.WriteString(e.opts.Indent)
 	}
+}
 
-	if n.Key == "root" {
-		switch n.Value.Type {
-		case node.TypeObject:
-			result := make(map[string]any)
-			current := n.Value.Node
-			for current != nil {
-				if current.Value != nil {
-					if current.Value.Type == node.TypeObject || current.Value.Type == node.TypeArray {
-						result[current.Key] = nodeToInterface(current)
-					} else {
-						result[current.Key] = convertValue(current.Value)
-					}
-				}
-				current = current.Next
-			}
-			return result
-
-		case node.TypeArray:
-			result := make([]any, 0)
-			for _, item := range n.Value.Array {
-				if item != nil {
-					if item.Node != nil {
-						result = append(result, nodeToInterface(item.Node))
-					} else {
-						result = append(result, convertValue(item))
-					}
-				}
-			}
-			return result
+// writeKey writes an object key and the colon following it
+func (e *jsonEncoder) writeKey(key string) {
+	e.writeString(key)
+	e.w.WriteByte(':')
+	if e.indented() {
+		e.w.WriteByte(' ')
+	}
+}
 
-		default:
-			return convertValue(n.Value)
-		}
+// writeValue writes the JSON form of a Value, wrapped in an object
+// naming its tag when the options ask for it
+func (e *jsonEncoder) writeValue(v *node.Value) error {
+	if v.Tag == "" || e.opts.Tags == TagDrop {
+		return e.writeContent(v)
 	}
 
-	switch n.Value.Type {
+	key, content := v.Tag, v
+	if e.opts.Tags == TagCloudFormation {
+		key, content = cloudFormation(v)
+	}
+	e.w.WriteByte('{')
+	e.depth++
+	e.newline()
+	e.writeKey(key)
+	if err := e.writeContent(content); err != nil {
+		return err
+	}
+	e.depth--
+	e.newline()
+	return e.w.WriteByte('}')
+}
+
+// writeContent writes the JSON form of a Value, leaving out its tag
+func (e *jsonEncoder) writeContent(v *node.Value) error {
+	w := e.w
+	switch v.Type {
 	case node.TypeObject:
-		result := make(map[string]any)
-		current := n.Value.Node
-		for current != nil {
+		var members []*node.Node
+		for current := v.Node; current != nil; current = current.Next {
 			if current.Value != nil {
-				if current.Value.Type == node.TypeObject || current.Value.Type == node.TypeArray {
-					result[current.Key] = nodeToInterface(current)
-				} else {
-					result[current.Key] = convertValue(current.Value)
-				}
+				members = append(members, current)
 			}
-			current = current.Next
 		}
-		return result
+		if e.opts.SortKeys {
+			sort.SliceStable(members, func(i, j int) bool {
+				return members[i].Key < members[j].Key
+			})
+		}
+
+		w.WriteByte('{')
+		e.depth++
+		for i, member := range members {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			e.newline()
+			e.writeKey(member.Key)
+			if err := e.writeValue(member.Value); err != nil {
+				return err
+			}
+		}
+		e.depth--
+		if len(members) > 0 {
+			e.newline()
+		}
+		w.WriteByte('}')
 
 	case node.TypeArray:
-		result := make([]any, 0)
-		for _, item := range n.Value.Array {
-			if item != nil {
-				if item.Node != nil {
-					result = append(result, nodeToInterface(item.Node))
-				} else {
-					result = append(result, convertValue(item))
+		w.WriteByte('[')
+		e.depth++
+		first := true
+		for _, item := range v.Array {
+			if item == nil {
+				continue
+			}
+			// Object items are stored behind a wrapper node
+			if item.Node != nil {
+				if item.Node.Value == nil {
+					continue
 				}
+				item = item.Node.Value
 			}
+			if !first {
+				w.WriteByte(',')
+			}
+			first = false
+			e.newline()
+			if err := e.writeValue(item); err != nil {
+				return err
+			}
+		}
+		e.depth--
+		if !first {
+			e.newline()
+		}
+		w.WriteByte(']')
+
+	case node.TypeNumber:
+		// Number literals are written as they were read, keeping precision
+		if node.IsNumberLiteral(v.Worth) {
+			_, err := w.WriteString(v.Worth)
+			return err
+		}
+		return writeScalar(w, convertValue(v))
+
+	case node.TypeTimestamp:
+		text, err := v.FormatTime(e.opts.TimeLayout)
+		if err != nil {
+			return err
 		}
-		return result
+		e.writeString(text)
+
+	case node.TypeBinary:
+		text, err := v.FormatBinary(e.opts.Binary)
+		if err != nil {
+			return err
+		}
+		e.writeString(text)
 
 	default:
-		return convertValue(n.Value)
+		data := convertValue(v)
+		if s, ok := data.(string); ok {
+			e.writeString(s)
+			return nil
+		}
+		return writeScalar(w, data)
+	}
+
+	return nil
+}
+
+// writeString writes a JSON string. Like encoding/json it escapes control
+// characters, U+2028 and U+2029, and replaces invalid UTF-8 with U+FFFD;
+// <, > and & and characters outside ASCII are escaped as the options ask.
+func (e *jsonEncoder) writeString(s string) {
+	w := e.w
+	w.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				w.WriteByte('\\')
+				w.WriteByte(c)
+			case c == '\n':
+				w.WriteString(`\n`)
+			case c == '\r':
+				w.WriteString(`\r`)
+			case c == '\t':
+				w.WriteString(`\t`)
+			case c == '\b':
+				w.WriteString(`\b`)
+			case c == '\f':
+				w.WriteString(`\f`)
+			case c < 0x20, !e.opts.NoHTMLEscape && (c == '<' || c == '>' || c == '&'):
+				fmt.Fprintf(w, `\u%04x`, c)
+			default:
+				w.WriteByte(c)
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '\u2028' || r == '\u2029':
+			fmt.Fprintf(w, `\u%04x`, r)
+		case e.opts.ASCII && r > 0xFFFF:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(w, `\u%04x\u%04x`, r1, r2)
+		case e.opts.ASCII:
+			fmt.Fprintf(w, `\u%04x`, r)
+		case r == utf8.RuneError:
+			w.WriteRune(r)
+		default:
+			w.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	w.WriteByte('"')
+}
+
+// cloudFormation returns the CloudFormation JSON key of a tagged value
+// and the value to write under it
+func cloudFormation(v *node.Value) (string, *node.Value) {
+	name := strings.TrimPrefix(v.Tag, "!")
+	content := &node.Value{Type: v.Type, Worth: v.Worth, Node: v.Node, Array: v.Array, Kind: v.Kind}
+	switch name {
+	case "Ref", "Condition":
+		return name, content
+	case "GetAtt":
+		if resource, attribute, ok := strings.Cut(v.Worth, "."); ok && v.Type == node.TypeString {
+			content = node.NewArray(node.NewString(resource), node.NewString(attribute))
+		}
+	}
+	return "Fn::" + name, content
+}
+
+// writeScalar writes a primitive Go value to w using encoding/json rules
+func writeScalar(w *bufio.Writer, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
 	}
+	_, err = w.Write(b)
+	return err
 }
 
 // convertValue converts a Value to a suitable any type
@@ -276,12 +525,13 @@ func convertValue(v *node.Value) any {
 	case node.TypeString:
 		return v.Worth
 	case node.TypeNumber:
-		if f, err := strconv.ParseFloat(v.Worth, 64); err == nil {
+		if f, err := v.Float64(); err == nil {
 			return f
 		}
 		return v.Worth
 	case node.TypeBoolean:
-		return v.Worth == "true"
+		b, _ := v.Bool()
+		return b
 	case node.TypeNull:
 		return nil
 	default:
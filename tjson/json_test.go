@@ -1,9 +1,14 @@
 package tjson
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/mstgnz/transformer/node"
@@ -199,3 +204,275 @@ func TestNodeToJson(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeJsonKeyOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "flat object",
+			data: []byte(`{"zeta": 1, "alpha": 2, "mid": 3}`),
+			want: `{"zeta":1,"alpha":2,"mid":3}`,
+		},
+		{
+			name: "nested objects and arrays",
+			data: []byte(`{"b": {"y": true, "x": null}, "a": [{"k2": "v", "k1": "w"}, [2, 1]]}`),
+			want: `{"b":{"y":true,"x":null},"a":[{"k2":"v","k1":"w"},[2,1]]}`,
+		},
+		{
+			name: "top-level array",
+			data: []byte(`["c", "b", "a"]`),
+			want: `["c","b","a"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := DecodeJson(tt.data)
+			if err != nil {
+				t.Fatalf("DecodeJson() error = %v", err)
+			}
+			got, err := NodeToJson(n)
+			if err != nil {
+				t.Fatalf("NodeToJson() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("NodeToJson() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJsonNumbers(t *testing.T) {
+	data := []byte(`{"id": 9007199254740993, "big": 123456789012345678901234567890, "price": 12.50, "rate": 1e-3, "n": -0}`)
+	n, err := DecodeJson(data)
+	if err != nil {
+		t.Fatalf("DecodeJson() error = %v", err)
+	}
+
+	kinds := map[string]node.NumberKind{
+		"id":    node.NumberInt,
+		"big":   node.NumberBig,
+		"price": node.NumberFloat,
+		"rate":  node.NumberFloat,
+		"n":     node.NumberInt,
+	}
+	for key, want := range kinds {
+		v, err := n.GetByPath(key)
+		if err != nil {
+			t.Fatalf("GetByPath(%q) error = %v", key, err)
+		}
+		if v.Kind != want {
+			t.Errorf("%s: Kind = %v, want %v", key, v.Kind, want)
+		}
+	}
+
+	got, err := NodeToJson(n)
+	if err != nil {
+		t.Fatalf("NodeToJson() error = %v", err)
+	}
+	if want := `{"id":9007199254740993,"big":123456789012345678901234567890,"price":12.50,"rate":1e-3,"n":-0}`; string(got) != want {
+		t.Errorf("NodeToJson() = %s, want %s", got, want)
+	}
+}
+
+func TestDecodeJsonTrailingData(t *testing.T) {
+	for _, data := range []string{``, `{} {}`, `{"a": 1} x`} {
+		if _, err := DecodeJson([]byte(data)); err == nil {
+			t.Errorf("DecodeJson(%q) expected error", data)
+		}
+	}
+}
+
+func TestDecodeJsonReader(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte(`{"name": "John", `))
+		pw.Write([]byte(`"tags": ["a", "b"]}`))
+		pw.Close()
+	}()
+
+	n, err := DecodeJsonReader(pr)
+	if err != nil {
+		t.Fatalf("DecodeJsonReader() error = %v", err)
+	}
+	if n.Value.Node == nil || n.Value.Node.Next == nil || n.Value.Node.Next.Key != "tags" {
+		t.Errorf("DecodeJsonReader() did not decode all members")
+	}
+
+	if _, err := DecodeJsonReader(strings.NewReader(`{"name": `)); err == nil {
+		t.Error("DecodeJsonReader() expected error for truncated input")
+	}
+}
+
+func TestEncodeTo(t *testing.T) {
+	n, err := DecodeJson([]byte(`{"name": "John", "tags": ["a", "b"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeTo(&buf, n); err != nil {
+		t.Fatalf("EncodeTo() error = %v", err)
+	}
+	if want := `{"name":"John","tags":["a","b"]}`; buf.String() != want {
+		t.Errorf("EncodeTo() = %s, want %s", buf.String(), want)
+	}
+
+	if err := EncodeTo(errWriter{}, n); err == nil {
+		t.Error("EncodeTo() expected error from failing writer")
+	}
+	if err := EncodeTo(&buf, nil); err == nil {
+		t.Error("EncodeTo() expected error for nil node")
+	}
+}
+
+// errWriter is an io.Writer that always fails
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestNodeToJsonWithOptions(t *testing.T) {
+	tagged := func(tag string, v *node.Value) *node.Value {
+		v.Tag = tag
+		return v
+	}
+	root := node.NewNode("root")
+	root.AddToValue(node.NewObject(
+		&node.Node{Key: "name", Value: tagged("!Sub", node.NewString("${AWS::StackName}-data"))},
+		&node.Node{Key: "owner", Value: tagged("!Ref", node.NewString("Owner"))},
+		&node.Node{Key: "arn", Value: tagged("!GetAtt", node.NewString("Bucket.Arn"))},
+		&node.Node{Key: "created", Value: &node.Value{Type: node.TypeTimestamp, Worth: "2024-05-01"}},
+		&node.Node{Key: "icon", Value: node.NewBinary([]byte("hi"))},
+	))
+
+	tests := []struct {
+		name string
+		opts JsonEncodeOptions
+		want string
+	}{
+		{
+			name: "default",
+			want: `{"name":"${AWS::StackName}-data","owner":"Owner","arn":"Bucket.Arn","created":"2024-05-01","icon":"aGk="}`,
+		},
+		{
+			name: "wrap tags",
+			opts: JsonEncodeOptions{Tags: TagWrap},
+			want: `{"name":{"!Sub":"${AWS::StackName}-data"},"owner":{"!Ref":"Owner"},"arn":{"!GetAtt":"Bucket.Arn"},` +
+				`"created":"2024-05-01","icon":"aGk="}`,
+		},
+		{
+			name: "cloudformation",
+			opts: JsonEncodeOptions{Tags: TagCloudFormation},
+			want: `{"name":{"Fn::Sub":"${AWS::StackName}-data"},"owner":{"Ref":"Owner"},"arn":{"Fn::GetAtt":["Bucket","Arn"]},` +
+				`"created":"2024-05-01","icon":"aGk="}`,
+		},
+		{
+			name: "time layout and hex",
+			opts: JsonEncodeOptions{TimeLayout: "2006-01-02T15:04:05Z07:00", Binary: node.BinaryHex},
+			want: `{"name":"${AWS::StackName}-data","owner":"Owner","arn":"Bucket.Arn","created":"2024-05-01T00:00:00Z","icon":"6869"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NodeToJsonWithOptions(root, tt.opts)
+			if err != nil {
+				t.Fatalf("NodeToJsonWithOptions() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("NodeToJsonWithOptions() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeToJsonWithOptions_Format(t *testing.T) {
+	root, err := DecodeJson([]byte(`{"b":"<a href=\"x\">café</a>","a":[1,{"z":true,"y":null}],"c":{},"d":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts JsonEncodeOptions
+		want string
+	}{
+		{
+			name: "default",
+			want: `{"b":"\u003ca href=\"x\"\u003ecafé\u003c/a\u003e","a":[1,{"z":true,"y":null}],"c":{},"d":[]}`,
+		},
+		{
+			name: "indent",
+			opts: JsonEncodeOptions{Indent: "  ", TrailingNewline: true},
+			want: "{\n  \"b\": \"\\u003ca href=\\\"x\\\"\\u003ecafé\\u003c/a\\u003e\",\n  \"a\": [\n    1,\n    {\n      \"z\": true,\n" +
+				"      \"y\": null\n    }\n  ],\n  \"c\": {},\n  \"d\": []\n}\n",
+		},
+		{
+			name: "prefix",
+			opts: JsonEncodeOptions{Prefix: "> ", Indent: "\t", SortKeys: true},
+			want: "{\n> \t\"a\": [\n> \t\t1,\n> \t\t{\n> \t\t\t\"y\": null,\n> \t\t\t\"z\": true\n> \t\t}\n> \t],\n" +
+				"> \t\"b\": \"\\u003ca href=\\\"x\\\"\\u003ecafé\\u003c/a\\u003e\",\n> \t\"c\": {},\n> \t\"d\": []\n> }",
+		},
+		{
+			name: "sort keys",
+			opts: JsonEncodeOptions{SortKeys: true, NoHTMLEscape: true},
+			want: `{"a":[1,{"y":null,"z":true}],"b":"<a href=\"x\">café</a>","c":{},"d":[]}`,
+		},
+		{
+			name: "no html escape and ascii",
+			opts: JsonEncodeOptions{NoHTMLEscape: true, ASCII: true},
+			want: `{"b":"<a href=\"x\">caf\u00e9</a>","a":[1,{"z":true,"y":null}],"c":{},"d":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NodeToJsonWithOptions(root, tt.opts)
+			if err != nil {
+				t.Fatalf("NodeToJsonWithOptions() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("NodeToJsonWithOptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeToJsonWithOptions_Escapes(t *testing.T) {
+	for _, s := range []string{"plain", "quote \" backslash \\", "\x00\x1f\b\f\n\r\t", "  ", "emoji 😀", "bad \xff"} {
+		root := node.NewNode("root")
+		root.AddToValue(node.NewString(s))
+
+		want, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NodeToJson(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("NodeToJson(%q) = %s, want %s as encoding/json writes it", s, got, want)
+		}
+
+		ascii, err := NodeToJsonWithOptions(root, JsonEncodeOptions{ASCII: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back string
+		if err := json.Unmarshal(ascii, &back); err != nil || back != string(bytes.ToValidUTF8([]byte(s), []byte("�"))) {
+			t.Errorf("ASCII output %s read back as %q, %v", ascii, back, err)
+		}
+		for _, c := range ascii {
+			if c >= 0x80 {
+				t.Errorf("ASCII output %s holds a non-ASCII byte", ascii)
+				break
+			}
+		}
+	}
+}
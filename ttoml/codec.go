@@ -0,0 +1,50 @@
+package ttoml
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/mstgnz/transformer"
+	"github.com/mstgnz/transformer/node"
+)
+
+func init() {
+	transformer.Register(transformer.FormatToml, Codec{})
+}
+
+// Codec implements transformer.Codec for TOML
+type Codec struct{}
+
+var _ transformer.Codec = Codec{}
+
+// Decode reads TOML from r and decodes it into a Node
+func (Codec) Decode(r io.Reader) (*node.Node, error) {
+	return DecodeTomlReader(r)
+}
+
+// Encode writes the TOML form of n to w
+func (Codec) Encode(w io.Writer, n *node.Node) error {
+	return EncodeTo(w, n)
+}
+
+// Detect reports whether data is valid TOML
+func (Codec) Detect(data []byte) bool {
+	return IsToml(data)
+}
+
+// Sniff rates how likely data is TOML.
+// Documents with a table header score highest. A few key/value lines
+// score lower since other key=value formats may parse as TOML too, and
+// documents without any key do not count.
+func (Codec) Sniff(data []byte) float64 {
+	n, err := DecodeToml(data)
+	if err != nil || n.Value.Node == nil {
+		return 0
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimLeft(line, " \t"), []byte("[")) {
+			return 0.9
+		}
+	}
+	return 0.7
+}
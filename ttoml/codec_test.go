@@ -0,0 +1,53 @@
+package ttoml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/transformer"
+)
+
+func TestCodec(t *testing.T) {
+	codec, err := transformer.Lookup("toml")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	n, err := codec.Decode(strings.NewReader("b = 1\n\n[a]\nc = [true, false]\n"))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, n); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if want := "b = 1\n\n[a]\nc = [true, false]\n"; buf.String() != want {
+		t.Errorf("Encode() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCodec_Sniff(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want float64
+	}{
+		{name: "table", data: "# config\n[server]\nport = 80", want: 0.9},
+		{name: "array of tables", data: "  [[items]]\nid = 1", want: 0.9},
+		{name: "key/value pairs", data: "name = \"web\"\nport = 80", want: 0.7},
+		{name: "comments only", data: "# nothing", want: 0},
+		{name: "json", data: `{"a": 1}`, want: 0},
+		{name: "yaml", data: "a: 1", want: 0},
+		{name: "ini", data: "[server]\nhost = localhost", want: 0},
+		{name: "empty", data: "", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Codec{}).Sniff([]byte(tt.data)); got != tt.want {
+				t.Errorf("Sniff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,642 @@
+package ttoml
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+var (
+	decimalPattern  = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+	prefixedPattern = regexp.MustCompile(`^(0x[0-9A-Fa-f](_?[0-9A-Fa-f])*|0o[0-7](_?[0-7])*|0b[01](_?[01])*)$`)
+	floatPattern    = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][+-]?[0-9](_?[0-9])*)?$`)
+	// datetimePattern matches the offset and local date-times, local dates
+	// and local times of TOML. As in RFC 3339, T and Z may be lowercase.
+	datetimePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})?)?|\d{2}:\d{2}:\d{2}(\.\d+)?)$`)
+	datePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// tableKind records how a table came to exist, which decides whether a
+// later header or dotted key may still add keys to it
+type tableKind int
+
+const (
+	tableImplicit tableKind = iota // parent of a header, not declared itself
+	tableHeader                    // declared by a [table] or [[table]] header
+	tableDotted                    // created by a dotted key
+	tableInline                    // inline table, closed once written
+)
+
+// table is the decoding state of a table value
+type table struct {
+	owner *node.Node            // node holding the table value
+	kind  tableKind             // how the table was created
+	keys  map[string]*node.Node // members by key, for duplicate checks
+}
+
+// parser decodes a TOML document into a node tree
+type parser struct {
+	data    []byte
+	pos     int
+	line    int
+	root    *table
+	current *table                 // table receiving key/value pairs
+	tables  map[*node.Value]*table // state of every table value
+	arrays  map[*node.Value]bool   // arrays created by [[table]] headers
+}
+
+// parse decodes the whole document
+func (p *parser) parse() (*node.Node, error) {
+	if !utf8.Valid(p.data) {
+		return nil, fmt.Errorf("toml: document is not valid UTF-8")
+	}
+	p.data = bytes.TrimPrefix(p.data, []byte("\xef\xbb\xbf"))
+	p.line = 1
+	p.tables = make(map[*node.Value]*table)
+	p.arrays = make(map[*node.Value]bool)
+
+	root := &node.Node{Key: "root", Value: &node.Value{Type: node.TypeObject}}
+	p.root = p.newTable(root, tableHeader)
+	p.current = p.root
+
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, nil
+		}
+		if p.peek() == '[' {
+			if err := p.header(); err != nil {
+				return nil, err
+			}
+		} else if err := p.keyValue(p.current); err != nil {
+			return nil, err
+		}
+		if err := p.endLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// errorf returns an error located at the current line
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("toml: line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+// peek returns the current byte, or 0 at the end of the input
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+// startsWith reports whether the input continues with s
+func (p *parser) startsWith(s string) bool {
+	return bytes.HasPrefix(p.data[p.pos:], []byte(s))
+}
+
+// skipSpace skips spaces and tabs
+func (p *parser) skipSpace() {
+	for !p.eof() && (p.data[p.pos] == ' ' || p.data[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment skips a comment up to the end of the line
+func (p *parser) skipComment() {
+	if p.peek() != '#' {
+		return
+	}
+	for !p.eof() && p.data[p.pos] != '\n' && !p.startsWith("\r\n") {
+		p.pos++
+	}
+}
+
+// newline consumes a line break and reports whether there was one
+func (p *parser) newline() bool {
+	switch {
+	case p.peek() == '\n':
+		p.pos++
+	case p.startsWith("\r\n"):
+		p.pos += 2
+	default:
+		return false
+	}
+	p.line++
+	return true
+}
+
+// skipBlank skips whitespace, comments and line breaks
+func (p *parser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if !p.newline() {
+			return
+		}
+	}
+}
+
+// endLine expects the end of a line, after optional whitespace and a comment
+func (p *parser) endLine() error {
+	p.skipSpace()
+	p.skipComment()
+	if !p.eof() && !p.newline() {
+		return p.errorf("unexpected %q at end of line", p.peek())
+	}
+	return nil
+}
+
+// expect consumes c or returns an error
+func (p *parser) expect(c byte) error {
+	if p.peek() != c {
+		if p.eof() {
+			return p.errorf("expected %q, found end of input", c)
+		}
+		return p.errorf("expected %q, found %q", c, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// newTable registers the value of owner as a table of the given kind
+func (p *parser) newTable(owner *node.Node, kind tableKind) *table {
+	t := &table{owner: owner, kind: kind, keys: make(map[string]*node.Node)}
+	p.tables[owner.Value] = t
+	return t
+}
+
+// add appends a member holding value to t
+func (p *parser) add(t *table, key string, value *node.Value) *node.Node {
+	member := &node.Node{Key: key}
+	member.AddToValue(value)
+	t.owner.AddToEnd(member)
+	t.keys[key] = member
+	return member
+}
+
+// header reads a [table] or [[table]] header and makes it the current table
+func (p *parser) header() error {
+	p.pos++
+	array := p.peek() == '['
+	if array {
+		p.pos++
+	}
+
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if err := p.expect(']'); err != nil {
+		return err
+	}
+	if array {
+		if err := p.expect(']'); err != nil {
+			return err
+		}
+	}
+
+	t := p.root
+	for i, key := range keys[:len(keys)-1] {
+		member := t.keys[key]
+		if member == nil {
+			t = p.newTable(p.add(t, key, &node.Value{Type: node.TypeObject}), tableImplicit)
+			continue
+		}
+		value := member.Value
+		if p.arrays[value] {
+			value = value.Array[len(value.Array)-1].Elem()
+		}
+		if t = p.tables[value]; t == nil || t.kind == tableInline {
+			return p.errorf("key %s is already defined", joinKey(keys[:i+1]))
+		}
+	}
+
+	last := keys[len(keys)-1]
+	member := t.keys[last]
+	if array {
+		if member == nil {
+			member = p.add(t, last, &node.Value{Type: node.TypeArray})
+			p.arrays[member.Value] = true
+		} else if !p.arrays[member.Value] {
+			return p.errorf("key %s is not an array of tables", joinKey(keys))
+		}
+		items := member.Value
+		item := node.NewItem(len(items.Array), &node.Value{Type: node.TypeObject})
+		items.Array = append(items.Array, item)
+		p.current = p.newTable(item.Node, tableHeader)
+		return nil
+	}
+
+	if member == nil {
+		p.current = p.newTable(p.add(t, last, &node.Value{Type: node.TypeObject}), tableHeader)
+		return nil
+	}
+	if t = p.tables[member.Value]; t == nil || t.kind != tableImplicit {
+		return p.errorf("table %s is already defined", joinKey(keys))
+	}
+	t.kind = tableHeader
+	p.current = t
+	return nil
+}
+
+// keyValue reads a key/value pair into t. Dotted keys create the tables
+// they pass through.
+func (p *parser) keyValue(t *table) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if err := p.expect('='); err != nil {
+		return err
+	}
+	p.skipSpace()
+
+	for i, key := range keys[:len(keys)-1] {
+		member := t.keys[key]
+		if member == nil {
+			t = p.newTable(p.add(t, key, &node.Value{Type: node.TypeObject}), tableDotted)
+			continue
+		}
+		if t = p.tables[member.Value]; t == nil || t.kind != tableDotted {
+			return p.errorf("key %s is already defined", joinKey(keys[:i+1]))
+		}
+	}
+
+	last := keys[len(keys)-1]
+	if t.keys[last] != nil {
+		return p.errorf("key %s is already defined", joinKey(keys))
+	}
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+	p.add(t, last, value)
+	return nil
+}
+
+// key reads a possibly dotted key
+func (p *parser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		key, err := p.simpleKey()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+// simpleKey reads a bare or quoted key
+func (p *parser) simpleKey() (string, error) {
+	switch p.peek() {
+	case '"':
+		return p.basicString()
+	case '\'':
+		return p.literalString()
+	}
+	start := p.pos
+	for !p.eof() && isBareKeyChar(p.data[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.eof() {
+			return "", p.errorf("expected a key, found end of input")
+		}
+		return "", p.errorf("expected a key, found %q", p.peek())
+	}
+	return string(p.data[start:p.pos]), nil
+}
+
+// isBareKeyChar reports whether c may appear in a bare key
+func isBareKeyChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// value reads a value
+func (p *parser) value() (*node.Value, error) {
+	switch c := p.peek(); {
+	case p.startsWith(`"""`):
+		s, err := p.multilineString(`"""`, true)
+		return node.NewString(s), err
+	case c == '"':
+		s, err := p.basicString()
+		return node.NewString(s), err
+	case p.startsWith("'''"):
+		s, err := p.multilineString("'''", false)
+		return node.NewString(s), err
+	case c == '\'':
+		s, err := p.literalString()
+		return node.NewString(s), err
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	}
+
+	start := p.pos
+	for !p.eof() && isScalarChar(p.data[p.pos]) {
+		p.pos++
+		// A date and a time may be separated by a space
+		if datePattern.Match(p.data[start:p.pos]) && p.startsWith(" ") && p.pos+3 < len(p.data) &&
+			isDigit(p.data[p.pos+1]) && isDigit(p.data[p.pos+2]) && p.data[p.pos+3] == ':' {
+			p.pos++
+		}
+	}
+	if p.pos == start {
+		if p.eof() {
+			return nil, p.errorf("expected a value, found end of input")
+		}
+		return nil, p.errorf("expected a value, found %q", p.peek())
+	}
+	return p.scalar(string(p.data[start:p.pos]))
+}
+
+// isScalarChar reports whether c may appear in a boolean, number or datetime
+func isScalarChar(c byte) bool {
+	return isBareKeyChar(c) || c == '+' || c == '.' || c == ':'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// scalar converts the text of a boolean, number or datetime into a value
+func (p *parser) scalar(s string) (*node.Value, error) {
+	switch s {
+	case "true", "false":
+		return &node.Value{Type: node.TypeBoolean, Worth: s}, nil
+	case "inf", "+inf":
+		return &node.Value{Type: node.TypeNumber, Worth: strconv.FormatFloat(math.Inf(1), 'f', -1, 64)}, nil
+	case "-inf":
+		return &node.Value{Type: node.TypeNumber, Worth: strconv.FormatFloat(math.Inf(-1), 'f', -1, 64)}, nil
+	case "nan", "+nan", "-nan":
+		return &node.Value{Type: node.TypeNumber, Worth: strconv.FormatFloat(math.NaN(), 'f', -1, 64)}, nil
+	}
+
+	switch {
+	case datetimePattern.MatchString(s):
+		v := &node.Value{Type: node.TypeTimestamp, Worth: s}
+		if _, err := v.AsTime(); err != nil {
+			return nil, p.errorf("invalid datetime %s", s)
+		}
+		return v, nil
+	case decimalPattern.MatchString(s), prefixedPattern.MatchString(s):
+		i, err := strconv.ParseInt(strings.TrimPrefix(s, "+"), 0, 64)
+		if err != nil {
+			return nil, p.errorf("integer %s is out of range", s)
+		}
+		return node.ParseNumber(strconv.FormatInt(i, 10))
+	case floatPattern.MatchString(s):
+		return node.ParseNumber(strings.ReplaceAll(strings.TrimPrefix(s, "+"), "_", ""))
+	}
+	return nil, p.errorf("invalid value %s", s)
+}
+
+// array reads an array, which may span several lines
+func (p *parser) array() (*node.Value, error) {
+	p.pos++
+	array := &node.Value{Type: node.TypeArray, Array: []*node.Value{}}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.pos++
+			return array, nil
+		}
+		item, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		array.Array = append(array.Array, node.NewItem(len(array.Array), item))
+
+		p.skipBlank()
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if err := p.expect(']'); err != nil {
+			return nil, err
+		}
+		return array, nil
+	}
+}
+
+// inlineTable reads an inline table, which must fit on one line
+func (p *parser) inlineTable() (*node.Value, error) {
+	p.pos++
+	owner := &node.Node{Value: &node.Value{Type: node.TypeObject}}
+	t := p.newTable(owner, tableDotted)
+
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		t.kind = tableInline
+		return owner.Value, nil
+	}
+	for {
+		if err := p.keyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.peek() == ',' {
+			p.pos++
+			p.skipSpace()
+			if p.peek() == '}' {
+				return nil, p.errorf("trailing comma in inline table")
+			}
+			continue
+		}
+		if err := p.expect('}'); err != nil {
+			return nil, err
+		}
+		p.seal(owner.Value)
+		return owner.Value, nil
+	}
+}
+
+// seal closes an inline table and the tables its dotted keys created
+func (p *parser) seal(v *node.Value) {
+	if t := p.tables[v]; t != nil {
+		t.kind = tableInline
+	}
+	for member := v.Node; member != nil; member = member.Next {
+		if member.Value.Type == node.TypeObject {
+			p.seal(member.Value)
+		}
+	}
+}
+
+// basicString reads a single-line string in double quotes
+func (p *parser) basicString() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.data[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return sb.String(), nil
+		case c == '\\':
+			if err := p.escape(&sb, false); err != nil {
+				return "", err
+			}
+		case c == '\n' || c == '\r':
+			return "", p.errorf("unterminated string")
+		case isControl(c):
+			return "", p.errorf("control character %q in string", c)
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// literalString reads a single-line string in single quotes
+func (p *parser) literalString() (string, error) {
+	p.pos++
+	start := p.pos
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.data[p.pos]
+		switch {
+		case c == '\'':
+			p.pos++
+			return string(p.data[start : p.pos-1]), nil
+		case c == '\n' || c == '\r':
+			return "", p.errorf("unterminated string")
+		case isControl(c):
+			return "", p.errorf("control character %q in string", c)
+		}
+		p.pos++
+	}
+}
+
+// multilineString reads a string delimited by three quotes. A line break
+// right after the opening quotes is dropped; basic strings also handle
+// escapes and line-ending backslashes.
+func (p *parser) multilineString(delim string, basic bool) (string, error) {
+	p.pos += len(delim)
+	p.newline()
+
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.data[p.pos]
+		switch {
+		case c == delim[0]:
+			// Up to two quotes may precede the closing delimiter
+			n := 0
+			for p.pos+n < len(p.data) && p.data[p.pos+n] == c {
+				n++
+			}
+			p.pos += n
+			if n < 3 {
+				sb.WriteString(delim[:n])
+				continue
+			}
+			if n > 5 {
+				return "", p.errorf("too many quotes at end of string")
+			}
+			sb.WriteString(delim[:n-3])
+			return sb.String(), nil
+		case c == '\\' && basic:
+			if err := p.escape(&sb, true); err != nil {
+				return "", err
+			}
+		case c == '\n' || p.startsWith("\r\n"):
+			p.newline()
+			sb.WriteByte('\n')
+		case isControl(c):
+			return "", p.errorf("control character %q in string", c)
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// escape reads an escape sequence of a basic string into sb. Only
+// multi-line strings may end a line with a backslash.
+func (p *parser) escape(sb *strings.Builder, multiline bool) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unterminated string")
+	}
+	c := p.data[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case '"', '\\':
+		sb.WriteByte(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.data) {
+			return p.errorf("invalid escape \\%c", c)
+		}
+		code, err := strconv.ParseUint(string(p.data[p.pos:p.pos+size]), 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid escape \\%c%s", c, p.data[p.pos:p.pos+size])
+		}
+		p.pos += size
+		sb.WriteRune(rune(code))
+	case ' ', '\t', '\r', '\n':
+		if !multiline {
+			return p.errorf("invalid escape \\%c", c)
+		}
+		// A backslash ending a line trims the break and the whitespace after it
+		p.pos--
+		p.skipSpace()
+		if !p.newline() {
+			return p.errorf("invalid escape \\%c", c)
+		}
+		for {
+			p.skipSpace()
+			if !p.newline() {
+				return nil
+			}
+		}
+	default:
+		return p.errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+// isControl reports whether c is a control character not allowed in strings
+func isControl(c byte) bool {
+	return c < 0x20 && c != '\t' || c == 0x7f
+}
@@ -0,0 +1,358 @@
+// Package ttoml provides functionality for handling TOML data using the Node structure.
+// It includes functions for reading, validating, encoding, and decoding TOML documents.
+package ttoml
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+var bareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// IsToml checks if the given bytes represent a valid TOML document
+func IsToml(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	_, err := DecodeToml(data)
+	return err == nil
+}
+
+// ReadToml reads a TOML file and returns its contents as bytes
+func ReadToml(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !IsToml(data) {
+		return nil, fmt.Errorf("invalid TOML format")
+	}
+	return data, nil
+}
+
+// DecodeToml decodes TOML bytes into a Node.
+// Keys keep their source order, tables become objects and arrays of tables
+// become arrays of objects. Datetimes are timestamp values holding the
+// text as written.
+func DecodeToml(data []byte) (*node.Node, error) {
+	p := &parser{data: data}
+	return p.parse()
+}
+
+// DecodeTomlReader decodes a TOML document read from r into a Node
+func DecodeTomlReader(r io.Reader) (*node.Node, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeToml(data)
+}
+
+// NodeToToml converts a Node to TOML bytes.
+// The node must hold an object. Within each table the plain values are
+// written first, in member order, followed by the tables and arrays of
+// tables as TOML requires. Null values have no TOML form and are an error
+// naming their path.
+func NodeToToml(n *node.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := EncodeTo(&buf, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeTo writes the TOML form of a Node to w
+func EncodeTo(w io.Writer, n *node.Node) error {
+	if n == nil {
+		return fmt.Errorf("node is nil")
+	}
+
+	if n.Value == nil {
+		return fmt.Errorf("node value is nil")
+	}
+
+	if n.Value.Type != node.TypeObject {
+		return fmt.Errorf("toml: document must be a table, not %s", n.Value.Type)
+	}
+
+	e := &tomlEncoder{w: bufio.NewWriter(w)}
+	if err := e.writeTable(nil, n.Value, ""); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// tomlEncoder writes the TOML form of a tree
+type tomlEncoder struct {
+	w       *bufio.Writer
+	started bool // whether a line was written, headers after it get a blank line
+}
+
+// writeTable writes the members of a table, preceded by its header unless
+// header is empty. A [table] header is left out when the table only holds
+// other tables, as their headers define it.
+func (e *tomlEncoder) writeTable(path []string, v *node.Value, header string) error {
+	var values, tables []*node.Node
+	for member := v.Node; member != nil; member = member.Next {
+		switch {
+		case member.Value != nil && (isTable(member.Value) || isTableArray(member.Value)):
+			tables = append(tables, member)
+		default:
+			values = append(values, member)
+		}
+	}
+
+	if header == "[[" || header == "[" && (len(values) > 0 || len(tables) == 0) {
+		if e.started {
+			e.w.WriteByte('\n')
+		}
+		e.w.WriteString(header)
+		e.w.WriteString(joinKey(path))
+		e.w.WriteString(strings.Repeat("]", len(header)))
+		e.w.WriteByte('\n')
+		e.started = true
+	}
+
+	for _, member := range values {
+		e.w.WriteString(formatKey(member.Key))
+		e.w.WriteString(" = ")
+		if err := e.writeValue(joinKey(append(path, member.Key)), member.Value, true); err != nil {
+			return err
+		}
+		e.w.WriteByte('\n')
+		e.started = true
+	}
+
+	for _, member := range tables {
+		tablePath := append(path[:len(path):len(path)], member.Key)
+		if member.Value.Type == node.TypeArray {
+			for _, item := range member.Value.Array {
+				if err := e.writeTable(tablePath, item.Elem(), "[["); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := e.writeTable(tablePath, member.Value, "["); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeValue writes a value in inline form. Strings holding line breaks
+// are written as multi-line strings when multiline is set. Errors name the
+// value by its path.
+func (e *tomlEncoder) writeValue(path string, v *node.Value, multiline bool) error {
+	v = v.Elem()
+	if v == nil || v.Type == node.TypeNull {
+		return fmt.Errorf("%s: toml: null cannot be represented", path)
+	}
+
+	switch v.Type {
+	case node.TypeObject:
+		e.w.WriteByte('{')
+		for member := v.Node; member != nil; member = member.Next {
+			if member != v.Node {
+				e.w.WriteString(", ")
+			}
+			e.w.WriteString(formatKey(member.Key))
+			e.w.WriteString(" = ")
+			if err := e.writeValue(path+"."+formatKey(member.Key), member.Value, false); err != nil {
+				return err
+			}
+		}
+		e.w.WriteByte('}')
+
+	case node.TypeArray:
+		e.w.WriteByte('[')
+		for i, item := range v.Array {
+			if i > 0 {
+				e.w.WriteString(", ")
+			}
+			if err := e.writeValue(fmt.Sprintf("%s[%d]", path, i), item, false); err != nil {
+				return err
+			}
+		}
+		e.w.WriteByte(']')
+
+	default:
+		literal, err := formatScalar(v, multiline)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		e.w.WriteString(literal)
+	}
+	return nil
+}
+
+// formatScalar returns the TOML literal of a value that is not a collection
+func formatScalar(v *node.Value, multiline bool) (string, error) {
+	switch v.Type {
+	case node.TypeString:
+		if multiline && strings.Contains(v.Worth, "\n") {
+			return multilineString(v.Worth), nil
+		}
+		return basicString(v.Worth), nil
+
+	case node.TypeNumber:
+		return formatNumber(v)
+
+	case node.TypeBoolean:
+		b, err := v.Bool()
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+
+	case node.TypeTimestamp:
+		if datetimePattern.MatchString(v.Worth) {
+			return v.Worth, nil
+		}
+		t, err := v.AsTime()
+		if err != nil {
+			return "", err
+		}
+		return t.Format(time.RFC3339Nano), nil
+
+	case node.TypeBinary:
+		// TOML has no binary type, the data is written as base64 text
+		s, err := v.FormatBinary(node.BinaryBase64)
+		if err != nil {
+			return "", err
+		}
+		return basicString(s), nil
+
+	default:
+		return "", fmt.Errorf("toml: unsupported value type %s", v.Type)
+	}
+}
+
+// isTable reports whether v is written as a [table]. Empty objects are
+// written inline as {}.
+func isTable(v *node.Value) bool {
+	return v.Type == node.TypeObject && v.Node != nil
+}
+
+// isTableArray reports whether v is written as an array of tables, which
+// holds objects only
+func isTableArray(v *node.Value) bool {
+	if v.Type != node.TypeArray || len(v.Array) == 0 {
+		return false
+	}
+	for _, item := range v.Array {
+		if item == nil || item.Elem().Type != node.TypeObject {
+			return false
+		}
+	}
+	return true
+}
+
+// formatNumber returns the TOML literal of a number value. TOML integers
+// are 64-bit signed, larger ones are an error.
+func formatNumber(v *node.Value) (string, error) {
+	if !node.IsNumberLiteral(v.Worth) {
+		f, err := strconv.ParseFloat(v.Worth, 64)
+		switch {
+		case err != nil && !math.IsInf(f, 0):
+			return "", fmt.Errorf("toml: invalid number %q", v.Worth)
+		case math.IsNaN(f):
+			return "nan", nil
+		case math.IsInf(f, 1):
+			return "inf", nil
+		case math.IsInf(f, -1):
+			return "-inf", nil
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	}
+
+	switch v.NumberKind() {
+	case node.NumberUint, node.NumberBig:
+		return "", fmt.Errorf("toml: integer %s is out of range", v.Worth)
+	}
+	return v.Worth, nil
+}
+
+// formatKey returns a key bare when it allows, quoted otherwise
+func formatKey(key string) string {
+	if bareKeyPattern.MatchString(key) {
+		return key
+	}
+	return basicString(key)
+}
+
+// joinKey returns the dotted form of a key path
+func joinKey(keys []string) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = formatKey(key)
+	}
+	return strings.Join(parts, ".")
+}
+
+// basicString returns s as a single-line basic string
+func basicString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		writeEscaped(&sb, r)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// multilineString returns s as a multi-line basic string. Quotes are
+// escaped where they would otherwise run into three or close the string.
+func multilineString(s string) string {
+	var sb strings.Builder
+	sb.WriteString("\"\"\"\n")
+	for i, r := range s {
+		switch {
+		case r == '\n':
+			sb.WriteByte('\n')
+		case r == '"' && i+1 < len(s) && s[i+1] != '"':
+			sb.WriteByte('"')
+		default:
+			writeEscaped(&sb, r)
+		}
+	}
+	sb.WriteString(`"""`)
+	return sb.String()
+}
+
+// writeEscaped writes r to sb, escaped as a basic string requires
+func writeEscaped(sb *strings.Builder, r rune) {
+	switch r {
+	case '"':
+		sb.WriteString(`\"`)
+	case '\\':
+		sb.WriteString(`\\`)
+	case '\b':
+		sb.WriteString(`\b`)
+	case '\t':
+		sb.WriteString(`\t`)
+	case '\n':
+		sb.WriteString(`\n`)
+	case '\f':
+		sb.WriteString(`\f`)
+	case '\r':
+		sb.WriteString(`\r`)
+	default:
+		if r < 0x20 || r == 0x7f {
+			fmt.Fprintf(sb, `\u%04X`, r)
+			return
+		}
+		// Invalid UTF-8 ranges as utf8.RuneError, written as U+FFFD
+		sb.WriteRune(r)
+	}
+}
@@ -0,0 +1,435 @@
+package ttoml
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/transformer/node"
+	"github.com/mstgnz/transformer/tjson"
+	"github.com/mstgnz/transformer/tyaml"
+)
+
+func TestIsToml(t *testing.T) {
+	validToml, err := os.ReadFile("../example/files/valid.toml")
+	if err != nil {
+		t.Fatalf("Error reading valid.toml: %v", err)
+	}
+
+	invalidToml, err := os.ReadFile("../example/files/invalid.toml")
+	if err != nil {
+		t.Fatalf("Error reading invalid.toml: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "valid toml", data: validToml, want: true},
+		{name: "invalid toml", data: invalidToml, want: false},
+		{name: "yaml", data: []byte("name: web"), want: false},
+		{name: "empty toml", data: []byte{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsToml(tt.data); got != tt.want {
+				t.Errorf("IsToml() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadToml(t *testing.T) {
+	if _, err := ReadToml("../example/files/valid.toml"); err != nil {
+		t.Errorf("ReadToml(valid.toml) error = %v", err)
+	}
+	if _, err := ReadToml("../example/files/invalid.toml"); err == nil {
+		t.Error("ReadToml(invalid.toml) expected error")
+	}
+	if _, err := ReadToml("../example/files/missing.toml"); err == nil {
+		t.Error("ReadToml(missing.toml) expected error")
+	}
+}
+
+func TestDecodeToml(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			name: "key order",
+			data: "b = 1\na = 2\nc = 3",
+			want: `{"b":1,"a":2,"c":3}`,
+		},
+		{
+			name: "tables",
+			data: "title = \"app\"\n\n[server]\nhost = \"localhost\"\n\n[server.tls]\nenabled = true\n\n[db]\nport = 5432\n",
+			want: `{"title":"app","server":{"host":"localhost","tls":{"enabled":true}},"db":{"port":5432}}`,
+		},
+		{
+			name: "implicit super-table",
+			data: "[a.b.c]\nx = 1\n[a]\ny = 2",
+			want: `{"a":{"b":{"c":{"x":1}},"y":2}}`,
+		},
+		{
+			name: "dotted and quoted keys",
+			data: "site.\"google.com\" = true\n'key two'.x = 1\n3.14159 = \"pi\"\n\"\" = 0",
+			want: `{"site":{"google.com":true},"key two":{"x":1},"3":{"14159":"pi"},"":0}`,
+		},
+		{
+			name: "arrays of tables",
+			data: "[[products]]\nname = \"Hammer\"\n\n[[products]]\n\n[[products]]\nname = \"Nail\"\n[products.size]\nw = 1\n[[products.parts]]\nid = 7",
+			want: `{"products":[{"name":"Hammer"},{},{"name":"Nail","size":{"w":1},"parts":[{"id":7}]}]}`,
+		},
+		{
+			name: "inline tables",
+			data: "point = { x = 1, y = 2 }\nempty = {}\nnested = { a.b = 1, a.c = [ { d = 1 } ] }",
+			want: `{"point":{"x":1,"y":2},"empty":{},"nested":{"a":{"b":1,"c":[{"d":1}]}}}`,
+		},
+		{
+			name: "arrays",
+			data: "ints = [ 1, 2, 3, ]\nmixed = [\n  1, # one\n  \"two\",\n  [3.5],\n]\nempty = []",
+			want: `{"ints":[1,2,3],"mixed":[1,"two",[3.5]],"empty":[]}`,
+		},
+		{
+			name: "integers",
+			data: "a = +99\nb = -17\nc = 1_000\nd = 0xDEAD_beef\ne = 0o755\nf = 0b1101\ng = -0",
+			want: `{"a":99,"b":-17,"c":1000,"d":3735928559,"e":493,"f":13,"g":0}`,
+		},
+		{
+			name: "floats",
+			data: "a = +1.0\nb = 3.1415\nc = -0.01\nd = 5e+22\ne = 6.626e-34\nf = 224_617.445_991\ng = 0.10",
+			want: `{"a":1.0,"b":3.1415,"c":-0.01,"d":5e+22,"e":6.626e-34,"f":224617.445991,"g":0.10}`,
+		},
+		{
+			name: "strings",
+			data: "basic = \"tab\\t \\\"quote\\\" \\u00E9 \\U0001F600\"\nliteral = 'C:\\Users\\nodejs'\n" +
+				"multi = \"\"\"\nRoses are red\n  Violets are \\\n    blue\"\"\"\nraw = '''\nfirst \\n\nsecond'''\nquotes = \"\"\"\"one\"\" \"\"\"\"\"",
+			want: `{"basic":"tab\t \"quote\" é 😀","literal":"C:\\Users\\nodejs","multi":"Roses are red\n  Violets are blue","raw":"first \\n\nsecond","quotes":"\"one\"\" \"\""}`,
+		},
+		{
+			name: "comments and crlf",
+			data: "# head\r\na = 1 # trailing\r\n\r\n[t] # table\r\nb = \"x\"\r\n",
+			want: `{"a":1,"t":{"b":"x"}}`,
+		},
+		{
+			name: "empty document",
+			data: "# nothing here\n",
+			want: `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := DecodeToml([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("DecodeToml() error = %v", err)
+			}
+			if err := n.Validate(); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+			got, err := tjson.NodeToJson(n)
+			if err != nil {
+				t.Fatalf("NodeToJson() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("DecodeToml() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeToml_Scalars(t *testing.T) {
+	data := `odt = 1979-05-27T07:32:00.999999-07:00
+space = 1979-05-27 07:32:00Z
+lower = 1979-05-27t07:32:00z
+ldt = 1979-05-27T07:32:00
+ld = 1979-05-27
+lt = 07:32:00
+inf = -inf
+nan = nan
+yes = true
+`
+	n, err := DecodeToml([]byte(data))
+	if err != nil {
+		t.Fatalf("DecodeToml() error = %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		typ   node.ValueType
+		worth string
+		time  time.Time
+	}{
+		{"odt", node.TypeTimestamp, "1979-05-27T07:32:00.999999-07:00", time.Date(1979, 5, 27, 14, 32, 0, 999999000, time.UTC)},
+		{"space", node.TypeTimestamp, "1979-05-27 07:32:00Z", time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+		{"lower", node.TypeTimestamp, "1979-05-27t07:32:00z", time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+		{"ldt", node.TypeTimestamp, "1979-05-27T07:32:00", time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+		{"ld", node.TypeTimestamp, "1979-05-27", time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC)},
+		{"lt", node.TypeTimestamp, "07:32:00", time.Date(0, 1, 1, 7, 32, 0, 0, time.UTC)},
+		{"inf", node.TypeNumber, "-Inf", time.Time{}},
+		{"nan", node.TypeNumber, "NaN", time.Time{}},
+		{"yes", node.TypeBoolean, "true", time.Time{}},
+	}
+	for _, tt := range tests {
+		v, err := n.GetByPath(tt.path)
+		if err != nil {
+			t.Fatalf("GetByPath(%s) error = %v", tt.path, err)
+		}
+		if v.Type != tt.typ || v.Worth != tt.worth {
+			t.Errorf("%s = %s %q, want %s %q", tt.path, v.Type, v.Worth, tt.typ, tt.worth)
+		}
+		if tt.typ != node.TypeTimestamp {
+			continue
+		}
+		if got, err := v.AsTime(); err != nil || !got.Equal(tt.time) {
+			t.Errorf("%s.AsTime() = %v, %v, want %v", tt.path, got, err, tt.time)
+		}
+	}
+}
+
+func TestDecodeToml_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{name: "duplicate key", data: "a = 1\na = 2", err: "line 2: key a is already defined"},
+		{name: "duplicate table", data: "[a]\nx = 1\n[a]", err: "line 3: table a is already defined"},
+		{name: "table over value", data: "a = 1\n[a.b]", err: "key a is already defined"},
+		{name: "table over inline table", data: "a = { b = 1 }\n[a]", err: "table a is already defined"},
+		{name: "extend inline table", data: "a = { b = 1 }\na.c = 2", err: "key a is already defined"},
+		{name: "header over dotted table", data: "a.b = 1\n[a]", err: "table a is already defined"},
+		{name: "dotted key into header table", data: "[a.b]\n[a]\nb.c = 1", err: "line 3: key b is already defined"},
+		{name: "array of tables over table", data: "[a]\n[[a]]", err: "key a is not an array of tables"},
+		{name: "append to static array", data: "a = [1]\n[[a]]", err: "key a is not an array of tables"},
+		{name: "missing value", data: "a =", err: "expected a value"},
+		{name: "missing key", data: "= 1", err: "expected a key"},
+		{name: "two pairs on a line", data: "a = 1 b = 2", err: "unexpected 'b' at end of line"},
+		{name: "unterminated string", data: "a = \"text", err: "unterminated string"},
+		{name: "invalid escape", data: `a = "\q"`, err: `invalid escape \q`},
+		{name: "line break in inline table", data: "a = {\nb = 1 }", err: "expected a key"},
+		{name: "trailing comma in inline table", data: "a = { b = 1, }", err: "trailing comma"},
+		{name: "leading zero", data: "a = 01", err: "invalid value 01"},
+		{name: "integer overflow", data: "a = 9223372036854775808", err: "out of range"},
+		{name: "invalid date", data: "a = 1979-13-27", err: "invalid datetime"},
+		{name: "bare string", data: "a = hello", err: "invalid value hello"},
+		{name: "invalid utf-8", data: "a = \"\xff\"", err: "not valid UTF-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeToml([]byte(tt.data))
+			if err == nil {
+				t.Fatal("DecodeToml() expected error")
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("DecodeToml() error = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNodeToToml(t *testing.T) {
+	data := `title = "app"
+owner = { name = "Tom", since = 1979-05-27 }
+ports = [8000, 8001]
+nothing = {}
+
+[server]
+host = "localhost"
+
+[server.tls]
+cert = """
+-----BEGIN-----
+"quoted" \\ end\""""
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+
+[[products]]
+name = "Nail"
+
+[products.size]
+w = 1.50
+`
+	n, err := DecodeToml([]byte(data))
+	if err != nil {
+		t.Fatalf("DecodeToml() error = %v", err)
+	}
+
+	got, err := NodeToToml(n)
+	if err != nil {
+		t.Fatalf("NodeToToml() error = %v", err)
+	}
+	// Inline tables become tables, everything else is written as read
+	want := `title = "app"
+ports = [8000, 8001]
+nothing = {}
+
+[owner]
+name = "Tom"
+since = 1979-05-27
+
+[server]
+host = "localhost"
+
+[server.tls]
+cert = """
+-----BEGIN-----
+"quoted" \\ end\""""
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+
+[[products]]
+name = "Nail"
+
+[products.size]
+w = 1.50
+`
+	if string(got) != want {
+		t.Errorf("NodeToToml() = %s, want %s", got, want)
+	}
+
+	again, err := DecodeToml(got)
+	if err != nil {
+		t.Fatalf("DecodeToml() of the output error = %v", err)
+	}
+	if !again.Value.Equal(n.Value) {
+		t.Error("decoding the output does not give the original tree")
+	}
+}
+
+func TestNodeToToml_FromJson(t *testing.T) {
+	n, err := tjson.DecodeJson([]byte(`{
+		"name": "web",
+		"labels": {"app.kubernetes.io/name": "web"},
+		"env": [{"name": "A", "value": "1"}, {"name": "B", "value": "tab\tline\nnext"}],
+		"matrix": [[1, 2], ["a", {"b": true}]],
+		"big": 1.5e300,
+		"quote": "say \"hi\""
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NodeToToml(n)
+	if err != nil {
+		t.Fatalf("NodeToToml() error = %v", err)
+	}
+	want := `name = "web"
+matrix = [[1, 2], ["a", {b = true}]]
+big = 1.5e300
+quote = "say \"hi\""
+
+[labels]
+"app.kubernetes.io/name" = "web"
+
+[[env]]
+name = "A"
+value = "1"
+
+[[env]]
+name = "B"
+value = """
+tab\tline
+next"""
+`
+	if string(got) != want {
+		t.Errorf("NodeToToml() = %s, want %s", got, want)
+	}
+}
+
+func TestNodeToToml_FromYaml(t *testing.T) {
+	n, err := tyaml.DecodeYaml([]byte(`created: 2001-12-14t21:59:43.10-05:00
+updated: 2001-1-2 3:4:5
+day: 2024-05-01
+icon: !!binary aGk=
+limit: .inf
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NodeToToml(n)
+	if err != nil {
+		t.Fatalf("NodeToToml() error = %v", err)
+	}
+	// Timestamps TOML cannot read as written are normalized to RFC 3339
+	want := `created = 2001-12-14t21:59:43.10-05:00
+updated = 2001-01-02T03:04:05Z
+day = 2024-05-01
+icon = "aGk="
+limit = inf
+`
+	if string(got) != want {
+		t.Errorf("NodeToToml() = %s, want %s", got, want)
+	}
+}
+
+func TestNodeToToml_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *node.Value
+	}{
+		{name: "scalar root", value: node.NewString("text")},
+		{name: "array root", value: node.NewArray(node.NewNumber(1))},
+		{name: "null in array", value: object("a", node.NewArray(node.NewNumber(1), node.NewNull()))},
+		{name: "uint64", value: object("a", &node.Value{Type: node.TypeNumber, Worth: "18446744073709551615"})},
+		{name: "invalid number", value: object("a", &node.Value{Type: node.TypeNumber, Worth: "abc"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := node.NewNode("root")
+			root.AddToValue(tt.value)
+			if _, err := NodeToToml(root); err == nil {
+				t.Error("NodeToToml() expected error")
+			}
+		})
+	}
+
+	if _, err := NodeToToml(nil); err == nil {
+		t.Error("NodeToToml(nil) expected error")
+	}
+}
+
+func TestNodeToToml_Null(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{data: `{"a": null}`, want: "a: toml: null cannot be represented"},
+		{data: `{"t": {"q": null, "r": 1}}`, want: "t.q: toml: null cannot be represented"},
+		{data: `{"a": {"b": [1, {"c": null}]}}`, want: "a.b[1].c: toml: null cannot be represented"},
+		{data: `{"a": [1, null]}`, want: "a[1]: toml: null cannot be represented"},
+		{data: `{"x.y": {"z": 18446744073709551615}}`, want: `"x.y".z: toml: integer 18446744073709551615 is out of range`},
+	}
+
+	for _, tt := range tests {
+		n, err := tjson.DecodeJson([]byte(tt.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = NodeToToml(n)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("NodeToToml(%s) error = %v, want %s", tt.data, err, tt.want)
+		}
+	}
+}
+
+// object returns an object value holding a single member
+func object(key string, value *node.Value) *node.Value {
+	member := node.NewNode(key)
+	member.AddToValue(value)
+	return node.NewObject(member)
+}
@@ -0,0 +1,50 @@
+package txml
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/mstgnz/transformer"
+	"github.com/mstgnz/transformer/node"
+)
+
+func init() {
+	transformer.Register(transformer.FormatXml, Codec{})
+}
+
+// Codec implements transformer.Codec for XML.
+// The zero value uses the default options.
+type Codec struct {
+	DecodeOptions XmlDecodeOptions
+	EncodeOptions XmlEncodeOptions
+}
+
+var _ transformer.Codec = Codec{}
+
+// Decode reads XML from r and decodes it into a Node
+func (c Codec) Decode(r io.Reader) (*node.Node, error) {
+	return DecodeXmlReaderWithOptions(r, c.DecodeOptions)
+}
+
+// Encode writes the XML form of n to w
+func (c Codec) Encode(w io.Writer, n *node.Node) error {
+	return EncodeToWithOptions(w, n, c.EncodeOptions)
+}
+
+// Detect reports whether data is valid XML
+func (Codec) Detect(data []byte) bool {
+	return IsXml(data)
+}
+
+// Sniff rates how likely data is XML.
+// Documents starting with an XML declaration are certain matches.
+func (Codec) Sniff(data []byte) float64 {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if !bytes.HasPrefix(trimmed, []byte("<")) || !IsXml(data) {
+		return 0
+	}
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) {
+		return 1
+	}
+	return 0.9
+}
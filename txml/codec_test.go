@@ -0,0 +1,61 @@
+package txml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	var codec Codec
+	n, err := codec.Decode(strings.NewReader(`<root><b>1</b><a>x</a></root>`))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, n); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if got, want := normalizeXml(buf.String()), "<root><b>1</b><a>x</a></root>"; got != want {
+		t.Errorf("Encode() = %s, want %s", got, want)
+	}
+}
+
+func TestCodec_Sniff(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want float64
+	}{
+		{name: "declaration", data: `<?xml version="1.0"?><a/>`, want: 1},
+		{name: "element", data: "  <a><b/></a>", want: 0.9},
+		{name: "unclosed element", data: "<a>", want: 0},
+		{name: "json", data: `{"a": 1}`, want: 0},
+		{name: "empty", data: "", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Codec{}).Sniff([]byte(tt.data)); got != tt.want {
+				t.Errorf("Sniff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodec_Options(t *testing.T) {
+	codec := Codec{DecodeOptions: XmlDecodeOptions{NamespaceMode: NamespaceStrip}}
+	n, err := codec.Decode(strings.NewReader(`<a:root xmlns:a="urn:a"><a:b>1</a:b></a:root>`))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, n); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if got, want := normalizeXml(buf.String()), "<root><b>1</b></root>"; got != want {
+		t.Errorf("Encode() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,261 @@
+package txml
+
+import (
+	"strings"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+// Convention selects how XML elements, attributes and text map to the tree
+type Convention int
+
+const (
+	// ConventionDefault stores attributes as "@name" members and text as the
+	// value of the element; an element with attributes and text is a scalar
+	// value holding the attribute members
+	ConventionDefault Convention = iota
+	// ConventionBadgerFish makes every element an object, with attributes as
+	// "@name" members and text in a "$" member
+	ConventionBadgerFish
+	// ConventionParker drops attributes; text-only elements are scalars and
+	// empty elements are null
+	ConventionParker
+	// ConventionGData makes every element an object, with attributes as plain
+	// members and text in a "$t" member
+	ConventionGData
+	// ConventionAbdera keeps text-only elements as scalars; elements with
+	// attributes become objects with an "attributes" object and a "children"
+	// array of text and single-member element objects
+	ConventionAbdera
+)
+
+// Member names used by the conventions
+const (
+	badgerFishText = "$"
+	gdataText      = "$t"
+	abderaAttrs    = "attributes"
+	abderaChildren = "children"
+)
+
+// XmlOptions holds the settings shared by XmlDecodeOptions and XmlEncodeOptions,
+// so that a tree is written back the way it was read
+type XmlOptions struct {
+	// Convention selects how elements, attributes and text map to the tree
+	Convention Convention
+}
+
+// applyConvention reshapes the value of an element decoded with the default
+// convention, and those of its child elements, into the given convention
+func applyConvention(v *node.Value, c Convention) *node.Value {
+	if c == ConventionDefault || v == nil {
+		return v
+	}
+
+	var attrs, children []*node.Node
+	if v.Type == node.TypeObject || v.Node != nil {
+		for member := v.Node; member != nil; member = member.Next {
+			if strings.HasPrefix(member.Key, "@") {
+				attrs = append(attrs, member)
+				continue
+			}
+			// Text, comments and instructions recorded in lossless mode are kept
+			if !isSpecialKey(member.Key) {
+				member.Value = applyElements(member.Value, c)
+			}
+			children = append(children, member)
+		}
+	}
+
+	var text *node.Value
+	if v.Type != node.TypeObject && v.Type != node.TypeArray {
+		text = &node.Value{Type: v.Type, Worth: v.Worth, Kind: v.Kind}
+	}
+
+	switch c {
+	case ConventionBadgerFish, ConventionGData:
+		object := newObject()
+		for _, attr := range attrs {
+			key := attr.Key
+			if c == ConventionGData {
+				key = key[1:]
+			}
+			addMember(object, key, attr.Value)
+		}
+		for _, child := range children {
+			addMember(object, child.Key, child.Value)
+		}
+		if text != nil {
+			key := badgerFishText
+			if c == ConventionGData {
+				key = gdataText
+			}
+			addMember(object, key, text)
+		}
+		return object.Value
+
+	case ConventionParker:
+		if text != nil {
+			return text
+		}
+		if len(children) == 0 {
+			return &node.Value{Type: node.TypeNull}
+		}
+		object := newObject()
+		for _, child := range children {
+			addMember(object, child.Key, child.Value)
+		}
+		return object.Value
+
+	case ConventionAbdera:
+		if len(attrs) == 0 {
+			if text != nil {
+				return text
+			}
+			object := newObject()
+			for _, child := range children {
+				addMember(object, child.Key, child.Value)
+			}
+			return object.Value
+		}
+
+		object := newObject()
+		attributes := newObject()
+		for _, attr := range attrs {
+			addMember(attributes, attr.Key[1:], attr.Value)
+		}
+		addMember(object, abderaAttrs, attributes.Value)
+
+		var items []*node.Value
+		if text != nil {
+			items = append(items, text)
+		}
+		for _, child := range children {
+			// Repeated elements are listed one by one, as in the document
+			values := []*node.Value{child.Value}
+			if child.Value.Type == node.TypeArray {
+				values = values[:0]
+				for _, item := range child.Value.Array {
+					values = append(values, item.Elem())
+				}
+			}
+			for _, value := range values {
+				entry := newObject()
+				addMember(entry, child.Key, value)
+				items = append(items, node.NewItem(len(items), entry.Value))
+			}
+		}
+		if len(items) > 0 {
+			addMember(object, abderaChildren, &node.Value{Type: node.TypeArray, Array: items})
+		}
+		return object.Value
+	}
+	return v
+}
+
+// applyElements applies a convention to a member value, which is an element
+// or, for repeated elements, an array of them
+func applyElements(v *node.Value, c Convention) *node.Value {
+	if v == nil || v.Type != node.TypeArray {
+		return applyConvention(v, c)
+	}
+	for i, item := range v.Array {
+		v.Array[i] = node.NewItem(i, applyConvention(item.Elem(), c))
+	}
+	return v
+}
+
+// newObject returns a holder node for building an object value
+func newObject() *node.Node {
+	return &node.Node{Value: &node.Value{Type: node.TypeObject}}
+}
+
+// addMember appends a member to the object value of holder
+func addMember(holder *node.Node, key string, value *node.Value) {
+	member := &node.Node{Key: key}
+	member.AddToValue(value)
+	holder.AddToEnd(member)
+}
+
+// xmlContent is an attribute-free part of an element: a child element, or
+// text, CDATA, a comment or an instruction stored under a special key
+type xmlContent struct {
+	key   string
+	value *node.Value
+	item  bool // an array item, written as a single element even when it is an array
+}
+
+// xmlParts splits the value of an element into its attributes and content
+// following the given convention; it is the reverse of applyConvention.
+// The items of an array are written as elements named item.
+func xmlParts(v *node.Value, c Convention, item string) (attrs []*node.Node, content []xmlContent) {
+	if v == nil || v.Type == node.TypeNull {
+		return nil, nil
+	}
+
+	switch v.Type {
+	case node.TypeArray:
+		for _, value := range v.Array {
+			if value != nil {
+				content = append(content, xmlContent{key: item, value: value.Elem(), item: true})
+			}
+		}
+		return nil, content
+	case node.TypeObject:
+	default:
+		// Scalars are text, attribute members are kept by any convention
+		for member := v.Node; member != nil; member = member.Next {
+			if strings.HasPrefix(member.Key, "@") {
+				attrs = append(attrs, member)
+			}
+		}
+		return attrs, []xmlContent{{key: textKey, value: v}}
+	}
+
+	for member := v.Node; member != nil; member = member.Next {
+		if member.Value == nil {
+			continue
+		}
+		key := member.Key
+		switch {
+		case strings.HasPrefix(key, "@"):
+			attrs = append(attrs, member)
+		case isSpecialKey(key):
+			content = append(content, xmlContent{key: key, value: member.Value})
+		case c == ConventionBadgerFish && key == badgerFishText,
+			c == ConventionGData && key == gdataText:
+			content = append(content, xmlContent{key: textKey, value: member.Value})
+		case c == ConventionGData && isScalar(member.Value):
+			attrs = append(attrs, &node.Node{Key: "@" + key, Value: member.Value})
+		case c == ConventionAbdera && key == abderaAttrs && member.Value.Type == node.TypeObject:
+			for attr := member.Value.Node; attr != nil; attr = attr.Next {
+				attrs = append(attrs, &node.Node{Key: "@" + attr.Key, Value: attr.Value})
+			}
+		case c == ConventionAbdera && key == abderaChildren && member.Value.Type == node.TypeArray:
+			for _, item := range member.Value.Array {
+				item = item.Elem()
+				if item == nil {
+					continue
+				}
+				if item.Type != node.TypeObject {
+					content = append(content, xmlContent{key: textKey, value: item})
+					continue
+				}
+				for child := item.Node; child != nil; child = child.Next {
+					content = append(content, xmlContent{key: child.Key, value: child.Value})
+				}
+			}
+		default:
+			content = append(content, xmlContent{key: key, value: member.Value})
+		}
+	}
+	return attrs, content
+}
+
+// isScalar reports whether v is a string, number, boolean, timestamp or binary value
+func isScalar(v *node.Value) bool {
+	switch v.Type {
+	case node.TypeString, node.TypeNumber, node.TypeBoolean, node.TypeTimestamp, node.TypeBinary:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package txml
+
+import (
+	"testing"
+
+	"github.com/mstgnz/transformer/tjson"
+)
+
+const bookXml = `<book id="1"><title>Go</title><price currency="EUR">10</price><tag>a</tag><tag>b</tag><note/></book>`
+
+func TestConventions(t *testing.T) {
+	tests := []struct {
+		name       string
+		convention Convention
+		json       string
+		xml        string
+	}{
+		{
+			name:       "default",
+			convention: ConventionDefault,
+			json:       `{"book":{"@id":"1","title":"Go","price":10,"tag":["a","b"],"note":{}}}`,
+			xml:        bookXml,
+		},
+		{
+			name:       "badgerfish",
+			convention: ConventionBadgerFish,
+			json:       `{"book":{"@id":"1","title":{"$":"Go"},"price":{"@currency":"EUR","$":10},"tag":[{"$":"a"},{"$":"b"}],"note":{}}}`,
+			xml:        bookXml,
+		},
+		{
+			name:       "parker",
+			convention: ConventionParker,
+			json:       `{"book":{"title":"Go","price":10,"tag":["a","b"],"note":null}}`,
+			xml:        `<book><title>Go</title><price>10</price><tag>a</tag><tag>b</tag><note/></book>`,
+		},
+		{
+			name:       "gdata",
+			convention: ConventionGData,
+			json:       `{"book":{"id":"1","title":{"$t":"Go"},"price":{"currency":"EUR","$t":10},"tag":[{"$t":"a"},{"$t":"b"}],"note":{}}}`,
+			xml:        bookXml,
+		},
+		{
+			name:       "abdera",
+			convention: ConventionAbdera,
+			json: `{"book":{"attributes":{"id":"1"},"children":[{"title":"Go"},` +
+				`{"price":{"attributes":{"currency":"EUR"},"children":[10]}},{"tag":"a"},{"tag":"b"},{"note":{}}]}}`,
+			xml: bookXml,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := XmlOptions{Convention: tt.convention}
+			n, err := DecodeXmlWithOptions([]byte(bookXml), XmlDecodeOptions{XmlOptions: options})
+			if err != nil {
+				t.Fatalf("DecodeXmlWithOptions() error = %v", err)
+			}
+			if err := n.Validate(); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+
+			got, err := tjson.NodeToJson(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.json {
+				t.Errorf("tree = %s, want %s", got, tt.json)
+			}
+
+			out, err := NodeToXmlWithOptions(n, XmlEncodeOptions{XmlOptions: options})
+			if err != nil {
+				t.Fatalf("NodeToXmlWithOptions() error = %v", err)
+			}
+			if want := "<root>" + tt.xml + "</root>"; normalizeXml(string(out)) != want {
+				t.Errorf("NodeToXmlWithOptions() = %s, want %s", out, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package txml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrDoctype is returned when a document holds a DOCTYPE, ENTITY or other
+// DTD declaration and XmlDecodeOptions.AllowDoctype is not set
+var ErrDoctype = errors.New("xml: DOCTYPE and ENTITY declarations are not allowed")
+
+// Names of the limits reported by LimitError, after the XmlDecodeOptions
+// fields that set them
+const (
+	LimitDepth      = "MaxDepth"
+	LimitElements   = "MaxElements"
+	LimitAttributes = "MaxAttributes"
+	LimitTextLength = "MaxTextLength"
+	LimitBytes      = "MaxBytes"
+)
+
+// LimitError is returned when a document exceeds a limit of XmlDecodeOptions
+type LimitError struct {
+	// Limit is the name of the limit, one of the Limit constants
+	Limit string
+	// Max is the configured value of the limit
+	Max int64
+	// Line is the line the limit tripped at, or 0 when it is not known
+	Line int
+}
+
+func (e *LimitError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("xml: %s limit of %d exceeded on line %d", e.Limit, e.Max, e.Line)
+	}
+	return fmt.Sprintf("xml: %s limit of %d exceeded", e.Limit, e.Max)
+}
+
+// limitError reports a limit tripped at the current line
+func (d *xmlDecoder) limitError(limit string, max int) error {
+	line, _ := d.dec.InputPos()
+	return &LimitError{Limit: limit, Max: int64(max), Line: line}
+}
+
+// checkStart enforces the depth, element and attribute limits on an
+// element about to be opened
+func (d *xmlDecoder) checkStart(attrs int) error {
+	opts := d.opts
+	d.elements++
+	switch {
+	case opts.MaxDepth > 0 && len(d.frames) > opts.MaxDepth:
+		return d.limitError(LimitDepth, opts.MaxDepth)
+	case opts.MaxElements > 0 && d.elements > opts.MaxElements:
+		return d.limitError(LimitElements, opts.MaxElements)
+	case opts.MaxAttributes > 0 && attrs > opts.MaxAttributes:
+		return d.limitError(LimitAttributes, opts.MaxAttributes)
+	}
+	return nil
+}
+
+// checkText enforces the text length limit
+func (d *xmlDecoder) checkText(length int) error {
+	if d.opts.MaxTextLength > 0 && length > d.opts.MaxTextLength {
+		return d.limitError(LimitTextLength, d.opts.MaxTextLength)
+	}
+	return nil
+}
+
+// limitReader fails with a LimitError once more than max bytes are read
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+// Read implements io.Reader
+func (r *limitReader) Read(p []byte) (int, error) {
+	if r.n > r.max {
+		return 0, &LimitError{Limit: LimitBytes, Max: r.max}
+	}
+	// Read one byte past the limit to know whether it is exceeded
+	if rest := r.max - r.n + 1; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if r.n > r.max {
+		return 0, &LimitError{Limit: LimitBytes, Max: r.max}
+	}
+	return n, err
+}
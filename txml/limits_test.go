@@ -0,0 +1,134 @@
+package txml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeXml_Limits(t *testing.T) {
+	tests := []struct {
+		name  string
+		xml   string
+		opts  XmlDecodeOptions
+		limit string
+	}{
+		{
+			name:  "depth",
+			xml:   "<a><b><c><d/></c></b></a>",
+			opts:  XmlDecodeOptions{MaxDepth: 3},
+			limit: LimitDepth,
+		},
+		{
+			name:  "elements",
+			xml:   "<list><i/><i/><i/></list>",
+			opts:  XmlDecodeOptions{MaxElements: 3},
+			limit: LimitElements,
+		},
+		{
+			name:  "attributes",
+			xml:   `<a x="1" y="2" z="3"/>`,
+			opts:  XmlDecodeOptions{MaxAttributes: 2},
+			limit: LimitAttributes,
+		},
+		{
+			name:  "text",
+			xml:   "<a>" + strings.Repeat("x", 11) + "</a>",
+			opts:  XmlDecodeOptions{MaxTextLength: 10},
+			limit: LimitTextLength,
+		},
+		{
+			name:  "text split by comments",
+			xml:   "<a>xxxxxx<!-- c -->xxxxxx</a>",
+			opts:  XmlDecodeOptions{MaxTextLength: 10},
+			limit: LimitTextLength,
+		},
+		{
+			name:  "attribute value",
+			xml:   `<a x="` + strings.Repeat("x", 11) + `"/>`,
+			opts:  XmlDecodeOptions{MaxTextLength: 10},
+			limit: LimitTextLength,
+		},
+		{
+			name:  "comment",
+			xml:   "<a><!--" + strings.Repeat("x", 11) + "--></a>",
+			opts:  XmlDecodeOptions{MaxTextLength: 10, Lossless: true},
+			limit: LimitTextLength,
+		},
+		{
+			name:  "bytes",
+			xml:   "<a>" + strings.Repeat("<b>1</b>", 1000) + "</a>",
+			opts:  XmlDecodeOptions{MaxBytes: 1024},
+			limit: LimitBytes,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeXmlWithOptions([]byte(tt.xml), tt.opts)
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("DecodeXmlWithOptions() error = %v, want *LimitError", err)
+			}
+			if limitErr.Limit != tt.limit {
+				t.Errorf("Limit = %s, want %s", limitErr.Limit, tt.limit)
+			}
+		})
+	}
+}
+
+func TestDecodeXml_WithinLimits(t *testing.T) {
+	data := `<a x="1" y="2"><b><c>0123456789</c></b><b/></a>`
+	opts := XmlDecodeOptions{
+		MaxDepth:      3,
+		MaxElements:   4,
+		MaxAttributes: 2,
+		MaxTextLength: 10,
+		MaxBytes:      int64(len(data)),
+	}
+	if _, err := DecodeXmlWithOptions([]byte(data), opts); err != nil {
+		t.Errorf("DecodeXmlWithOptions() error = %v", err)
+	}
+}
+
+func TestDecodeXml_Doctype(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+	}{
+		{
+			name: "doctype",
+			xml:  `<!DOCTYPE note SYSTEM "note.dtd"><note>hi</note>`,
+		},
+		{
+			name: "billion laughs",
+			xml: `<?xml version="1.0"?><!DOCTYPE lolz [<!ENTITY lol "lol">` +
+				`<!ENTITY lol2 "&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;">]><lolz>&lol2;</lolz>`,
+		},
+		{
+			name: "external entity",
+			xml:  `<!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><foo>&xxe;</foo>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeXml([]byte(tt.xml)); !errors.Is(err, ErrDoctype) {
+				t.Errorf("DecodeXml() error = %v, want ErrDoctype", err)
+			}
+		})
+	}
+
+	n, err := DecodeXmlWithOptions([]byte(tests[0].xml), XmlDecodeOptions{AllowDoctype: true})
+	if err != nil {
+		t.Fatalf("DecodeXmlWithOptions() with AllowDoctype error = %v", err)
+	}
+	if got := n.Value.Node.Value.Worth; got != "hi" {
+		t.Errorf("note = %q, want hi", got)
+	}
+
+	// Declared entities are never expanded
+	if _, err := DecodeXmlWithOptions([]byte(tests[2].xml), XmlDecodeOptions{AllowDoctype: true}); err == nil {
+		t.Error("DecodeXmlWithOptions() expected error for undefined entity")
+	}
+}
@@ -0,0 +1,105 @@
+package txml
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/transformer/node"
+	"github.com/mstgnz/transformer/tjson"
+)
+
+func TestDecodeXml_Lossless(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		json string
+	}{
+		{
+			name: "mixed content",
+			xml:  `<p class="intro">Hello <b>big</b> <i>world</i>!</p>`,
+			json: `{"p":{"@class":"intro","#text":"Hello ","b":"big","#text":" ","i":"world","#text":"!"}}`,
+		},
+		{
+			name: "cdata and comments",
+			xml:  "<script><!-- inline --><![CDATA[if (a < b) { run(); }]]></script>",
+			json: `{"script":{"#comment":" inline ","#cdata":"if (a \u003c b) { run(); }"}}`,
+		},
+		{
+			name: "prolog",
+			xml:  `<?xml-stylesheet href="style.xsl" type="text/xsl"?><!-- generated --><doc><?page break?><title>  Spaced  </title><count>5</count></doc><!-- end -->`,
+			json: `{"?xml-stylesheet":"href=\"style.xsl\" type=\"text/xsl\"","#comment":" generated ",` +
+				`"doc":{"?page":"break","title":"  Spaced  ","count":5},"#comment":" end "}`,
+		},
+		{
+			name: "repeated elements",
+			xml:  "<list>\n  <i>1</i>\n  <i>2</i>\n</list>",
+			json: `{"list":{"i":[1,2]}}`,
+		},
+		{
+			name: "repeated elements with comments",
+			xml:  `<list><i>1</i><!-- two --><i>2</i></list>`,
+			json: `{"list":{"i":1,"#comment":" two ","i":2}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := DecodeXmlWithOptions([]byte(tt.xml), XmlDecodeOptions{Lossless: true})
+			if err != nil {
+				t.Fatalf("DecodeXmlWithOptions() error = %v", err)
+			}
+			if n.Key != "#document" {
+				t.Errorf("root key = %q, want #document", n.Key)
+			}
+
+			got, err := tjson.NodeToJson(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.json {
+				t.Errorf("tree = %s, want %s", got, tt.json)
+			}
+
+			out, err := NodeToXml(n)
+			if err != nil {
+				t.Fatalf("NodeToXml() error = %v", err)
+			}
+			if want := strings.ReplaceAll(tt.xml, "\n  ", ""); strings.TrimPrefix(string(out), xml.Header) != strings.ReplaceAll(want, "\n", "") {
+				t.Errorf("NodeToXml() = %s, want %s", out, tt.xml)
+			}
+		})
+	}
+}
+
+func TestDecodeXml_TextWithChildren(t *testing.T) {
+	n, err := DecodeXml([]byte(`<note>Remember <b>this</b></note>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tjson.NodeToJson(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"note":{"b":"this","#text":"Remember"}}`; string(got) != want {
+		t.Errorf("tree = %s, want %s", got, want)
+	}
+}
+
+func TestNodeToXml_InvalidSpecials(t *testing.T) {
+	tests := []struct {
+		key, worth string
+	}{
+		{"#comment", "a -- b"},
+		{"?xml", "version=\"1.0\""},
+		{"?pi", "a ?> b"},
+	}
+
+	for _, tt := range tests {
+		root := node.NewNode("root")
+		root.AddToValue(node.NewObject(&node.Node{Key: tt.key, Value: node.NewString(tt.worth)}))
+		if _, err := NodeToXml(root); err == nil {
+			t.Errorf("NodeToXml() with %s %q expected error", tt.key, tt.worth)
+		}
+	}
+}
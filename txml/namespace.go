@@ -0,0 +1,244 @@
+package txml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NamespaceMode selects how DecodeXml stores namespaced names
+type NamespaceMode int
+
+const (
+	// NamespacePrefix keeps names as written, e.g. "soap:Envelope", and keeps
+	// xmlns declarations as attributes so that the prefixes can be resolved
+	NamespacePrefix NamespaceMode = iota
+	// NamespaceClark stores names in Clark notation, e.g.
+	// "{http://schemas.xmlsoap.org/soap/envelope/}Envelope", and keeps
+	// xmlns declarations as attributes so that NodeToXml reuses the prefixes
+	NamespaceClark
+	// NamespaceStrip drops prefixes and xmlns declarations, keeping only
+	// the local names
+	NamespaceStrip
+)
+
+// xmlURI is the namespace bound to the reserved "xml" prefix
+const xmlURI = "http://www.w3.org/XML/1998/namespace"
+
+// namespaces tracks the prefix bindings of the open elements
+type namespaces struct {
+	scopes    []map[string]string
+	generated map[string]string // prefixes generated for URIs while encoding
+}
+
+// push opens the scope of an element, binding the prefixes its xmlns
+// attributes declare. The default namespace is bound to the "" prefix.
+func (ns *namespaces) push(attrs []xml.Attr) {
+	var scope map[string]string
+	for _, attr := range attrs {
+		if !isXmlns(attr.Name) {
+			continue
+		}
+		if scope == nil {
+			scope = make(map[string]string)
+		}
+		if attr.Name.Space == "xmlns" {
+			scope[attr.Name.Local] = attr.Value
+		} else {
+			scope[""] = attr.Value
+		}
+	}
+	ns.scopes = append(ns.scopes, scope)
+}
+
+// pop closes the scope of the innermost element
+func (ns *namespaces) pop() {
+	if len(ns.scopes) > 0 {
+		ns.scopes = ns.scopes[:len(ns.scopes)-1]
+	}
+}
+
+// lookup returns the URI bound to a prefix
+func (ns *namespaces) lookup(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlURI, true
+	}
+	for i := len(ns.scopes) - 1; i >= 0; i-- {
+		if uri, ok := ns.scopes[i][prefix]; ok {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// bind binds a prefix in the scope of the innermost element
+func (ns *namespaces) bind(prefix, uri string) {
+	if len(ns.scopes) == 0 {
+		ns.scopes = append(ns.scopes, nil)
+	}
+	top := len(ns.scopes) - 1
+	if ns.scopes[top] == nil {
+		ns.scopes[top] = make(map[string]string)
+	}
+	ns.scopes[top][prefix] = uri
+}
+
+// decodeName returns the key of an element or attribute name as stored
+// by the given mode. Unprefixed attributes are in no namespace, unlike
+// unprefixed elements which are in the default one.
+func (ns *namespaces) decodeName(name xml.Name, element bool, mode NamespaceMode) string {
+	switch mode {
+	case NamespaceStrip:
+		return name.Local
+	case NamespaceClark:
+		if name.Space == "" && !element {
+			return name.Local
+		}
+		uri, ok := ns.lookup(name.Space)
+		if !ok {
+			// Unbound prefixes are kept, there is no URI to use
+			return qualifiedName(name)
+		}
+		if uri == "" {
+			return name.Local
+		}
+		return "{" + uri + "}" + name.Local
+	default:
+		return qualifiedName(name)
+	}
+}
+
+// encodeName returns the name to write for a key of the tree. Names in
+// Clark notation get a prefix bound to their URI, and prefixes that are
+// not bound yet are declared from the given prefix map; the needed xmlns
+// declarations are appended to decls.
+func (ns *namespaces) encodeName(name string, element bool, prefixes map[string]string, decls []xml.Attr) (string, []xml.Attr) {
+	if strings.HasPrefix(name, "{") {
+		if end := strings.IndexByte(name, '}'); end > 0 {
+			return ns.clarkName(name[1:end], name[end+1:], element, prefixes, decls)
+		}
+		return name, decls
+	}
+
+	prefix, _, ok := strings.Cut(name, ":")
+	if ok && prefix != "xmlns" {
+		if _, bound := ns.lookup(prefix); !bound {
+			if uri := prefixes[prefix]; uri != "" {
+				ns.bind(prefix, uri)
+				decls = append(decls, declaration(prefix, uri))
+			}
+		}
+	}
+	return name, decls
+}
+
+// clarkName returns the prefixed name for a local name in a namespace
+func (ns *namespaces) clarkName(uri, local string, element bool, prefixes map[string]string, decls []xml.Attr) (string, []xml.Attr) {
+	defaultURI, _ := ns.lookup("")
+	if uri == "" {
+		// Elements leave an inherited default namespace explicitly
+		if element && defaultURI != "" {
+			ns.bind("", "")
+			decls = append(decls, declaration("", ""))
+		}
+		return local, decls
+	}
+	if element && defaultURI == uri {
+		return local, decls
+	}
+
+	// Reuse a prefix in scope
+	var bound []string
+	for i := len(ns.scopes) - 1; i >= 0; i-- {
+		for prefix, u := range ns.scopes[i] {
+			if u == uri && prefix != "" {
+				if current, _ := ns.lookup(prefix); current == uri {
+					bound = append(bound, prefix)
+				}
+			}
+		}
+	}
+	if uri == xmlURI {
+		bound = append(bound, "xml")
+	}
+	if len(bound) > 0 {
+		sort.Strings(bound)
+		return bound[0] + ":" + local, decls
+	}
+
+	prefix := ns.choosePrefix(uri, prefixes)
+	ns.bind(prefix, uri)
+	decls = append(decls, declaration(prefix, uri))
+	return prefix + ":" + local, decls
+}
+
+// choosePrefix picks the prefix to declare for a URI, preferring the
+// given prefix map over generated "ns1", "ns2", ... prefixes
+func (ns *namespaces) choosePrefix(uri string, prefixes map[string]string) string {
+	var candidates []string
+	for prefix, u := range prefixes {
+		if u == uri && prefix != "" {
+			candidates = append(candidates, prefix)
+		}
+	}
+	sort.Strings(candidates)
+	for _, prefix := range candidates {
+		if _, bound := ns.lookup(prefix); !bound {
+			return prefix
+		}
+	}
+
+	if ns.generated == nil {
+		ns.generated = make(map[string]string)
+	}
+	if prefix, ok := ns.generated[uri]; ok {
+		if current, bound := ns.lookup(prefix); !bound || current == uri {
+			return prefix
+		}
+	}
+	for i := len(ns.generated) + 1; ; i++ {
+		prefix := fmt.Sprintf("ns%d", i)
+		if _, bound := ns.lookup(prefix); bound {
+			continue
+		}
+		if _, taken := prefixes[prefix]; taken {
+			continue
+		}
+		ns.generated[uri] = prefix
+		return prefix
+	}
+}
+
+// declaration returns the xmlns attribute binding prefix to uri
+func declaration(prefix, uri string) xml.Attr {
+	if prefix == "" {
+		return xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: uri}
+	}
+	return xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: uri}
+}
+
+// isXmlns reports whether an attribute declares a namespace
+func isXmlns(name xml.Name) bool {
+	return name.Space == "xmlns" || (name.Space == "" && name.Local == "xmlns")
+}
+
+// qualifiedName returns a raw name in its "prefix:local" form
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// parseQualifiedName splits a "prefix:local" key; names in Clark
+// notation are kept whole, their URI may contain colons
+func parseQualifiedName(s string) xml.Name {
+	if strings.HasPrefix(s, "{") {
+		return xml.Name{Local: s}
+	}
+	if prefix, local, ok := strings.Cut(s, ":"); ok {
+		return xml.Name{Space: prefix, Local: local}
+	}
+	return xml.Name{Local: s}
+}
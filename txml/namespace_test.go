@@ -0,0 +1,109 @@
+package txml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+const soapXml = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns="urn:shop">` +
+	`<soap:Body><order soap:mustUnderstand="1" id="7"><item>pen</item></order></soap:Body>` +
+	`</soap:Envelope>`
+
+func TestDecodeXml_Namespaces(t *testing.T) {
+	tests := []struct {
+		name string
+		mode NamespaceMode
+		keys []string
+	}{
+		{
+			name: "prefix",
+			mode: NamespacePrefix,
+			keys: []string{
+				"root", "soap:Envelope", "@xmlns:soap", "@xmlns", "soap:Body", "order",
+				"@soap:mustUnderstand", "@id", "item",
+			},
+		},
+		{
+			name: "clark",
+			mode: NamespaceClark,
+			keys: []string{
+				"root", "{http://schemas.xmlsoap.org/soap/envelope/}Envelope", "@xmlns:soap", "@xmlns",
+				"{http://schemas.xmlsoap.org/soap/envelope/}Body", "{urn:shop}order",
+				"@{http://schemas.xmlsoap.org/soap/envelope/}mustUnderstand", "@id", "{urn:shop}item",
+			},
+		},
+		{
+			name: "strip",
+			mode: NamespaceStrip,
+			keys: []string{"root", "Envelope", "Body", "order", "@mustUnderstand", "@id", "item"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := DecodeXmlWithOptions([]byte(soapXml), XmlDecodeOptions{NamespaceMode: tt.mode})
+			if err != nil {
+				t.Fatalf("DecodeXmlWithOptions() error = %v", err)
+			}
+			var keys []string
+			n.FindNodes(func(member *node.Node) bool {
+				keys = append(keys, member.Key)
+				return false
+			})
+			if strings.Join(keys, " ") != strings.Join(tt.keys, " ") {
+				t.Errorf("keys = %v, want %v", keys, tt.keys)
+			}
+		})
+	}
+}
+
+func TestNodeToXml_Namespaces(t *testing.T) {
+	for _, mode := range []NamespaceMode{NamespacePrefix, NamespaceClark} {
+		n, err := DecodeXmlWithOptions([]byte(soapXml), XmlDecodeOptions{NamespaceMode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NodeToXml(n)
+		if err != nil {
+			t.Fatalf("NodeToXml() error = %v", err)
+		}
+		if want := "<root>" + soapXml + "</root>"; normalizeXml(string(got)) != want {
+			t.Errorf("mode %d: NodeToXml() = %s, want %s", mode, got, want)
+		}
+	}
+}
+
+func TestNodeToXml_DeclareNamespaces(t *testing.T) {
+	root := node.NewNode("root")
+	root.Set(`"{http://www.w3.org/2005/Atom}feed"."{http://www.w3.org/2005/Atom}title"`, "News")
+	root.Set(`"{http://www.w3.org/2005/Atom}feed"."{urn:x}extra"."@{urn:x}lang"`, "en")
+	root.Set(`"{http://www.w3.org/2005/Atom}feed"."dc:creator"`, "Ann")
+
+	got, err := NodeToXmlWithOptions(root, XmlEncodeOptions{
+		Prefixes: map[string]string{
+			"atom": "http://www.w3.org/2005/Atom",
+			"dc":   "http://purl.org/dc/elements/1.1/",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NodeToXmlWithOptions() error = %v", err)
+	}
+
+	want := `<root><atom:feed xmlns:atom="http://www.w3.org/2005/Atom"><atom:title>News</atom:title>` +
+		`<ns1:extra ns1:lang="en" xmlns:ns1="urn:x"/>` +
+		`<dc:creator xmlns:dc="http://purl.org/dc/elements/1.1/">Ann</dc:creator></atom:feed></root>`
+	if normalizeXml(string(got)) != want {
+		t.Errorf("NodeToXmlWithOptions() = %s, want %s", got, want)
+	}
+	if _, err := DecodeXml(got); err != nil {
+		t.Errorf("DecodeXml() of encoded output error = %v", err)
+	}
+}
+
+func TestDecodeXml_MismatchedTags(t *testing.T) {
+	if _, err := DecodeXml([]byte(`<a:root xmlns:a="urn:a"><b></a:root>`)); err == nil {
+		t.Error("DecodeXml() expected error for mismatched end element")
+	}
+}
@@ -1,6 +1,7 @@
 package txml
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/xml"
 	"fmt"
@@ -8,6 +9,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/mstgnz/transformer/node"
 )
@@ -29,206 +32,774 @@ func ReadXml(filename string) ([]byte, error) {
 	return data, nil
 }
 
+// XmlDecodeOptions configures DecodeXmlWithOptions.
+// The zero value is the default used by DecodeXml.
+type XmlDecodeOptions struct {
+	XmlOptions
+	// NamespaceMode selects how namespaced element and attribute names are stored
+	NamespaceMode NamespaceMode
+	// Lossless records text interleaved with child elements as "#text"
+	// members, CDATA sections as "#cdata", comments as "#comment",
+	// processing instructions as "?target" and directives as "#directive",
+	// in document order. The root node is then "#document", holding the
+	// document element together with the comments and instructions around
+	// it, and is written back without a wrapping element.
+	Lossless bool
+	// ForceArray lists elements that are decoded into arrays even when they
+	// occur once, like xmltodict's force_list. An entry is an element key,
+	// e.g. "port", or a dotted path of keys ending at the element, starting
+	// anywhere down from the document element, e.g. "spec.containers".
+	ForceArray []string
+
+	// AllowDoctype accepts DOCTYPE and ENTITY declarations, which are
+	// rejected with ErrDoctype by default. Entities they declare are never
+	// expanded; they are kept as "#directive" members in lossless mode.
+	AllowDoctype bool
+
+	// Limits guard against oversized or hostile input. A limit that trips
+	// fails the decoding with a *LimitError; zero leaves it unlimited.
+
+	// MaxDepth limits how deeply elements nest
+	MaxDepth int
+	// MaxElements limits the number of elements in the document
+	MaxElements int
+	// MaxAttributes limits the number of attributes of an element,
+	// namespace declarations included
+	MaxAttributes int
+	// MaxTextLength limits the length in bytes of the text of an element,
+	// and of a CDATA section, comment, instruction or attribute value
+	MaxTextLength int
+	// MaxBytes limits the size in bytes of the input read
+	MaxBytes int64
+}
+
+// XmlEncodeOptions configures NodeToXmlWithOptions.
+// The zero value is the default used by NodeToXml.
+type XmlEncodeOptions struct {
+	XmlOptions
+	// Prefixes maps namespace prefixes to URIs. Prefixed names whose prefix
+	// is not declared in the tree are declared with these URIs, and names in
+	// Clark notation use these prefixes instead of generated ones.
+	Prefixes map[string]string
+
+	// TimeLayout reformats timestamp values with a time layout, e.g.
+	// time.RFC3339; empty writes them as they were read
+	TimeLayout string
+	// Binary selects the text form of binary values
+	Binary node.BinaryFormat
+	// TagAttribute names an attribute that records the tag of tagged values,
+	// such as a YAML !Ref; empty drops the tags
+	TagAttribute string
+
+	// Indent pretty prints the document, starting every element on a new
+	// line indented by Indent once per nesting level. Elements holding text
+	// are written on one line so their text is kept as it is.
+	Indent string
+
+	// RootName names the document element; empty uses the key of the root node
+	RootName string
+	// InferRoot writes a root object holding a single element, such as
+	// {"person": {...}} decoded from JSON, as that element instead of
+	// wrapping it in a root element
+	InferRoot bool
+
+	// OmitDeclaration leaves out the <?xml ...?> declaration
+	OmitDeclaration bool
+	// Encoding is the encoding named by the declaration; empty means UTF-8.
+	// The output is UTF-8 either way, with characters outside ASCII in text
+	// and attribute values written as character references for any other
+	// encoding, so that it is valid in every ASCII-compatible one.
+	Encoding string
+	// Standalone adds standalone="yes" to the declaration
+	Standalone bool
+
+	// ArrayStyle selects how arrays are written
+	ArrayStyle ArrayStyle
+	// ItemName names the elements of array items that have no name of their
+	// own; empty means "item"
+	ItemName string
+
+	// SanitizeNames turns keys that are not valid XML names into valid ones:
+	// invalid characters become underscores and names starting with a digit,
+	// hyphen or dot get an underscore prefix, e.g. "2nd place" becomes
+	// "_2nd_place". Otherwise such keys are an error.
+	SanitizeNames bool
+}
+
+// ArrayStyle selects how NodeToXml writes arrays
+type ArrayStyle int
+
+const (
+	// ArrayRepeat writes an array held by an object member as repeated
+	// elements named by the member key, the form DecodeXml reads back as an
+	// array. Items of arrays held by the root or by another array are named
+	// by ItemName.
+	ArrayRepeat ArrayStyle = iota
+	// ArraySingular writes an array as an element named by its key holding
+	// an element per item named by the singular of the key, e.g.
+	// <ports><port>80</port></ports>. Keys whose singular cannot be told
+	// name their items by ItemName.
+	ArraySingular
+	// ArrayItemName writes an array as an element named by its key holding
+	// an element per item named by ItemName
+	ArrayItemName
+)
+
 // DecodeXml decodes XML bytes into a Node
 func DecodeXml(data []byte) (*node.Node, error) {
-	decoder := xml.NewDecoder(bytes.NewReader(data))
+	return DecodeXmlReader(bytes.NewReader(data))
+}
+
+// DecodeXmlWithOptions decodes XML bytes into a Node using the given options
+func DecodeXmlWithOptions(data []byte, opts XmlDecodeOptions) (*node.Node, error) {
+	return DecodeXmlReaderWithOptions(bytes.NewReader(data), opts)
+}
+
+// DecodeXmlReader decodes an XML document read from r into a Node.
+// Tokens are consumed as they arrive, so the input is never buffered as a whole.
+func DecodeXmlReader(r io.Reader) (*node.Node, error) {
+	return DecodeXmlReaderWithOptions(r, XmlDecodeOptions{})
+}
+
+// DecodeXmlReaderWithOptions decodes an XML document read from r into a Node
+// using the given options
+func DecodeXmlReaderWithOptions(r io.Reader, opts XmlDecodeOptions) (*node.Node, error) {
+	d := &xmlDecoder{opts: opts, ns: &namespaces{}}
+	if opts.MaxBytes > 0 {
+		r = &limitReader{r: r, max: opts.MaxBytes}
+	}
+	if opts.Lossless {
+		// CDATA sections arrive as text, the raw input tells them apart
+		d.raw = &rawReader{r: bufio.NewReader(r)}
+		d.dec = xml.NewDecoder(d.raw)
+	} else {
+		d.dec = xml.NewDecoder(r)
+	}
+	return d.decode()
+}
+
+// Keys of the members recording XML content other than elements and
+// attributes. Processing instructions are stored as "?target" members.
+const (
+	documentKey  = "#document"
+	textKey      = "#text"
+	cdataKey     = "#cdata"
+	commentKey   = "#comment"
+	directiveKey = "#directive"
+)
+
+// isSpecialKey reports whether a key records text, CDATA, a comment,
+// a directive or a processing instruction rather than an element
+func isSpecialKey(key string) bool {
+	return strings.HasPrefix(key, "#") || strings.HasPrefix(key, "?")
+}
+
+// xmlDecoder builds a tree from the tokens of an XML document
+type xmlDecoder struct {
+	dec    *xml.Decoder
+	raw    *rawReader // records the input in lossless mode
+	opts   XmlDecodeOptions
+	ns     *namespaces
+	frames []*xmlFrame
+
+	elements int // elements opened so far, for MaxElements
+}
+
+// xmlFrame is an open element
+type xmlFrame struct {
+	node *node.Node
+	name xml.Name
+	path string          // dotted path of keys from the document element
+	text strings.Builder // text content collected outside of lossless mode
+}
+
+func (d *xmlDecoder) decode() (*node.Node, error) {
 	root := &node.Node{
 		Key: "root",
 		Value: &node.Value{
 			Type: node.TypeObject,
 		},
 	}
-
-	var current *node.Node = root
-	var stack []*node.Node
-	var textContent strings.Builder
+	if d.opts.Lossless {
+		root.Key = documentKey
+	}
+	d.frames = []*xmlFrame{{node: root}}
+	// document is set when the document element itself is the root node
+	var document bool
 
 	for {
-		token, err := decoder.Token()
+		// Raw tokens keep the prefixes as written, namespaces are resolved here
+		token, raw, err := d.token()
 		if err == io.EOF {
+			if len(d.frames) > 1 {
+				return nil, d.syntaxError("unexpected EOF")
+			}
 			break
 		}
 		if err != nil {
 			return nil, err
 		}
+		top := d.frames[len(d.frames)-1]
 
 		switch t := token.(type) {
 		case xml.StartElement:
-			// Flush any pending text content
-			text := strings.TrimSpace(textContent.String())
-			if text != "" && current != nil {
-				current.Value.Type = node.TypeString
-				current.Value.Worth = text
+			if err := d.checkStart(len(t.Attr)); err != nil {
+				return nil, err
 			}
-			textContent.Reset()
-
-			// Create new node
-			key := t.Name.Local
-			if key == "name" {
-				key = "name"
+			n, err := d.startElement(t)
+			if err != nil {
+				return nil, err
 			}
-			n := &node.Node{
-				Key: key,
-				Value: &node.Value{
-					Type: node.TypeObject,
-				},
+			if len(d.frames) == 1 && n.Key == "root" && !d.opts.Lossless && !document {
+				root = n
+				top.node = n
+				document = true
+			} else if err := top.node.AddToEnd(n); err != nil {
+				return nil, err
 			}
+			d.frames = append(d.frames, &xmlFrame{node: n, name: t.Name, path: joinPath(top.path, n.Key)})
 
-			// Handle attributes
-			if len(t.Attr) > 0 {
-				for _, attr := range t.Attr {
-					attrNode := &node.Node{
-						Key: "@" + attr.Name.Local,
-						Value: &node.Value{
-							Type:  node.TypeString,
-							Worth: attr.Value,
-						},
-					}
-					if err := n.AddToEnd(attrNode); err != nil {
-						return nil, err
-					}
-				}
+		case xml.EndElement:
+			if len(d.frames) == 1 || top.name != t.Name {
+				return nil, d.syntaxError("unexpected end element </" + qualifiedName(t.Name) + ">")
 			}
+			d.frames = d.frames[:len(d.frames)-1]
+			d.ns.pop()
+			d.endElement(top)
 
-			// Add to parent
-			if t.Name.Local != "root" {
-				if err := current.AddToEnd(n); err != nil {
-					return nil, err
+		case xml.CharData:
+			if err := d.checkText(top.text.Len() + len(t)); err != nil {
+				return nil, err
+			}
+			switch {
+			case len(d.frames) == 1:
+				// Only whitespace may surround the document element
+			case d.opts.Lossless:
+				key := textKey
+				if bytes.HasPrefix(raw, []byte("<![CDATA[")) {
+					key = cdataKey
 				}
-			} else {
-				root = n
-				current = n
-				continue
+				appendSpecial(top.node, key, string(t))
+			case strings.TrimSpace(string(t)) != "":
+				top.text.WriteString(string(t))
 			}
 
-			stack = append(stack, current)
-			current = n
-
-		case xml.EndElement:
-			// Flush any pending text content
-			text := strings.TrimSpace(textContent.String())
-			if text != "" && current != nil {
-				// Try to convert the text to appropriate type
-				if _, err := strconv.ParseFloat(text, 64); err == nil {
-					current.Value.Type = node.TypeNumber
-					current.Value.Worth = text
-				} else if _, err := strconv.ParseBool(text); err == nil {
-					current.Value.Type = node.TypeBoolean
-					current.Value.Worth = text
-				} else {
-					current.Value.Type = node.TypeString
-					current.Value.Worth = text
-				}
+		case xml.Comment:
+			if err := d.checkText(len(t)); err != nil {
+				return nil, err
+			}
+			if d.opts.Lossless {
+				appendSpecial(top.node, commentKey, string(t))
 			}
-			textContent.Reset()
 
-			if len(stack) > 0 {
-				current = stack[len(stack)-1]
-				stack = stack[:len(stack)-1]
+		case xml.ProcInst:
+			if err := d.checkText(len(t.Inst)); err != nil {
+				return nil, err
+			}
+			// The XML declaration is written by the encoder
+			if d.opts.Lossless && t.Target != "xml" {
+				appendSpecial(top.node, "?"+t.Target, string(t.Inst))
 			}
 
-		case xml.CharData:
-			text := string(t)
-			if strings.TrimSpace(text) != "" {
-				textContent.WriteString(text)
+		case xml.Directive:
+			// Directives only declare document types and what they hold
+			if !d.opts.AllowDoctype {
+				return nil, ErrDoctype
+			}
+			if d.opts.Lossless {
+				appendSpecial(top.node, directiveKey, string(t))
 			}
 		}
 	}
 
+	if document {
+		root.AddToValue(applyConvention(root.Value, d.opts.Convention))
+	} else {
+		groupRepeated(root, "", d.opts.ForceArray)
+		root.AddToValue(applyElements(root.Value, d.opts.Convention))
+	}
 	return root, nil
 }
 
+// token returns the next raw token and, in lossless mode, the input it was read from
+func (d *xmlDecoder) token() (xml.Token, []byte, error) {
+	if d.raw == nil {
+		token, err := d.dec.RawToken()
+		return token, nil, err
+	}
+	start := d.dec.InputOffset()
+	token, err := d.dec.RawToken()
+	return token, d.raw.slice(start, d.dec.InputOffset()), err
+}
+
+// syntaxError reports a malformed document at the current line
+func (d *xmlDecoder) syntaxError(msg string) error {
+	line, _ := d.dec.InputPos()
+	return &xml.SyntaxError{Msg: msg, Line: line}
+}
+
+// startElement opens the scope of an element and creates its node
+// holding the attributes
+func (d *xmlDecoder) startElement(t xml.StartElement) (*node.Node, error) {
+	d.ns.push(t.Attr)
+
+	// Create new node
+	n := &node.Node{
+		Key: d.ns.decodeName(t.Name, true, d.opts.NamespaceMode),
+		Value: &node.Value{
+			Type: node.TypeObject,
+		},
+	}
+
+	// Handle attributes
+	for _, attr := range t.Attr {
+		if err := d.checkText(len(attr.Value)); err != nil {
+			return nil, err
+		}
+		name := d.ns.decodeName(attr.Name, false, d.opts.NamespaceMode)
+		if isXmlns(attr.Name) {
+			if d.opts.NamespaceMode == NamespaceStrip {
+				continue
+			}
+			name = qualifiedName(attr.Name)
+		}
+		attrNode := &node.Node{
+			Key: "@" + name,
+			Value: &node.Value{
+				Type:  node.TypeString,
+				Worth: attr.Value,
+			},
+		}
+		if err := n.AddToEnd(attrNode); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// endElement completes the value of a closed element
+func (d *xmlDecoder) endElement(frame *xmlFrame) {
+	n := frame.node
+	if d.opts.Lossless {
+		d.endLossless(frame)
+		return
+	}
+
+	// Text of an element with children is kept beside them
+	if text := strings.TrimSpace(frame.text.String()); text != "" {
+		if hasChildren(n) {
+			appendSpecial(n, textKey, text)
+		} else {
+			setText(n.Value, text)
+		}
+	}
+
+	// Repeated children are complete once their parent closes
+	groupRepeated(n, frame.path, d.opts.ForceArray)
+}
+
+// endLossless completes an element decoded in lossless mode. An element
+// holding a single text becomes a scalar. Whitespace between children is
+// dropped unless the element has mixed content, and children are grouped
+// into arrays only when no text, comment or instruction lies between them.
+func (d *xmlDecoder) endLossless(frame *xmlFrame) {
+	n := frame.node
+
+	var texts []*node.Node
+	others, mixed := 0, false
+	for member := n.Value.Node; member != nil; member = member.Next {
+		switch {
+		case strings.HasPrefix(member.Key, "@"):
+		case member.Key == textKey:
+			texts = append(texts, member)
+			if strings.TrimSpace(member.Value.Worth) != "" {
+				mixed = true
+			}
+		default:
+			others++
+		}
+	}
+
+	if others == 0 && len(texts) == 1 {
+		text := texts[0].Value.Worth
+		texts[0].Delete()
+		if strings.TrimSpace(text) == text {
+			setText(n.Value, text)
+		} else {
+			n.Value.Type = node.TypeString
+			n.Value.Worth = text
+		}
+		return
+	}
+
+	if !mixed {
+		for _, text := range texts {
+			text.Delete()
+		}
+	}
+	for member := n.Value.Node; member != nil; member = member.Next {
+		if isSpecialKey(member.Key) {
+			return
+		}
+	}
+	groupRepeated(n, frame.path, d.opts.ForceArray)
+}
+
+// setText stores the text of an element without children as its value.
+// Only number literals in JSON syntax become numbers and keep their form.
+func setText(v *node.Value, text string) {
+	if number, err := node.ParseNumber(text); err == nil {
+		v.Type = node.TypeNumber
+		v.Worth = number.Worth
+		v.Kind = number.Kind
+	} else if _, err := strconv.ParseBool(text); err == nil {
+		v.Type = node.TypeBoolean
+		v.Worth = text
+	} else {
+		v.Type = node.TypeString
+		v.Worth = text
+	}
+}
+
+// hasChildren reports whether an element node holds child elements
+func hasChildren(n *node.Node) bool {
+	for member := n.Value.Node; member != nil; member = member.Next {
+		if !strings.HasPrefix(member.Key, "@") {
+			return true
+		}
+	}
+	return false
+}
+
+// appendSpecial appends a text, CDATA, comment, directive or processing
+// instruction member to an element node
+func appendSpecial(n *node.Node, key, worth string) {
+	n.AddToEnd(&node.Node{
+		Key: key,
+		Value: &node.Value{
+			Type:  node.TypeString,
+			Worth: worth,
+		},
+	})
+}
+
+// rawReader records the bytes an xml.Decoder reads, so that the input
+// behind a token can be inspected
+type rawReader struct {
+	r    *bufio.Reader
+	buf  []byte
+	base int64 // input offset of buf[0]
+}
+
+// ReadByte implements io.ByteReader, which xml.Decoder reads through
+func (r *rawReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, b)
+	}
+	return b, err
+}
+
+// Read implements io.Reader
+func (r *rawReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+// slice returns the input between two offsets and drops what precedes end.
+// Bytes past end were read ahead by the decoder and are kept.
+func (r *rawReader) slice(start, end int64) []byte {
+	if start < r.base || end-r.base > int64(len(r.buf)) || start > end {
+		return nil
+	}
+	raw := r.buf[start-r.base : end-r.base]
+	r.buf = r.buf[end-r.base:]
+	r.base = end
+	return raw
+}
+
+// groupRepeated turns repeated child elements of n into a single member
+// holding an array, in document order, so that no sibling is lost when the
+// tree is written as JSON or YAML. Children matching forceArray become
+// arrays even when they occur once. path is the dotted path of n.
+func groupRepeated(n *node.Node, path string, forceArray []string) {
+	if n.Value == nil || n.Value.Type != node.TypeObject {
+		return
+	}
+
+	first := make(map[string]*node.Node)
+	for member := n.Value.Node; member != nil; {
+		next := member.Next
+		if strings.HasPrefix(member.Key, "@") || isSpecialKey(member.Key) {
+			member = next
+			continue
+		}
+
+		head, repeated := first[member.Key]
+		switch {
+		case repeated:
+			if head.Value.Type != node.TypeArray {
+				toArray(head)
+			}
+			head.Value.Array = append(head.Value.Array, node.NewItem(len(head.Value.Array), member.Value))
+			member.Delete()
+		case isForced(member.Key, joinPath(path, member.Key), forceArray):
+			first[member.Key] = member
+			if member.Value.Type != node.TypeArray {
+				toArray(member)
+			}
+		default:
+			first[member.Key] = member
+		}
+		member = next
+	}
+}
+
+// toArray replaces the value of a member with an array holding it
+func toArray(member *node.Node) {
+	member.Value = &node.Value{
+		Type:  node.TypeArray,
+		Array: []*node.Value{node.NewItem(0, member.Value)},
+	}
+}
+
+// isForced reports whether an element matches an entry of ForceArray
+func isForced(key, path string, forceArray []string) bool {
+	for _, entry := range forceArray {
+		if entry == key || entry == path || strings.HasSuffix(path, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinPath appends a key to a dotted path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
 // NodeToXml converts a Node to XML bytes
 func NodeToXml(n *node.Node) ([]byte, error) {
+	return NodeToXmlWithOptions(n, XmlEncodeOptions{})
+}
+
+// NodeToXmlWithOptions converts a Node to XML bytes using the given options
+func NodeToXmlWithOptions(n *node.Node, opts XmlEncodeOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := EncodeToWithOptions(&buf, n, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeTo writes the XML form of a Node to w.
+// Output is produced while walking the tree instead of being built in memory first.
+func EncodeTo(w io.Writer, n *node.Node) error {
+	return EncodeToWithOptions(w, n, XmlEncodeOptions{})
+}
+
+// EncodeToWithOptions writes the XML form of a Node to w using the given options
+func EncodeToWithOptions(w io.Writer, n *node.Node, opts XmlEncodeOptions) error {
 	if n == nil {
-		return nil, fmt.Errorf("node is nil")
+		return fmt.Errorf("node is nil")
 	}
 
-	var buf bytes.Buffer
-	buf.WriteString(xml.Header)
+	buf := bufio.NewWriter(w)
+	e := &xmlEncoder{buf: buf, opts: opts, ns: &namespaces{}, lineStart: true}
+	e.ascii = opts.Encoding != "" && !strings.EqualFold(opts.Encoding, "UTF-8") && !strings.EqualFold(opts.Encoding, "UTF8")
+	if !opts.OmitDeclaration {
+		e.writeDeclaration()
+	}
+	if err := e.writeNode(n); err != nil {
+		return err
+	}
 
-	if err := writeNodeToXml(&buf, n); err != nil {
-		return nil, err
+	return buf.Flush()
+}
+
+// xmlEncoder writes a tree as XML, tracking the namespaces in scope
+type xmlEncoder struct {
+	buf  *bufio.Writer
+	opts XmlEncodeOptions
+	ns   *namespaces
+
+	ascii     bool // characters outside ASCII are written as references
+	depth     int  // nesting level of the element being written
+	mixed     int  // number of open elements holding text, which are not indented
+	lineStart bool // nothing was written on the current line yet
+}
+
+// writeDeclaration writes the XML declaration
+func (e *xmlEncoder) writeDeclaration() {
+	encoding := e.opts.Encoding
+	if encoding == "" {
+		encoding = "UTF-8"
+	}
+	e.buf.WriteString(`<?xml version="1.0" encoding="` + escapeXml(encoding) + `"`)
+	if e.opts.Standalone {
+		e.buf.WriteString(` standalone="yes"`)
 	}
+	e.buf.WriteString("?>\n")
+}
 
-	return buf.Bytes(), nil
+// breakLine starts a new indented line when pretty printing
+func (e *xmlEncoder) breakLine() {
+	if e.opts.Indent == "" || e.mixed > 0 {
+		return
+	}
+	if !e.lineStart {
+		e.buf.WriteByte('\n')
+	}
+	for i := 0; i < e.depth; i++ {
+		e.buf.WriteString(e.opts.Indent)
+	}
+	e.lineStart = false
 }
 
-func writeNodeToXml(buf *bytes.Buffer, n *node.Node) error {
+// writeNode writes n as the document element. An array held by it is
+// written as item elements, repeating the root element would not be XML.
+func (e *xmlEncoder) writeNode(n *node.Node) error {
 	if n == nil {
 		return nil
 	}
+	// A lossless document holds the document element and what surrounds it
+	if n.Key == documentKey && n.Value != nil && n.Value.Type == node.TypeObject {
+		_, content := xmlParts(n.Value, e.opts.Convention, "")
+		for _, part := range content {
+			if err := e.writeContent(part); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if e.opts.InferRoot {
+		if member := singleElement(n.Value); member != nil {
+			return e.writeElement(member.Key, member.Value)
+		}
+	}
+	key := n.Key
+	if e.opts.RootName != "" {
+		key = e.opts.RootName
+	}
+	return e.writeElement(key, n.Value)
+}
+
+// singleElement returns the member of an object holding nothing but a
+// single element that is not an array
+func singleElement(v *node.Value) *node.Node {
+	if v == nil || v.Type != node.TypeObject || v.Node == nil || v.Node.Next != nil {
+		return nil
+	}
+	member := v.Node
+	if strings.HasPrefix(member.Key, "@") || isSpecialKey(member.Key) || member.Value == nil || member.Value.Type == node.TypeArray {
+		return nil
+	}
+	return member
+}
+
+// writeMember writes an object member. Arrays are written as repeated
+// elements named by the member key, the form DecodeXml groups into arrays.
+func (e *xmlEncoder) writeMember(key string, v *node.Value) error {
+	if v == nil || v.Type != node.TypeArray || e.opts.ArrayStyle != ArrayRepeat {
+		return e.writeElement(key, v)
+	}
+	for _, item := range v.Array {
+		if item == nil {
+			continue
+		}
+		if err := e.writeElement(key, item.Elem()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeElement writes a value as an element with the given key
+func (e *xmlEncoder) writeElement(key string, v *node.Value) error {
+	buf := e.buf
+	members, content := xmlParts(v, e.opts.Convention, e.itemName(key))
+
+	// Namespaces declared by the element apply to its own name
+	attrs := make([]xml.Attr, 0, len(members)+1)
+	for _, member := range members {
+		if member.Value != nil {
+			text, err := e.text(member.Value)
+			if err != nil {
+				return err
+			}
+			name, err := e.name(member.Key[1:])
+			if err != nil {
+				return err
+			}
+			attrs = append(attrs, xml.Attr{Name: parseQualifiedName(name), Value: text})
+		}
+	}
+	if e.opts.TagAttribute != "" && v != nil && v.Tag != "" {
+		attrs = append(attrs, xml.Attr{Name: parseQualifiedName(e.opts.TagAttribute), Value: v.Tag})
+	}
+	e.ns.push(attrs)
+	defer e.ns.pop()
 
-	// Get tag name
-	tagName := n.Key
-	if tagName == "n" {
-		tagName = "name"
+	name, err := e.name(key)
+	if err != nil {
+		return err
 	}
+	tagName, declarations := e.ns.encodeName(name, true, e.opts.Prefixes, nil)
 
 	// Start tag
+	e.breakLine()
 	buf.WriteByte('<')
 	buf.WriteString(tagName)
 
 	// Write attributes
-	if n.Value != nil && n.Value.Node != nil {
-		current := n.Value.Node
-		for current != nil {
-			if strings.HasPrefix(current.Key, "@") {
-				buf.WriteByte(' ')
-				buf.WriteString(strings.TrimPrefix(current.Key, "@"))
-				buf.WriteString("=\"")
-				buf.WriteString(escapeXml(current.Value.Worth))
-				buf.WriteByte('"')
-			}
-			current = current.Next
+	for _, attr := range attrs {
+		name := qualifiedName(attr.Name)
+		if !isXmlns(attr.Name) {
+			name, declarations = e.ns.encodeName(name, false, e.opts.Prefixes, declarations)
 		}
+		buf.WriteByte(' ')
+		buf.WriteString(name)
+		buf.WriteString("=\"")
+		buf.WriteString(e.escape(attr.Value))
+		buf.WriteByte('"')
+	}
+	for _, decl := range declarations {
+		buf.WriteByte(' ')
+		buf.WriteString(qualifiedName(decl.Name))
+		buf.WriteString("=\"")
+		buf.WriteString(escapeXml(decl.Value))
+		buf.WriteByte('"')
 	}
 
-	// Check if element is empty
-	isEmpty := n.Value == nil ||
-		(n.Value.Type == node.TypeObject && n.Value.Worth == "" &&
-			(n.Value.Node == nil || onlyHasAttributes(n.Value.Node)))
-
-	if isEmpty && !hasNonAttributeChildren(n) {
+	if len(content) == 0 {
 		buf.WriteString("/>")
 		return nil
 	}
 
 	buf.WriteByte('>')
 
-	if n.Value != nil {
-		switch n.Value.Type {
-		case node.TypeString, node.TypeNumber, node.TypeBoolean:
-			buf.WriteString(escapeXml(n.Value.Worth))
-		case node.TypeArray:
-			for _, item := range n.Value.Array {
-				if item != nil {
-					if item.Node != nil {
-						if err := writeNodeToXml(buf, item.Node); err != nil {
-							return err
-						}
-					} else {
-						// Write array item as element
-						buf.WriteString("<item>")
-						buf.WriteString(escapeXml(item.Worth))
-						buf.WriteString("</item>")
-					}
-				}
-			}
-		case node.TypeObject:
-			if n.Value.Node != nil {
-				current := n.Value.Node
-				for current != nil {
-					if !strings.HasPrefix(current.Key, "@") {
-						if err := writeNodeToXml(buf, current); err != nil {
-							return err
-						}
-					}
-					current = current.Next
-				}
-			}
+	// Indenting the children of an element holding text would change it
+	mixed := hasText(content)
+	if mixed {
+		e.mixed++
+	}
+	e.depth++
+	for _, part := range content {
+		if err := e.writeContent(part); err != nil {
+			return err
 		}
 	}
+	e.depth--
+	if mixed {
+		e.mixed--
+	} else {
+		e.breakLine()
+	}
 
 	// End tag
 	buf.WriteString("</")
@@ -237,34 +808,213 @@ func writeNodeToXml(buf *bytes.Buffer, n *node.Node) error {
 	return nil
 }
 
-// onlyHasAttributes checks if a node only has attribute nodes
-func onlyHasAttributes(n *node.Node) bool {
-	current := n
-	for current != nil {
-		if !strings.HasPrefix(current.Key, "@") {
-			return false
+// writeContent writes a part of the content of an element: text, CDATA,
+// a comment, a directive, a processing instruction or child elements.
+// Array items are written as one element each, so that an array nested in
+// an array keeps its own element.
+func (e *xmlEncoder) writeContent(part xmlContent) error {
+	buf := e.buf
+	key, v := part.key, part.value
+	if part.item {
+		return e.writeElement(key, v)
+	}
+	if key != textKey && key != cdataKey && isSpecialKey(key) {
+		e.breakLine()
+	}
+	switch {
+	case key == textKey:
+		text, err := e.text(v)
+		if err != nil {
+			return err
 		}
-		current = current.Next
+		buf.WriteString(e.escape(text))
+	case key == cdataKey:
+		// A CDATA section cannot contain its end marker, split it there
+		buf.WriteString("<![CDATA[")
+		buf.WriteString(strings.ReplaceAll(v.Worth, "]]>", "]]]]><![CDATA[>"))
+		buf.WriteString("]]>")
+	case key == commentKey:
+		if strings.Contains(v.Worth, "--") || strings.HasSuffix(v.Worth, "-") {
+			return fmt.Errorf("invalid XML comment %q", v.Worth)
+		}
+		buf.WriteString("<!--")
+		buf.WriteString(v.Worth)
+		buf.WriteString("-->")
+	case key == directiveKey:
+		buf.WriteString("<!")
+		buf.WriteString(v.Worth)
+		buf.WriteByte('>')
+	case strings.HasPrefix(key, "?"):
+		target := key[1:]
+		if target == "" || strings.EqualFold(target, "xml") || strings.Contains(v.Worth, "?>") {
+			return fmt.Errorf("invalid XML processing instruction %q", key)
+		}
+		buf.WriteString("<?")
+		buf.WriteString(target)
+		if v.Worth != "" {
+			buf.WriteByte(' ')
+			buf.WriteString(v.Worth)
+		}
+		buf.WriteString("?>")
+	default:
+		return e.writeMember(key, v)
+	}
+	return nil
+}
+
+// text returns the text of a scalar, with timestamps and binary values
+// in the form the options ask for
+func (e *xmlEncoder) text(v *node.Value) (string, error) {
+	switch v.Type {
+	case node.TypeTimestamp:
+		return v.FormatTime(e.opts.TimeLayout)
+	case node.TypeBinary:
+		return v.FormatBinary(e.opts.Binary)
 	}
-	return true
+	return v.Worth, nil
 }
 
-// hasNonAttributeChildren checks if a node has any non-attribute children
-func hasNonAttributeChildren(n *node.Node) bool {
-	if n.Value == nil || n.Value.Node == nil {
-		return false
+// escape escapes text or an attribute value, writing characters outside
+// ASCII as character references when the encoding asks for it
+func (e *xmlEncoder) escape(s string) string {
+	s = escapeXml(s)
+	if !e.ascii {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+		} else {
+			fmt.Fprintf(&b, "&#x%X;", r)
+		}
 	}
+	return b.String()
+}
 
-	current := n.Value.Node
-	for current != nil {
-		if !strings.HasPrefix(current.Key, "@") {
+// name returns a key to write as an element or attribute name, made valid
+// when the options ask for it. Other keys that are not valid XML names are
+// an error.
+func (e *xmlEncoder) name(key string) (string, error) {
+	// Only the local part of a name in Clark notation is a name
+	namespace, local := "", key
+	if strings.HasPrefix(key, "{") {
+		if end := strings.IndexByte(key, '}'); end > 0 {
+			namespace, local = key[:end+1], key[end+1:]
+		}
+	}
+	if e.opts.SanitizeNames {
+		return namespace + sanitizeName(local), nil
+	}
+	if !isName(local) {
+		return "", fmt.Errorf("xml: %q is not a valid XML name", key)
+	}
+	return key, nil
+}
+
+// sanitizeName replaces the characters of a name that XML does not allow
+// with underscores and prefixes one when the name cannot start as it does
+func sanitizeName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case isNameStart(r):
+		case i == 0 && isNameChar(r):
+			b.WriteByte('_')
+		case isNameChar(r):
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isName reports whether s is a valid XML name
+func isName(s string) bool {
+	return s != "" && sanitizeName(s) == s
+}
+
+// isNameStart reports whether r may start an XML name
+func isNameStart(r rune) bool {
+	return r == ':' || r == '_' || unicode.IsLetter(r)
+}
+
+// isNameChar reports whether r may appear in an XML name after its start
+func isNameChar(r rune) bool {
+	return isNameStart(r) || r == '-' || r == '.' || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == 0xB7
+}
+
+// hasText reports whether the content of an element holds text or CDATA
+func hasText(content []xmlContent) bool {
+	for _, part := range content {
+		if part.key == textKey || part.key == cdataKey {
 			return true
 		}
-		current = current.Next
 	}
 	return false
 }
 
+// itemName returns the name of the elements of the items of an array
+// written as the element with the given key
+func (e *xmlEncoder) itemName(key string) string {
+	if e.opts.ArrayStyle == ArraySingular {
+		if singular := singularize(key); singular != key {
+			return singular
+		}
+	}
+	if e.opts.ItemName != "" {
+		return e.opts.ItemName
+	}
+	return "item"
+}
+
+// irregularPlurals are plural nouns whose singular does not follow the
+// rules of singularize
+var irregularPlurals = map[string]string{
+	"children": "child",
+	"people":   "person",
+	"men":      "man",
+	"women":    "woman",
+	"feet":     "foot",
+	"teeth":    "tooth",
+	"mice":     "mouse",
+	"indices":  "index",
+}
+
+// singularize returns the singular of an English plural noun, or the word
+// itself when it does not look like one
+func singularize(word string) string {
+	lower := strings.ToLower(word)
+	for plural, singular := range irregularPlurals {
+		if strings.HasSuffix(lower, plural) && (len(lower) == len(plural) || !unicode.IsLetter(rune(lower[len(lower)-len(plural)-1]))) {
+			return word[:len(word)-len(plural)] + matchCase(word[len(word)-len(plural):], singular)
+		}
+	}
+	switch {
+	case len(lower) > 3 && strings.HasSuffix(lower, "ies"):
+		return word[:len(word)-3] + matchCase(word[len(word)-3:], "y")
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"),
+		strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return word[:len(word)-2]
+	case len(lower) > 1 && strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss") &&
+		!strings.HasSuffix(lower, "us") && !strings.HasSuffix(lower, "is"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// matchCase returns s in upper case when the text it replaces is
+func matchCase(replaced, s string) string {
+	if replaced == strings.ToUpper(replaced) {
+		return strings.ToUpper(s)
+	}
+	return s
+}
+
 func escapeXml(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
 	s = strings.ReplaceAll(s, "<", "&lt;")
@@ -1,9 +1,14 @@
 package txml
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/mstgnz/transformer/node"
 )
 
 func TestIsXml(t *testing.T) {
@@ -141,6 +146,180 @@ func TestDecodeXml(t *testing.T) {
 	}
 }
 
+func TestDecodeXmlNumbers(t *testing.T) {
+	data := []byte(`<order><id>9007199254740993</id><total>19.90</total><code>0x1F</code><zip>02134</zip><limit>Inf</limit></order>`)
+	n, err := DecodeXml(data)
+	if err != nil {
+		t.Fatalf("DecodeXml() error = %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		typ   node.ValueType
+		worth string
+	}{
+		{"order.id", node.TypeNumber, "9007199254740993"},
+		{"order.total", node.TypeNumber, "19.90"},
+		{"order.code", node.TypeString, "0x1F"},
+		{"order.zip", node.TypeString, "02134"},
+		{"order.limit", node.TypeString, "Inf"},
+	}
+	for _, tt := range tests {
+		v, err := n.GetByPath(tt.path)
+		if err != nil {
+			t.Fatalf("GetByPath(%q) error = %v", tt.path, err)
+		}
+		if v.Type != tt.typ || v.Worth != tt.worth {
+			t.Errorf("%s = %s %q, want %s %q", tt.path, v.Type, v.Worth, tt.typ, tt.worth)
+		}
+	}
+}
+
+func TestDecodeXml_RepeatedElements(t *testing.T) {
+	data, err := os.ReadFile("../example/files/valid.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := DecodeXml(data)
+	if err != nil {
+		t.Fatalf("DecodeXml() error = %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"spec.containers[0].name", "front-end"},
+		{"spec.containers[1].name", "rss-reader"},
+		{"spec.containers[0].ports.port[0]", "34"},
+		{"spec.containers[0].ports.port[0].@port", "34"},
+		{"spec.containers[0].ports.port[1]", "55"},
+		{"spec.containers[1].ports.test[3]", "new"},
+		{"spec.containers[1].image.@img", "nginx"},
+	}
+	for _, tt := range tests {
+		v, err := n.GetByPath(tt.path)
+		if err != nil {
+			t.Errorf("GetByPath(%q) error = %v", tt.path, err)
+			continue
+		}
+		if v.Worth != tt.want {
+			t.Errorf("GetByPath(%q) = %q, want %q", tt.path, v.Worth, tt.want)
+		}
+	}
+	if err := n.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestDecodeXml_ForceArray(t *testing.T) {
+	tests := []struct {
+		name  string
+		force []string
+		want  node.ValueType
+	}{
+		{name: "default", want: node.TypeNumber},
+		{name: "key", force: []string{"port"}, want: node.TypeArray},
+		{name: "path", force: []string{"ports.port"}, want: node.TypeArray},
+		{name: "full path", force: []string{"service.ports.port"}, want: node.TypeArray},
+		{name: "other path", force: []string{"hosts.port"}, want: node.TypeNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := DecodeXmlWithOptions([]byte(`<service><ports><port>80</port></ports></service>`),
+				XmlDecodeOptions{ForceArray: tt.force})
+			if err != nil {
+				t.Fatalf("DecodeXmlWithOptions() error = %v", err)
+			}
+			v, err := n.GetByPath("service.ports.port")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Type != tt.want {
+				t.Errorf("port type = %s, want %s", v.Type, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeToXml_Arrays(t *testing.T) {
+	root := node.NewNode("root")
+	root.Set("ports", []int{80, 443})
+	root.Set("hosts", []map[string]string{{"name": "a"}, {"name": "b"}})
+	root.Set("matrix", [][]int{{1, 2}})
+
+	got, err := NodeToXml(root)
+	if err != nil {
+		t.Fatalf("NodeToXml() error = %v", err)
+	}
+	want := "<root><ports>80</ports><ports>443</ports>" +
+		"<hosts><name>a</name></hosts><hosts><name>b</name></hosts>" +
+		"<matrix><item>1</item><item>2</item></matrix></root>"
+	if normalizeXml(string(got)) != want {
+		t.Errorf("NodeToXml() = %s, want %s", got, want)
+	}
+}
+
+func TestNodeToXml_NestedArrays(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+		paths map[string]string
+	}{
+		{
+			name:  "array root",
+			value: [][]string{{"a", "b"}, {"c"}},
+			want:  "<root><item><item>a</item><item>b</item></item><item><item>c</item></item></root>",
+			paths: map[string]string{"item[0].item[1]": "b", "item[1].item": "c"},
+		},
+		{
+			name:  "array member",
+			value: map[string]any{"a": [][][]int{{{1, 2}}, {{3}}}},
+			want:  "<root><a><item><item>1</item><item>2</item></item></a><a><item><item>3</item></item></a></root>",
+			paths: map[string]string{"a[0].item.item[1]": "2", "a[1].item.item": "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := node.NewNode("root")
+			v, err := node.ValueOf(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root.AddToValue(v)
+
+			got, err := NodeToXml(root)
+			if err != nil {
+				t.Fatalf("NodeToXml() error = %v", err)
+			}
+			if normalizeXml(string(got)) != tt.want {
+				t.Fatalf("NodeToXml() = %s, want %s", got, tt.want)
+			}
+
+			// Every item keeps its own element, so nothing is lost on the way back
+			decoded, err := DecodeXml(got)
+			if err != nil {
+				t.Fatalf("DecodeXml() error = %v", err)
+			}
+			for path, want := range tt.paths {
+				if v, err := decoded.GetByPath(path); err != nil || v.Worth != want {
+					t.Errorf("GetByPath(%s) = %v, %v, want %s", path, v, err, want)
+				}
+			}
+			again, err := NodeToXml(decoded)
+			if err != nil {
+				t.Fatalf("NodeToXml() of the decoded tree error = %v", err)
+			}
+			if normalizeXml(string(again)) != tt.want {
+				t.Errorf("NodeToXml() of the decoded tree = %s, want %s", again, tt.want)
+			}
+		})
+	}
+}
+
 func TestNodeToXml(t *testing.T) {
 	tests := []struct {
 		name string
@@ -214,3 +393,195 @@ func normalizeXml(xml string) string {
 
 	return xml
 }
+
+func TestDecodeXmlReader(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte(`<root><name>John</name>`))
+		pw.Write([]byte(`<age>30</age></root>`))
+		pw.Close()
+	}()
+
+	n, err := DecodeXmlReader(pr)
+	if err != nil {
+		t.Fatalf("DecodeXmlReader() error = %v", err)
+	}
+	if n.Value.Node == nil || n.Value.Node.Next == nil || n.Value.Node.Next.Key != "age" {
+		t.Errorf("DecodeXmlReader() did not decode all elements")
+	}
+
+	if _, err := DecodeXmlReader(strings.NewReader(`<root><name>`)); err == nil {
+		t.Error("DecodeXmlReader() expected error for truncated input")
+	}
+}
+
+func TestEncodeTo(t *testing.T) {
+	n, err := DecodeXml([]byte(`<root><name>John</name></root>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeTo(&buf, n); err != nil {
+		t.Fatalf("EncodeTo() error = %v", err)
+	}
+	if got, want := normalizeXml(buf.String()), "<root><name>John</name></root>"; got != want {
+		t.Errorf("EncodeTo() = %s, want %s", got, want)
+	}
+
+	if err := EncodeTo(errWriter{}, n); err == nil {
+		t.Error("EncodeTo() expected error from failing writer")
+	}
+}
+
+// errWriter is an io.Writer that always fails
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestNodeToXml_TaggedValues(t *testing.T) {
+	owner := node.NewString("Owner")
+	owner.Tag = "!Ref"
+	root := node.NewNode("root")
+	root.AddToValue(node.NewObject(
+		&node.Node{Key: "owner", Value: owner},
+		&node.Node{Key: "created", Value: &node.Value{Type: node.TypeTimestamp, Worth: "2024-05-01"}},
+		&node.Node{Key: "icon", Value: node.NewBinary([]byte("hi"))},
+	))
+
+	got, err := NodeToXmlWithOptions(root, XmlEncodeOptions{
+		TimeLayout:   "2006-01-02T15:04:05Z07:00",
+		Binary:       node.BinaryHex,
+		TagAttribute: "tag",
+	})
+	if err != nil {
+		t.Fatalf("NodeToXmlWithOptions() error = %v", err)
+	}
+	want := `<root><owner tag="!Ref">Owner</owner><created>2024-05-01T00:00:00Z</created><icon>6869</icon></root>`
+	if normalizeXml(string(got)) != want {
+		t.Errorf("NodeToXmlWithOptions() = %s, want %s", got, want)
+	}
+}
+
+func TestNodeToXmlWithOptions_Format(t *testing.T) {
+	doc, err := DecodeXml([]byte(`<person><n>Zoë</n><tags>a</tags><tags>b</tags><note lang="en">hi <b>there</b></note></person>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts XmlEncodeOptions
+		want string
+	}{
+		{
+			name: "default",
+			want: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<root><person><n>Zoë</n><tags>a</tags><tags>b</tags><note lang="en"><b>there</b>hi</note></person></root>`,
+		},
+		{
+			name: "indent and inferred root",
+			opts: XmlEncodeOptions{Indent: "  ", InferRoot: true},
+			want: `<?xml version="1.0" encoding="UTF-8"?>` + `
+<person>
+  <n>Zoë</n>
+  <tags>a</tags>
+  <tags>b</tags>
+  <note lang="en"><b>there</b>hi</note>
+</person>`,
+		},
+		{
+			name: "root name and declaration",
+			opts: XmlEncodeOptions{RootName: "doc", Encoding: "US-ASCII", Standalone: true},
+			want: `<?xml version="1.0" encoding="US-ASCII" standalone="yes"?>` + "\n" +
+				`<doc><person><n>Zo&#xEB;</n><tags>a</tags><tags>b</tags><note lang="en"><b>there</b>hi</note></person></doc>`,
+		},
+		{
+			name: "singular items",
+			opts: XmlEncodeOptions{OmitDeclaration: true, InferRoot: true, ArrayStyle: ArraySingular},
+			want: `<person><n>Zoë</n><tags><tag>a</tag><tag>b</tag></tags><note lang="en"><b>there</b>hi</note></person>`,
+		},
+		{
+			name: "fixed item name",
+			opts: XmlEncodeOptions{OmitDeclaration: true, InferRoot: true, ArrayStyle: ArrayItemName, ItemName: "li"},
+			want: `<person><n>Zoë</n><tags><li>a</li><li>b</li></tags><note lang="en"><b>there</b>hi</note></person>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NodeToXmlWithOptions(doc, tt.opts)
+			if err != nil {
+				t.Fatalf("NodeToXmlWithOptions() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("NodeToXmlWithOptions() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeToXmlWithOptions_SanitizeNames(t *testing.T) {
+	root := node.NewNode("root")
+	for _, set := range []struct {
+		path string
+		data any
+	}{
+		{`["2nd place"]`, "silver"},
+		{`["first name"]`, "Ada"},
+		{`["-x.y"]`, 1},
+		{`[""]`, true},
+		{`["ok-name_1"]`, 2},
+	} {
+		if err := root.Set(set.path, set.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := NodeToXmlWithOptions(root, XmlEncodeOptions{SanitizeNames: true, OmitDeclaration: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<root><_2nd_place>silver</_2nd_place><first_name>Ada</first_name><_-x.y>1</_-x.y><_>true</_><ok-name_1>2</ok-name_1></root>`
+	if string(got) != want {
+		t.Errorf("NodeToXmlWithOptions() = %s, want %s", got, want)
+	}
+	if !IsXml(got) {
+		t.Errorf("sanitized output is not valid XML")
+	}
+}
+
+func TestNodeToXml_InvalidNames(t *testing.T) {
+	for _, path := range []string{`["<<"]`, `["2nd place"]`, `["@a b"]`, `["ok"]["@1x"]`} {
+		root := node.NewNode("root")
+		if err := root.Set(path, "x"); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := NodeToXml(root); err == nil {
+			t.Errorf("%s: NodeToXml() = %s, want an error", path, got)
+		}
+	}
+}
+
+func TestSingularize(t *testing.T) {
+	tests := map[string]string{
+		"ports":     "port",
+		"entries":   "entry",
+		"boxes":     "box",
+		"classes":   "class",
+		"matches":   "match",
+		"children":  "child",
+		"ns:people": "ns:person",
+		"ITEMS":     "ITEM",
+		"status":    "status",
+		"address":   "address",
+		"data":      "data",
+	}
+	for plural, want := range tests {
+		if got := singularize(plural); got != want {
+			t.Errorf("singularize(%q) = %q, want %q", plural, got, want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package tyaml
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mstgnz/transformer/node"
+	"gopkg.in/yaml.v3"
+)
+
+// AliasMode selects how anchors, aliases and merge keys are decoded
+type AliasMode int
+
+const (
+	// ExpandAliases replaces every alias with a copy of its anchored value
+	// and applies merge keys, so the tree holds plain data
+	ExpandAliases AliasMode = iota
+	// PreserveAliases keeps anchors and aliases in the tree: the anchored
+	// value records its name in Value.Anchor, and an alias is a value that
+	// records the name in Value.Alias and shares the content of the anchored
+	// value, so reading through it resolves the reference. Merge keys of
+	// aliases are kept as "<<" members, other ones are applied. NodeToYaml
+	// writes them all back, and Expand turns the tree into the expanded
+	// form, which other formats should be given instead.
+	PreserveAliases
+)
+
+// maxAliasExpansion is the default limit on how many nodes may be produced
+// by expanding aliases, protecting against "billion laughs" style documents
+const maxAliasExpansion = 1 << 20
+
+// ErrExcessiveAliasing is returned when expanding the aliases of a document
+// would produce more nodes than YamlDecodeOptions.MaxAliasExpansion allows
+var ErrExcessiveAliasing = errors.New("yaml: document contains excessive aliasing")
+
+// anchored is the decoded value of an anchor and its size in nodes once
+// its own aliases are expanded
+type anchored struct {
+	value *node.Value
+	size  int
+}
+
+// expand counts nodes produced through alias expansion against the budget
+func (d *decoder) expand(size int) error {
+	max := d.opts.MaxAliasExpansion
+	if max <= 0 {
+		max = maxAliasExpansion
+	}
+	d.expanded += size
+	if d.expanded > max {
+		return ErrExcessiveAliasing
+	}
+	return nil
+}
+
+// anchor decodes a value carrying an anchor in PreserveAliases mode and
+// records it for the aliases that follow
+func (d *decoder) anchor(key string, y *yaml.Node) (*node.Node, error) {
+	before := d.count
+	n, err := yamlValue(d, key, y)
+	if err != nil {
+		return nil, err
+	}
+	n.Value.Anchor = y.Anchor
+	if d.anchors == nil {
+		d.anchors = make(map[*yaml.Node]*anchored)
+	}
+	d.anchors[y] = &anchored{value: n.Value, size: d.count - before}
+	return n, nil
+}
+
+// alias decodes an alias in PreserveAliases mode into a value sharing the
+// content of its anchor. Expanding it is not needed, but a consumer walking
+// the tree will, so its expanded size still counts against the budget.
+func (d *decoder) alias(key string, y *yaml.Node) (*node.Node, error) {
+	a, ok := d.anchors[y.Alias]
+	if !ok {
+		return nil, fmt.Errorf("yaml: anchor %q contains an alias to itself", y.Value)
+	}
+	if err := d.expand(a.size); err != nil {
+		return nil, err
+	}
+	d.count += a.size
+	return &node.Node{
+		Key: key,
+		Value: &node.Value{
+			Type:  a.value.Type,
+			Worth: a.value.Worth,
+			Node:  a.value.Node,
+			Array: a.value.Array,
+			Kind:  a.value.Kind,
+			Alias: y.Value,
+
+			Comments: commentsOf(y),
+		},
+	}, nil
+}
+
+// Expand returns a copy of a tree decoded with PreserveAliases in which
+// every alias is replaced by a copy of its anchored value and merge keys
+// are applied, as if it had been decoded with ExpandAliases
+func Expand(n *node.Node) (*node.Node, error) {
+	if n == nil {
+		return nil, fmt.Errorf("node is nil")
+	}
+	return expandNode(n.Key, n.Comments, n.Value)
+}
+
+// expandNode returns a node holding a copy of v without anchors, aliases
+// and merge keys
+func expandNode(key string, comments node.Comments, v *node.Value) (*node.Node, error) {
+	n := &node.Node{Key: key, Comments: comments}
+	if v == nil {
+		n.Value = &node.Value{Type: node.TypeNull}
+		return n, nil
+	}
+	n.Value = &node.Value{
+		Type:  v.Type,
+		Worth: v.Worth,
+		Kind:  v.Kind,
+		Tag:   v.Tag,
+
+		Comments: v.Comments,
+	}
+
+	switch v.Type {
+	case node.TypeObject:
+		if err := expandMembers(n, v, make(map[string]bool), false); err != nil {
+			return nil, err
+		}
+	case node.TypeArray:
+		n.Value.Array = make([]*node.Value, len(v.Array))
+		for i, item := range v.Array {
+			child, err := expandNode(fmt.Sprintf("item%d", i), node.Comments{}, item.Elem())
+			if err != nil {
+				return nil, err
+			}
+			n.Value.Array[i] = node.NewItem(i, child.Value)
+		}
+	}
+	return n, nil
+}
+
+// expandMembers appends copies of the members of an object to n the way
+// addMapping does: "<<" members are merged in place and keys in seen are
+// skipped. Merged members leave their comments behind.
+func expandMembers(n *node.Node, v *node.Value, seen map[string]bool, merged bool) error {
+	explicit := make(map[string]bool)
+	for member := v.Node; member != nil; member = member.Next {
+		if !isMerge(member) {
+			explicit[member.Key] = true
+		}
+	}
+
+	for member := v.Node; member != nil; member = member.Next {
+		if isMerge(member) {
+			if err := expandMerge(n, member.Value, seen, explicit); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if seen[member.Key] {
+			continue
+		}
+		seen[member.Key] = true
+
+		comments := member.Comments
+		if merged {
+			comments = node.Comments{}
+		}
+		child, err := expandNode(member.Key, comments, member.Value)
+		if err != nil {
+			return err
+		}
+		if err := n.AddToEnd(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandMerge applies the value of a "<<" member, an object or a list of
+// objects, to n
+func expandMerge(n *node.Node, v *node.Value, seen, explicit map[string]bool) error {
+	switch v.Type {
+	case node.TypeObject:
+		// Explicit keys win over merged ones regardless of their position
+		skip := make(map[string]bool, len(seen)+len(explicit))
+		for k := range seen {
+			skip[k] = true
+		}
+		for k := range explicit {
+			skip[k] = true
+		}
+		if err := expandMembers(n, v, skip, true); err != nil {
+			return err
+		}
+		for k := range skip {
+			if !explicit[k] {
+				seen[k] = true
+			}
+		}
+		return nil
+
+	case node.TypeArray:
+		for _, item := range v.Array {
+			if err := expandMerge(n, item.Elem(), seen, explicit); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("yaml: map merge requires a mapping or a list of mappings")
+	}
+}
+
+// isMerge reports whether a member is a preserved merge key
+func isMerge(member *node.Node) bool {
+	return member.Key == "<<" && member.Value != nil && isMergeValue(member.Value)
+}
+
+// isMergeValue reports whether the value of a "<<" member is a preserved
+// merge, that is an alias or a list of aliases
+func isMergeValue(v *node.Value) bool {
+	if v.Alias != "" {
+		return true
+	}
+	if v.Type != node.TypeArray || len(v.Array) == 0 {
+		return false
+	}
+	for _, item := range v.Array {
+		if item == nil || item.Elem().Alias == "" {
+			return false
+		}
+	}
+	return true
+}
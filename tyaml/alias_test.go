@@ -0,0 +1,183 @@
+package tyaml
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/transformer/tjson"
+	"github.com/mstgnz/transformer/txml"
+)
+
+const ciConfig = `defaults: &defaults
+    image: golang
+    env: &env
+    - CGO_ENABLED=0
+build:
+    <<: *defaults
+    script: go build
+test:
+    <<: *defaults
+    image: golang:race
+    env: *env
+`
+
+func TestDecodeYaml_PreserveAliases(t *testing.T) {
+	n, err := DecodeYamlWithOptions([]byte(ciConfig), YamlDecodeOptions{AliasMode: PreserveAliases})
+	if err != nil {
+		t.Fatalf("DecodeYamlWithOptions() error = %v", err)
+	}
+	if err := n.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	defaults, err := n.GetByPath("defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaults.Anchor != "defaults" {
+		t.Errorf("Anchor = %q, want defaults", defaults.Anchor)
+	}
+	merge, err := n.GetByPointer("/build/<<")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merge.Alias != "defaults" {
+		t.Errorf("Alias = %q, want defaults", merge.Alias)
+	}
+
+	// Aliases resolve to the content of their anchor
+	env, err := n.GetByPointer("/test/env/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Worth != "CGO_ENABLED=0" {
+		t.Errorf("test.env[0] = %q, want CGO_ENABLED=0", env.Worth)
+	}
+
+	got, err := NodeToYaml(n)
+	if err != nil {
+		t.Fatalf("NodeToYaml() error = %v", err)
+	}
+	if got != ciConfig {
+		t.Errorf("NodeToYaml() = %q, want %q", got, ciConfig)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	n, err := DecodeYamlWithOptions([]byte(ciConfig), YamlDecodeOptions{AliasMode: PreserveAliases})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expanded, err := Expand(n)
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	direct, err := DecodeYaml([]byte(ciConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := tjson.NodeToJson(expanded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := tjson.NodeToJson(direct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("Expand() = %s, want %s", got, want)
+	}
+	if want := `{"defaults":{"image":"golang","env":["CGO_ENABLED=0"]},` +
+		`"build":{"image":"golang","env":["CGO_ENABLED=0"],"script":"go build"},` +
+		`"test":{"image":"golang:race","env":["CGO_ENABLED=0"]}}`; string(got) != want {
+		t.Errorf("Expand() = %s, want %s", got, want)
+	}
+}
+
+func TestExpand_Merges(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "explicit keys win", data: "base: &base {a: 1, b: 2}\nx:\n    b: 3\n    <<: *base\n    c: 4\n"},
+		{name: "list of aliases", data: "a: &a {x: 1, y: 1}\nb: &b {y: 2, z: 2}\nc:\n    <<: [*a, *b]\n"},
+		{name: "nested merge", data: "a: &a {x: 1}\nb: &b\n    <<: *a\n    y: 2\nc:\n    <<: *b\n    z: 3\n"},
+		{name: "inline mapping", data: "a:\n    <<: {x: 1}\n    y: 2\n"},
+		{name: "aliased items", data: "item: &item {name: x}\nlist:\n    - *item\n    - <<: *item\n      size: 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := DecodeYamlWithOptions([]byte(tt.data), YamlDecodeOptions{AliasMode: PreserveAliases})
+			if err != nil {
+				t.Fatal(err)
+			}
+			expanded, err := Expand(n)
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if err := expanded.Validate(); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+			direct, err := DecodeYaml([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := NodeToYaml(expanded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := NodeToYaml(direct)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("Expand() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestExpand_OtherFormats(t *testing.T) {
+	n, err := DecodeYamlWithOptions([]byte(ciConfig), YamlDecodeOptions{AliasMode: PreserveAliases})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "<<" is not an XML name
+	if _, err := txml.NodeToXml(n); err == nil {
+		t.Errorf("NodeToXml() of a preserved merge key, want an error")
+	}
+
+	expanded, err := Expand(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := txml.NodeToXmlWithOptions(expanded, txml.XmlEncodeOptions{OmitDeclaration: true})
+	if err != nil {
+		t.Fatalf("NodeToXml() error = %v", err)
+	}
+	want := `<root><defaults><image>golang</image><env>CGO_ENABLED=0</env></defaults>` +
+		`<build><image>golang</image><env>CGO_ENABLED=0</env><script>go build</script></build>` +
+		`<test><image>golang:race</image><env>CGO_ENABLED=0</env></test></root>`
+	if string(got) != want {
+		t.Errorf("NodeToXml() = %s, want %s", got, want)
+	}
+}
+
+func TestDecodeYaml_AliasBudget(t *testing.T) {
+	data := []byte("a: &a [x, x, x, x]\nb: &b [*a, *a, *a, *a]\nc: [*b, *b, *b, *b]\n")
+
+	for _, mode := range []AliasMode{ExpandAliases, PreserveAliases} {
+		opts := YamlDecodeOptions{AliasMode: mode, MaxAliasExpansion: 50}
+		if _, err := DecodeYamlWithOptions(data, opts); !errors.Is(err, ErrExcessiveAliasing) {
+			t.Errorf("mode %d: DecodeYamlWithOptions() error = %v, want ErrExcessiveAliasing", mode, err)
+		}
+
+		opts.MaxAliasExpansion = 150
+		if _, err := DecodeYamlWithOptions(data, opts); err != nil {
+			t.Errorf("mode %d: DecodeYamlWithOptions() error = %v", mode, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package tyaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/mstgnz/transformer"
+	"github.com/mstgnz/transformer/node"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	transformer.Register(transformer.FormatYaml, Codec{})
+	transformer.RegisterAlias("yml", transformer.FormatYaml)
+}
+
+// Codec implements transformer.Codec for YAML.
+// The zero value uses the defaults of DecodeYaml and NodeToYaml.
+type Codec struct {
+	DecodeOptions YamlDecodeOptions
+	EncodeOptions YamlEncodeOptions
+}
+
+var _ transformer.Codec = Codec{}
+
+// Decode reads YAML from r and decodes it into a Node
+func (c Codec) Decode(r io.Reader) (*node.Node, error) {
+	return DecodeYamlReaderWithOptions(r, c.DecodeOptions)
+}
+
+// Encode writes the YAML form of n to w
+func (c Codec) Encode(w io.Writer, n *node.Node) error {
+	return EncodeToWithOptions(w, n, c.EncodeOptions)
+}
+
+// Detect reports whether data is valid YAML
+func (Codec) Detect(data []byte) bool {
+	return IsYaml(data)
+}
+
+// Sniff rates how likely data is YAML.
+// Documents with a directive or document marker score highest. JSON is
+// valid YAML too, so it scores below the JSON codec, and a lone plain
+// scalar barely counts since almost any text parses as one.
+func (Codec) Sniff(data []byte) float64 {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] == '<' {
+		return 0
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return 0
+	}
+
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("%YAML")), bytes.HasPrefix(trimmed, []byte("---")):
+		return 0.95
+	case json.Valid(data):
+		return 0.5
+	case len(doc.Content) == 0:
+		return 0
+	}
+
+	switch doc.Content[0].Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		return 0.8
+	}
+	return 0.1
+}
@@ -0,0 +1,48 @@
+package tyaml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	var codec Codec
+	n, err := codec.Decode(strings.NewReader("b: 1\na:\n    - x\n"))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, n); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if want := "b: 1\na:\n- x\n"; buf.String() != want {
+		t.Errorf("Encode() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCodec_Sniff(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want float64
+	}{
+		{name: "document marker", data: "---\na: 1\n", want: 0.95},
+		{name: "directive", data: "%YAML 1.1\n---\na: 1\n", want: 0.95},
+		{name: "mapping", data: "a: 1\nb: 2\n", want: 0.8},
+		{name: "sequence", data: "- a\n- b\n", want: 0.8},
+		{name: "json", data: `{"a": 1}`, want: 0.5},
+		{name: "plain scalar", data: "hello world", want: 0.1},
+		{name: "xml", data: "<a>1</a>", want: 0},
+		{name: "invalid", data: "a: : b", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Codec{}).Sniff([]byte(tt.data)); got != tt.want {
+				t.Errorf("Sniff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
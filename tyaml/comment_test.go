@@ -0,0 +1,99 @@
+package tyaml
+
+import (
+	"testing"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+const valuesYaml = `# Default values for web
+
+# Image settings
+image:
+    # repository to pull from
+    repository: nginx # official image
+    tag: "1.25"
+    # pinned for now
+ports: # exposed ports
+    # http
+    - 80 # plain
+    - 443
+replicas: 2
+# end of values
+`
+
+func TestDecodeYaml_Comments(t *testing.T) {
+	n, err := DecodeYaml([]byte(valuesYaml))
+	if err != nil {
+		t.Fatalf("DecodeYaml() error = %v", err)
+	}
+
+	if n.Comments.Head != "# Default values for web" {
+		t.Errorf("document head comment = %q", n.Comments.Head)
+	}
+
+	image := n.GetNodeByPath("root.image")
+	if image == nil {
+		t.Fatal("image not found")
+	}
+	if image.Comments.Head != "# Image settings" {
+		t.Errorf("image head comment = %q", image.Comments.Head)
+	}
+
+	repository := n.GetNodeByPath("root.image.repository")
+	if repository == nil {
+		t.Fatal("image.repository not found")
+	}
+	if repository.Comments.Head != "# repository to pull from" {
+		t.Errorf("repository head comment = %q", repository.Comments.Head)
+	}
+	if repository.Value.Comments.Line != "# official image" {
+		t.Errorf("repository line comment = %q", repository.Value.Comments.Line)
+	}
+
+	port, err := n.GetByPointer("/ports/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (node.Comments{Head: "# http", Line: "# plain"}); port.Comments != want {
+		t.Errorf("ports[0] comments = %+v, want %+v", port.Comments, want)
+	}
+}
+
+func TestNodeToYaml_Comments(t *testing.T) {
+	n, err := DecodeYaml([]byte(valuesYaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing a value keeps the comments around it
+	if err := n.Set("image.repository", "nginx-unprivileged"); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Set("replicas", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NodeToYaml(n)
+	if err != nil {
+		t.Fatalf("NodeToYaml() error = %v", err)
+	}
+	want := `# Default values for web
+
+# Image settings
+image:
+    # repository to pull from
+    repository: nginx-unprivileged # official image
+    tag: "1.25"
+    # pinned for now
+ports: # exposed ports
+# http
+- 80 # plain
+- 443
+replicas: 3
+# end of values
+`
+	if got != want {
+		t.Errorf("NodeToYaml() = %q, want %q", got, want)
+	}
+}
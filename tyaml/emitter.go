@@ -0,0 +1,653 @@
+package tyaml
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mstgnz/transformer/node"
+	"gopkg.in/yaml.v3"
+)
+
+// emitter writes Node trees as YAML text. It walks the tree itself rather
+// than going through yaml.Marshal so that indentation, flow style, quoting
+// and folding follow YamlEncodeOptions.
+type emitter struct {
+	w       *bufio.Writer
+	opts    YamlEncodeOptions
+	indent  int
+	width   int
+	flows   [][]string      // parsed FlowPaths
+	path    []string        // keys and indexes leading to the value being written
+	anchors map[string]bool // anchors written so far
+	column  int             // characters written on the current line
+	inline  bool            // a "-" waits for the collection continuing its line
+}
+
+// newEmitter checks the options and prepares an emitter writing to w
+func newEmitter(w io.Writer, opts YamlEncodeOptions) (*emitter, error) {
+	e := &emitter{w: bufio.NewWriter(w), opts: opts, indent: opts.Indent, width: opts.LineWidth}
+	if e.indent == 0 {
+		e.indent = 4
+	}
+	if e.indent < 2 || e.indent > 9 {
+		return nil, fmt.Errorf("yaml: indent %d is out of range 2-9", opts.Indent)
+	}
+	if e.width == 0 {
+		e.width = 80
+	}
+	for _, path := range opts.FlowPaths {
+		tokens, err := node.ParsePath(path)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: flow path: %v", err)
+		}
+		e.flows = append(e.flows, tokens)
+	}
+	return e, nil
+}
+
+// document writes a root Node as a YAML document
+func (e *emitter) document(n *node.Node, start bool) error {
+	e.anchors = nil
+	if start {
+		e.write("---")
+		e.newline()
+	}
+	if n.Comments.Head != "" {
+		// The blank line keeps the comment on the document rather than
+		// on its first entry when the output is read back
+		e.comment(0, n.Comments.Head)
+		e.newline()
+	}
+
+	v := n.Value
+	if v == nil {
+		v = &node.Value{Type: node.TypeNull}
+	}
+	e.comment(0, v.Comments.Head)
+	e.entry(v, 0, 0, e.indent, v.Comments.Line, false)
+	e.comment(0, v.Comments.Foot)
+
+	if n.Comments.Foot != "" {
+		e.newline()
+		e.comment(0, n.Comments.Foot)
+	}
+	return e.w.Flush()
+}
+
+// entry writes a value after its key or dash, or at the start of the
+// document: scalars, aliases and flow collections continue the line while
+// block collections start below it, indented to mapIndent when they are
+// mappings and to seqIndent when they are sequences. Folded and literal
+// strings continue at textIndent. A block collection after a dash starts
+// on the line of the dash when nothing else is written there.
+func (e *emitter) entry(v *node.Value, mapIndent, seqIndent, textIndent int, line string, dash bool) {
+	if v.Alias != "" && e.anchors[v.Alias] {
+		e.space()
+		e.write("*" + v.Alias)
+		e.lineComment(line)
+		e.newline()
+		return
+	}
+
+	if e.isBlock(v) {
+		props := e.props(v)
+		if props != "" {
+			e.space()
+			e.write(props)
+		}
+		e.lineComment(line)
+		switch {
+		case dash && props == "" && line == "":
+			e.inline = true
+		case e.column > 0:
+			e.newline()
+		}
+		if v.Type == node.TypeObject {
+			e.mapping(v, mapIndent)
+		} else {
+			e.sequence(v, seqIndent)
+		}
+		return
+	}
+
+	e.space()
+	if e.isFlow(v) {
+		e.write(e.flowContent(v))
+		e.lineComment(line)
+		e.newline()
+		return
+	}
+	if props := e.props(v); props != "" {
+		e.write(props + " ")
+	}
+	switch {
+	case e.isLiteral(v):
+		e.literal(v.Worth, textIndent, line)
+		return
+	default:
+		text, fold := e.scalar(v, false)
+		if fold {
+			e.fold(text, textIndent)
+		} else {
+			e.write(text)
+		}
+	}
+	e.lineComment(line)
+	e.newline()
+}
+
+// mapping writes the members of an object as a block mapping
+func (e *emitter) mapping(v *node.Value, indent int) {
+	for member := v.Node; member != nil; member = member.Next {
+		value := member.Value
+		if value == nil {
+			continue
+		}
+		e.path = append(e.path, member.Key)
+
+		e.comment(indent, member.Comments.Head)
+		e.comment(indent, value.Comments.Head)
+		e.startLine(indent)
+		e.write(e.key(member.Key, value, false) + ":")
+
+		seqIndent := indent
+		if e.opts.IndentSequences {
+			seqIndent += e.indent
+		}
+		e.entry(value, indent+e.indent, seqIndent, indent+e.indent, joinComments(member.Comments.Line, value.Comments.Line), false)
+		e.comment(indent, value.Comments.Foot)
+		e.comment(indent, member.Comments.Foot)
+
+		e.path = e.path[:len(e.path)-1]
+	}
+}
+
+// sequence writes the items of an array as a block sequence
+func (e *emitter) sequence(v *node.Value, indent int) {
+	for i, item := range v.Array {
+		if item == nil {
+			continue
+		}
+		// Object items are stored behind a wrapper node
+		if item.Node != nil {
+			if item.Node.Value == nil {
+				continue
+			}
+			item = item.Node.Value
+		}
+		e.path = append(e.path, strconv.Itoa(i))
+
+		e.comment(indent, item.Comments.Head)
+		e.startLine(indent)
+		e.write("-")
+		e.entry(item, indent+2, indent+2, indent+2, item.Comments.Line, true)
+		e.comment(indent, item.Comments.Foot)
+
+		e.path = e.path[:len(e.path)-1]
+	}
+}
+
+// flowContent returns a value written in flow style on a single line
+func (e *emitter) flowContent(v *node.Value) string {
+	if v.Alias != "" && e.anchors[v.Alias] {
+		return "*" + v.Alias
+	}
+
+	var b strings.Builder
+	if props := e.props(v); props != "" {
+		b.WriteString(props + " ")
+	}
+	switch v.Type {
+	case node.TypeObject:
+		b.WriteString("{")
+		first := true
+		for member := v.Node; member != nil; member = member.Next {
+			if member.Value == nil {
+				continue
+			}
+			if !first {
+				b.WriteString(", ")
+			}
+			first = false
+			b.WriteString(e.key(member.Key, member.Value, true) + ": " + e.flowContent(member.Value))
+		}
+		b.WriteString("}")
+
+	case node.TypeArray:
+		b.WriteString("[")
+		first := true
+		for _, item := range v.Array {
+			if item == nil || item.Elem() == nil {
+				continue
+			}
+			if !first {
+				b.WriteString(", ")
+			}
+			first = false
+			b.WriteString(e.flowContent(item.Elem()))
+		}
+		b.WriteString("]")
+
+	default:
+		text, _ := e.scalar(v, true)
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// props returns the anchor and tag written before a value, recording the
+// anchor for the aliases that follow
+func (e *emitter) props(v *node.Value) string {
+	var props []string
+	if v.Anchor != "" {
+		props = append(props, "&"+v.Anchor)
+		if e.anchors == nil {
+			e.anchors = make(map[string]bool)
+		}
+		e.anchors[v.Anchor] = true
+	}
+	if v.Tag != "" {
+		if strings.HasPrefix(v.Tag, "!") {
+			props = append(props, v.Tag)
+		} else {
+			props = append(props, "!<"+v.Tag+">")
+		}
+	}
+	return strings.Join(props, " ")
+}
+
+// isBlock reports whether a value is written as a block collection
+func (e *emitter) isBlock(v *node.Value) bool {
+	switch v.Type {
+	case node.TypeObject:
+		if v.Node == nil {
+			return false
+		}
+	case node.TypeArray:
+		if len(v.Array) == 0 {
+			return false
+		}
+	default:
+		return false
+	}
+	return !e.isFlow(v)
+}
+
+// isFlow reports whether a collection at the current path is written in
+// flow style. Empty collections always are.
+func (e *emitter) isFlow(v *node.Value) bool {
+	switch v.Type {
+	case node.TypeObject:
+		if v.Node == nil {
+			return true
+		}
+	case node.TypeArray:
+		if len(v.Array) == 0 {
+			return true
+		}
+	default:
+		return false
+	}
+	if e.opts.FlowLevel > 0 && len(e.path) >= e.opts.FlowLevel {
+		return true
+	}
+	for _, tokens := range e.flows {
+		if matchPath(tokens, e.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath reports whether path matches the tokens of a flow path,
+// in which "*" matches any key or index
+func matchPath(tokens, path []string) bool {
+	if len(tokens) != len(path) {
+		return false
+	}
+	for i, token := range tokens {
+		if token != "*" && token != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// key returns a mapping key, quoted when it is not a valid plain scalar or
+// would be read back as something else than a string. A merge key whose
+// value is a preserved merge is written plain so it is read back as one.
+func (e *emitter) key(key string, v *node.Value, flow bool) string {
+	if key == "<<" && isMergeValue(v) {
+		return key
+	}
+	if len(key) <= 1024 && isPlain(key, flow) {
+		return key
+	}
+	return doubleQuote(key)
+}
+
+// scalar returns the text of a scalar value and whether it may be folded
+func (e *emitter) scalar(v *node.Value, flow bool) (string, bool) {
+	switch v.Type {
+	case node.TypeNull:
+		return "null", false
+
+	case node.TypeBoolean:
+		if b, err := v.Bool(); err == nil {
+			return strconv.FormatBool(b), false
+		}
+
+	case node.TypeNumber:
+		// Number literals are written as they were read, keeping precision
+		if node.IsNumberLiteral(v.Worth) {
+			return v.Worth, false
+		}
+		if f, err := v.Float64(); err == nil {
+			switch {
+			case math.IsNaN(f):
+				return ".nan", false
+			case math.IsInf(f, 1):
+				return ".inf", false
+			case math.IsInf(f, -1):
+				return "-.inf", false
+			}
+		}
+
+	case node.TypeTimestamp:
+		if v.Tag != "" || resolve(v.Worth) == "!!timestamp" {
+			return v.Worth, false
+		}
+		return "!!timestamp " + doubleQuote(v.Worth), false
+
+	case node.TypeBinary:
+		if v.Tag != "" {
+			return v.Worth, false
+		}
+		return "!!binary " + v.Worth, false
+
+	case node.TypeObject, node.TypeArray:
+		// Only empty collections and flow content get here
+		return e.flowContent(v), false
+	}
+
+	if !utf8.ValidString(v.Worth) {
+		// YAML text is UTF-8, other bytes can only be kept as binary data
+		return "!!binary " + base64.StdEncoding.EncodeToString([]byte(v.Worth)), false
+	}
+	return e.str(v.Worth, flow), true
+}
+
+// str returns a string value quoted according to the quoting policy
+func (e *emitter) str(s string, flow bool) string {
+	switch e.opts.Quote {
+	case QuoteSingle:
+		if isPrintable(s, false) {
+			return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+		}
+	case QuoteDouble:
+	default:
+		if isPlain(s, flow) {
+			return s
+		}
+	}
+	return doubleQuote(s)
+}
+
+// isLiteral reports whether a string is written as a literal block scalar,
+// which keeps multi-line text readable
+func (e *emitter) isLiteral(v *node.Value) bool {
+	if v.Type != node.TypeString || e.opts.Quote != QuoteMinimal || !strings.Contains(v.Worth, "\n") {
+		return false
+	}
+	// The indentation of the first line tells where the content starts, so
+	// it may not be indented itself
+	body := strings.TrimRight(v.Worth, "\n")
+	if body == "" || body[0] == ' ' || body[0] == '\t' || body[0] == '\n' {
+		return false
+	}
+	return isPrintable(v.Worth, true)
+}
+
+// literal writes a multi-line string as a literal block scalar, choosing
+// the chomping indicator that restores its trailing line breaks
+func (e *emitter) literal(s string, indent int, line string) {
+	body := strings.TrimRight(s, "\n")
+	switch len(s) - len(body) {
+	case 0:
+		e.write("|-")
+	case 1:
+		e.write("|")
+	default:
+		e.write("|+")
+	}
+	e.lineComment(line)
+	e.newline()
+
+	for _, text := range strings.Split(body, "\n") {
+		if text != "" {
+			e.write(strings.Repeat(" ", indent) + text)
+		}
+		e.newline()
+	}
+	for i := 1; i < len(s)-len(body); i++ {
+		e.newline()
+	}
+}
+
+// fold writes a plain or quoted scalar, breaking it at single spaces
+// between non-blank characters so that lines stay within the line width
+// where possible. Continuation lines are indented and read back with the
+// break turned into a space.
+func (e *emitter) fold(s string, indent int) {
+	for e.width > 0 && e.column+utf8.RuneCountInString(s) > e.width {
+		at, column := -1, e.column
+		for k := 1; k < len(s)-1; k++ {
+			if s[k]&0xC0 != 0x80 {
+				column++
+			}
+			if s[k] != ' ' || isBlank(s[k-1]) || isBlank(s[k+1]) || strings.IndexByte(indicators, s[k+1]) >= 0 {
+				continue
+			}
+			if at >= 0 && column > e.width {
+				break
+			}
+			at = k
+		}
+		if at < 0 {
+			break
+		}
+		e.write(s[:at])
+		e.newline()
+		e.write(strings.Repeat(" ", indent))
+		s = s[at+1:]
+	}
+	e.write(s)
+}
+
+// isBlank reports whether c is a space or a tab, which a line break next
+// to it would drop
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// startLine indents a new line, unless a dash is waiting for its content
+func (e *emitter) startLine(indent int) {
+	if e.inline {
+		e.inline = false
+		e.write(" ")
+		return
+	}
+	e.write(strings.Repeat(" ", indent))
+}
+
+// comment writes comment lines at the given indentation
+func (e *emitter) comment(indent int, text string) {
+	if text == "" {
+		return
+	}
+	if e.inline {
+		// The content of the dash moves to the lines after the comment
+		e.inline = false
+		e.newline()
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if line != "" {
+			e.write(strings.Repeat(" ", indent) + line)
+		}
+		e.newline()
+	}
+}
+
+// lineComment ends the current line with a comment
+func (e *emitter) lineComment(text string) {
+	if text != "" {
+		e.space()
+		e.write(text)
+	}
+}
+
+// space separates what follows from the text already on the line
+func (e *emitter) space() {
+	if e.column > 0 {
+		e.write(" ")
+	}
+}
+
+func (e *emitter) write(s string) {
+	e.w.WriteString(s)
+	e.column += utf8.RuneCountInString(s)
+}
+
+func (e *emitter) newline() {
+	e.w.WriteByte('\n')
+	e.column = 0
+}
+
+// joinComments joins the line comments of a key and of its value
+func joinComments(key, value string) string {
+	if key == "" || value == "" {
+		return key + value
+	}
+	return key + " " + value
+}
+
+// indicators are the characters with a meaning at the start of a plain scalar
+const indicators = "-?:,[]{}#&*!|>'\"%@`"
+
+// isPlain reports whether s can be written as a plain scalar and read back
+// as the same string. Plain text that would resolve to another type, such
+// as "true", "1.5" or "null", or to a YAML 1.1 boolean like "yes", is not.
+// Inside flow collections text holding ?, a comma or a bracket is quoted.
+func isPlain(s string, flow bool) bool {
+	if s == "" || !isPrintable(s, false) {
+		return false
+	}
+	first, last := s[0], s[len(s)-1]
+	switch {
+	case first == ' ' || first == '\t' || last == ' ' || last == '\t' || last == ':':
+		return false
+	case strings.HasPrefix(s, "---") || strings.HasPrefix(s, "..."):
+		return false
+	case s == "<<", flow && (first == '?' || first == ':'):
+		return false
+	case strings.IndexByte("-?:", first) >= 0:
+		if len(s) == 1 || s[1] == ' ' || s[1] == '\t' || (flow && strings.IndexByte(",[]{}", s[1]) >= 0) {
+			return false
+		}
+	case strings.IndexByte(indicators, first) >= 0:
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, ":\t") || strings.Contains(s, " #") || strings.Contains(s, "\t#") {
+		return false
+	}
+	if flow && strings.ContainsAny(s, "?,[]{}") {
+		return false
+	}
+	switch s {
+	case "yes", "Yes", "YES", "no", "No", "NO", "on", "On", "ON", "off", "Off", "OFF":
+		return false
+	}
+	return resolve(s) == "!!str"
+}
+
+// resolve returns the tag a plain scalar resolves to
+func resolve(s string) string {
+	return (&yaml.Node{Kind: yaml.ScalarNode, Value: s}).ShortTag()
+}
+
+// isPrintable reports whether s only holds characters YAML can write
+// without escaping; newlines are allowed when the newlines flag is set,
+// other line breaks never are
+func isPrintable(s string, newlines bool) bool {
+	for i, r := range s {
+		switch {
+		case r == utf8.RuneError:
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				return false
+			}
+		case r == '\n':
+			if !newlines {
+				return false
+			}
+		case r == '\t', r >= 0x20 && r <= 0x7E:
+		case r >= 0xA0 && r <= 0xD7FF && r != 0x2028 && r != 0x2029, r >= 0xE000 && r <= 0xFFFD && r != 0xFEFF:
+		case r >= 0x10000 && r <= 0x10FFFF:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// doubleQuote returns s as a double-quoted scalar, escaping the characters
+// that cannot be written as they are
+func doubleQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case 0:
+			b.WriteString(`\0`)
+		case 0x85:
+			b.WriteString(`\N`)
+		case 0x2028:
+			b.WriteString(`\L`)
+		case 0x2029:
+			b.WriteString(`\P`)
+		default:
+			switch {
+			case r == utf8.RuneError && size == 1:
+				// An invalid UTF-8 byte
+				fmt.Fprintf(&b, `\x%02X`, s[i])
+			case isPrintable(string(r), false):
+				b.WriteRune(r)
+			case r <= 0xFF:
+				fmt.Fprintf(&b, `\x%02X`, r)
+			case r <= 0xFFFF:
+				fmt.Fprintf(&b, `\u%04X`, r)
+			default:
+				fmt.Fprintf(&b, `\U%08X`, r)
+			}
+		}
+		i += size
+	}
+	b.WriteByte('"')
+	return b.String()
+}
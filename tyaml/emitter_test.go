@@ -0,0 +1,248 @@
+package tyaml
+
+import (
+	"testing"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+const deploymentYaml = `metadata:
+    name: web
+spec:
+    ports:
+        - name: http
+          port: 80
+    args: [--verbose]
+    note: a rather long note about the deployment that does not fit on one line
+`
+
+func TestNodeToYamlWithOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts YamlEncodeOptions
+		want string
+	}{
+		{
+			name: "defaults",
+			want: `metadata:
+    name: web
+spec:
+    ports:
+    - name: http
+      port: 80
+    args:
+    - --verbose
+    note: a rather long note about the deployment that does not fit on one line
+`,
+		},
+		{
+			name: "two spaces and indented sequences",
+			opts: YamlEncodeOptions{Indent: 2, IndentSequences: true, DocumentStart: true},
+			want: `---
+metadata:
+  name: web
+spec:
+  ports:
+    - name: http
+      port: 80
+  args:
+    - --verbose
+  note: a rather long note about the deployment that does not fit on one line
+`,
+		},
+		{
+			name: "flow level",
+			opts: YamlEncodeOptions{FlowLevel: 2},
+			want: `metadata:
+    name: web
+spec:
+    ports: [{name: http, port: 80}]
+    args: [--verbose]
+    note: a rather long note about the deployment that does not fit on one line
+`,
+		},
+		{
+			name: "flow paths",
+			opts: YamlEncodeOptions{FlowPaths: []string{"metadata", "spec.ports.*"}},
+			want: `metadata: {name: web}
+spec:
+    ports:
+    - {name: http, port: 80}
+    args:
+    - --verbose
+    note: a rather long note about the deployment that does not fit on one line
+`,
+		},
+		{
+			name: "single quotes and line width",
+			opts: YamlEncodeOptions{Quote: QuoteSingle, LineWidth: 40, FlowLevel: 1},
+			want: `metadata: {name: 'web'}
+spec: {ports: [{name: 'http', port: 80}], args: ['--verbose'], note: 'a rather long note about the deployment that does not fit on one line'}
+`,
+		},
+		{
+			name: "double quotes and folding",
+			opts: YamlEncodeOptions{Quote: QuoteDouble, LineWidth: 40},
+			want: `metadata:
+    name: "web"
+spec:
+    ports:
+    - name: "http"
+      port: 80
+    args:
+    - "--verbose"
+    note: "a rather long note about the
+        deployment that does not fit on
+        one line"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := DecodeYaml([]byte(deploymentYaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := NodeToYamlWithOptions(n, tt.opts)
+			if err != nil {
+				t.Fatalf("NodeToYamlWithOptions() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("NodeToYamlWithOptions() = %q, want %q", got, tt.want)
+			}
+
+			back, err := DecodeYaml([]byte(got))
+			if err != nil {
+				t.Fatalf("DecodeYaml() of the output error = %v", err)
+			}
+			if !back.Value.Equal(n.Value) {
+				t.Errorf("output does not decode back to the same tree")
+			}
+		})
+	}
+}
+
+func TestNodeToYaml_Strings(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain text", "plain text"},
+		{"", `""`},
+		{"true", `"true"`},
+		{"yes", `"yes"`},
+		{"1.5", `"1.5"`},
+		{"null", `"null"`},
+		{"- item", `"- item"`},
+		{"a: b", `"a: b"`},
+		{"a #b", `"a #b"`},
+		{"#a", `"#a"`},
+		{" padded", `" padded"`},
+		{"<<", `"<<"`},
+		{"tab\x01", `"tab\x01"`},
+		{"line1\nline2", "|-\n    line1\n    line2"},
+		{"line1\nline2\n", "|\n    line1\n    line2"},
+		{" indented\nline2", `" indented\nline2"`},
+		{"\xff", "!!binary /w=="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			root := node.NewNode("root")
+			if err := root.Set("v", tt.value); err != nil {
+				t.Fatal(err)
+			}
+			got, err := NodeToYaml(root)
+			if err != nil {
+				t.Fatalf("NodeToYaml() error = %v", err)
+			}
+			if want := "v: " + tt.want + "\n"; got != want {
+				t.Errorf("NodeToYaml() = %q, want %q", got, want)
+			}
+
+			back, err := DecodeYaml([]byte(got))
+			if err != nil {
+				t.Fatalf("DecodeYaml() error = %v", err)
+			}
+			if v, _ := back.GetByPath("v"); v.Type == node.TypeString && v.Worth != tt.value {
+				t.Errorf("read back %q, want %q", v.Worth, tt.value)
+			}
+		})
+	}
+}
+
+func TestNodeToYamlWithOptions_FlowStrings(t *testing.T) {
+	values := []string{
+		"plain", "x?y", "http://x?a=1", `\?`, "=?", "?", "?x", ":x", "a:b", "a: b", "x:",
+		"a,b", "[x", "x]", "{x", "x}", "#a", "a #b", "-x", "- x", "-", "'", `"`, "a'b",
+		"yes", "", " x", "x ", "<<", "&a", "*a", "!a", "|", ">", "%x", "@x", "`x", "a\tb",
+	}
+
+	for _, quote := range []QuoteStyle{QuoteMinimal, QuoteSingle, QuoteDouble} {
+		for _, value := range values {
+			root := node.NewNode("root")
+			if err := root.Set("list", []string{value, "after"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := root.Set("map", map[string]string{value: value}); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := NodeToYamlWithOptions(root, YamlEncodeOptions{FlowLevel: 1, Quote: quote})
+			if err != nil {
+				t.Fatalf("NodeToYamlWithOptions(%q) error = %v", value, err)
+			}
+			// Every emitted flow document must read back as it was written
+			back, err := DecodeYaml([]byte(got))
+			if err != nil {
+				t.Errorf("DecodeYaml(%q) error = %v", got, err)
+				continue
+			}
+			if !back.Value.Equal(root.Value) {
+				t.Errorf("%q with quote style %d read back differently from %q", value, quote, got)
+			}
+		}
+	}
+}
+
+func TestNodeToYamlWithOptions_FoldBlanks(t *testing.T) {
+	values := []string{
+		", \t", "a \tb", "a\t b", "one two \tthree four", "tab\t tab\t tab", "x  y z", "word word\t",
+	}
+
+	for _, quote := range []QuoteStyle{QuoteMinimal, QuoteSingle, QuoteDouble} {
+		for width := 2; width <= 12; width++ {
+			for _, value := range values {
+				root := node.NewNode("root")
+				if err := root.Set("v", value); err != nil {
+					t.Fatal(err)
+				}
+				got, err := NodeToYamlWithOptions(root, YamlEncodeOptions{Quote: quote, LineWidth: width})
+				if err != nil {
+					t.Fatalf("NodeToYamlWithOptions(%q) error = %v", value, err)
+				}
+				back, err := DecodeYaml([]byte(got))
+				if err != nil {
+					t.Fatalf("DecodeYaml(%q) error = %v", got, err)
+				}
+				// A break next to a blank would lose it on the way back
+				if v, _ := back.GetByPath("v"); v.Worth != value {
+					t.Errorf("%q folded at %d as %q reads back as %q", value, width, got, v.Worth)
+				}
+			}
+		}
+	}
+}
+
+func TestNodeToYamlWithOptions_Invalid(t *testing.T) {
+	root := node.NewNode("root")
+	if err := root.Set("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []YamlEncodeOptions{{Indent: 1}, {Indent: 10}, {FlowPaths: []string{"a..b"}}} {
+		if _, err := NodeToYamlWithOptions(root, opts); err == nil {
+			t.Errorf("NodeToYamlWithOptions(%+v) expected error", opts)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package tyaml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mstgnz/transformer/node"
+	"gopkg.in/yaml.v3"
+)
+
+// Decoder reads the documents of a YAML stream one at a time, so that
+// large multi-document files are never held in memory as a whole
+type Decoder struct {
+	dec  *yaml.Decoder
+	opts YamlDecodeOptions
+}
+
+// NewDecoder returns a Decoder reading a YAML stream from r
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWithOptions(r, YamlDecodeOptions{})
+}
+
+// NewDecoderWithOptions returns a Decoder reading a YAML stream from r
+// that decodes every document using the given options
+func NewDecoderWithOptions(r io.Reader, opts YamlDecodeOptions) *Decoder {
+	return &Decoder{dec: yaml.NewDecoder(r), opts: opts}
+}
+
+// Next decodes the next document of the stream into a Node keyed "root".
+// Empty documents, such as the one left by a trailing "---", are skipped.
+// It returns io.EOF when the stream holds no more documents.
+func (d *Decoder) Next() (*node.Node, error) {
+	for {
+		var doc yaml.Node
+		if err := d.dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+		if isEmptyDocument(&doc) {
+			continue
+		}
+		// Anchors are scoped to their document
+		return yamlToNode(&decoder{opts: d.opts}, "root", &doc)
+	}
+}
+
+// DecodeYamlAll decodes every document of a YAML stream, as separated by
+// "---", into a Node keyed "root"
+func DecodeYamlAll(data []byte) ([]*node.Node, error) {
+	return DecodeYamlAllReader(bytes.NewReader(data))
+}
+
+// DecodeYamlAllReader decodes every document of a YAML stream read from r
+func DecodeYamlAllReader(r io.Reader) ([]*node.Node, error) {
+	var docs []*node.Node
+	dec := NewDecoder(r)
+	for {
+		n, err := dec.Next()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", len(docs)+1, err)
+		}
+		docs = append(docs, n)
+	}
+}
+
+// isEmptyDocument reports whether a document holds no content at all,
+// which the YAML decoder reports as an untagged empty null scalar
+func isEmptyDocument(doc *yaml.Node) bool {
+	if len(doc.Content) == 0 {
+		return true
+	}
+	y := doc.Content[0]
+	return y.Kind == yaml.ScalarNode && y.Value == "" && y.Style == 0 && y.ShortTag() == "!!null"
+}
+
+// NodeToYamlAll writes Nodes as a YAML stream of documents separated by "---"
+func NodeToYamlAll(nodes []*node.Node) (string, error) {
+	return NodeToYamlAllWithOptions(nodes, YamlEncodeOptions{})
+}
+
+// NodeToYamlAllWithOptions writes Nodes as a YAML stream using the given options
+func NodeToYamlAllWithOptions(nodes []*node.Node, opts YamlEncodeOptions) (string, error) {
+	var b strings.Builder
+	if err := EncodeAllToWithOptions(&b, nodes, opts); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// EncodeAllTo writes Nodes to w as a YAML stream of documents separated by "---"
+func EncodeAllTo(w io.Writer, nodes []*node.Node) error {
+	return EncodeAllToWithOptions(w, nodes, YamlEncodeOptions{})
+}
+
+// EncodeAllToWithOptions writes Nodes to w as a YAML stream using the given options
+func EncodeAllToWithOptions(w io.Writer, nodes []*node.Node, opts YamlEncodeOptions) error {
+	e, err := newEmitter(w, opts)
+	if err != nil {
+		return err
+	}
+	for i, n := range nodes {
+		if n == nil {
+			return fmt.Errorf("document %d: node is nil", i+1)
+		}
+		if err := e.document(n, i > 0 || opts.DocumentStart); err != nil {
+			return fmt.Errorf("document %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
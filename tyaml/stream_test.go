@@ -0,0 +1,96 @@
+package tyaml
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+const manifests = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+# trailing separator leaves an empty document
+---
+`
+
+func TestDecodeYamlAll(t *testing.T) {
+	docs, err := DecodeYamlAll([]byte(manifests))
+	if err != nil {
+		t.Fatalf("DecodeYamlAll() error = %v", err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("DecodeYamlAll() returned %d documents, want 2", len(docs))
+	}
+	for i, kind := range []string{"Service", "Deployment"} {
+		if docs[i].Key != "root" {
+			t.Errorf("document %d key = %q, want root", i, docs[i].Key)
+		}
+		if got, _ := docs[i].GetByPath("kind"); got == nil || got.Worth != kind {
+			t.Errorf("document %d kind = %v, want %s", i, got, kind)
+		}
+	}
+
+	docs, err = DecodeYamlAll([]byte("--- null\n--- 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Errorf("explicit null documents: got %d documents, want 2", len(docs))
+	}
+
+	if _, err := DecodeYamlAll([]byte("a: 1\n---\na: [\n")); err == nil || !strings.Contains(err.Error(), "document 2") {
+		t.Errorf("DecodeYamlAll() error = %v, want error for document 2", err)
+	}
+}
+
+func TestDecoder_Next(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(manifests))
+	var names []string
+	for {
+		doc, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		kind, err := doc.GetByPath("kind")
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, kind.Worth)
+	}
+	if got := strings.Join(names, ","); got != "Service,Deployment" {
+		t.Errorf("kinds = %s, want Service,Deployment", got)
+	}
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("Next() after the end error = %v, want io.EOF", err)
+	}
+}
+
+func TestNodeToYamlAll(t *testing.T) {
+	docs, err := DecodeYamlAll([]byte(manifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NodeToYamlAll(docs)
+	if err != nil {
+		t.Fatalf("NodeToYamlAll() error = %v", err)
+	}
+	want := "apiVersion: v1\nkind: Service\nmetadata:\n    name: web\n---\n" +
+		"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n    name: web\n"
+	if got != want {
+		t.Errorf("NodeToYamlAll() = %q, want %q", got, want)
+	}
+
+	if _, err := NodeToYamlAll(append(docs, nil)); err == nil {
+		t.Error("NodeToYamlAll() expected error for nil node")
+	}
+}
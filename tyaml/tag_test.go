@@ -0,0 +1,121 @@
+package tyaml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mstgnz/transformer/node"
+)
+
+const cloudFormationYaml = `Resources:
+    Bucket:
+        Type: AWS::S3::Bucket
+        Properties:
+            BucketName: !Sub ${AWS::StackName}-data
+            Tags:
+            - Key: Owner
+              Value: !Ref Owner
+Outputs:
+    Arn:
+        Value: !GetAtt Bucket.Arn
+    Zones: !Split
+    - ","
+    - !Ref Csv
+`
+
+func TestDecodeYaml_Tags(t *testing.T) {
+	n, err := DecodeYaml([]byte(cloudFormationYaml))
+	if err != nil {
+		t.Fatalf("DecodeYaml() error = %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		tag   string
+		typ   node.ValueType
+		worth string
+	}{
+		{"Resources.Bucket.Type", "", node.TypeString, "AWS::S3::Bucket"},
+		{"Resources.Bucket.Properties.BucketName", "!Sub", node.TypeString, "${AWS::StackName}-data"},
+		{"Resources.Bucket.Properties.Tags[0].Value", "!Ref", node.TypeString, "Owner"},
+		{"Outputs.Zones", "!Split", node.TypeArray, ""},
+		{"Outputs.Zones[1]", "!Ref", node.TypeString, "Csv"},
+	}
+	for _, tt := range tests {
+		v, err := n.GetByPath(tt.path)
+		if err != nil {
+			t.Fatalf("GetByPath(%s) error = %v", tt.path, err)
+		}
+		if v.Tag != tt.tag || v.Type != tt.typ || v.Worth != tt.worth {
+			t.Errorf("%s = %s %q tagged %q, want %s %q tagged %q", tt.path, v.Type, v.Worth, v.Tag, tt.typ, tt.worth, tt.tag)
+		}
+	}
+
+	got, err := NodeToYaml(n)
+	if err != nil {
+		t.Fatalf("NodeToYaml() error = %v", err)
+	}
+	if got != cloudFormationYaml {
+		t.Errorf("NodeToYaml() = %q, want %q", got, cloudFormationYaml)
+	}
+}
+
+func TestDecodeYaml_TimestampAndBinary(t *testing.T) {
+	data := `created: 2001-12-14 21:59:43.10
+day: 2024-05-01
+quoted: "2024-05-01"
+icon: !!binary |
+    aGVs
+    bG8=
+`
+	n, err := DecodeYaml([]byte(data))
+	if err != nil {
+		t.Fatalf("DecodeYaml() error = %v", err)
+	}
+
+	created, _ := n.GetByPath("created")
+	if created.Type != node.TypeTimestamp || created.Worth != "2001-12-14 21:59:43.10" {
+		t.Errorf("created = %s %q, want timestamp as written", created.Type, created.Worth)
+	}
+	if got, err := created.AsTime(); err != nil || !got.Equal(time.Date(2001, 12, 14, 21, 59, 43, 1e8, time.UTC)) {
+		t.Errorf("created.AsTime() = %v, %v", got, err)
+	}
+	if quoted, _ := n.GetByPath("quoted"); quoted.Type != node.TypeString {
+		t.Errorf("quoted = %s, want string", quoted.Type)
+	}
+	icon, _ := n.GetByPath("icon")
+	if b, err := icon.Bytes(); icon.Type != node.TypeBinary || err != nil || string(b) != "hello" {
+		t.Errorf("icon = %s %q, want binary hello", icon.Type, b)
+	}
+
+	got, err := NodeToYaml(n)
+	if err != nil {
+		t.Fatalf("NodeToYaml() error = %v", err)
+	}
+	want := `created: 2001-12-14 21:59:43.10
+day: 2024-05-01
+quoted: "2024-05-01"
+icon: !!binary aGVsbG8=
+`
+	if got != want {
+		t.Errorf("NodeToYaml() = %q, want %q", got, want)
+	}
+}
+
+func TestNodeToYaml_GoValues(t *testing.T) {
+	root := node.NewNode("root")
+	if err := root.Set("at", time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Set("data", []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NodeToYaml(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "at: 2024-05-01T10:30:00Z\ndata: !!binary aGk=\n"; got != want {
+		t.Errorf("NodeToYaml() = %q, want %q", got, want)
+	}
+}
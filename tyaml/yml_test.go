@@ -1,9 +1,13 @@
 package tyaml
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/mstgnz/transformer/node"
@@ -258,3 +262,192 @@ boolean: true
 		})
 	}
 }
+
+func TestDecodeYamlKeyOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "flat mapping",
+			data: []byte("zeta: 1\nalpha: 2\nmid: 3\n"),
+			want: "zeta: 1\nalpha: 2\nmid: 3\n",
+		},
+		{
+			name: "nested mapping in sequence",
+			data: []byte("list:\n    - b: x\n      a: z\n"),
+			want: "list:\n- b: x\n  a: z\n",
+		},
+		{
+			name: "merge key",
+			data: []byte("base: &b\n    x: 1\n    y: 2\nd:\n    a: 0\n    <<: *b\n    y: 3\n"),
+			want: "base:\n    x: 1\n    y: 2\nd:\n    a: 0\n    x: 1\n    y: 3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := DecodeYaml(tt.data)
+			if err != nil {
+				t.Fatalf("DecodeYaml() error = %v", err)
+			}
+			got, err := NodeToYaml(n)
+			if err != nil {
+				t.Fatalf("NodeToYaml() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("NodeToYaml() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeYamlKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr string
+	}{
+		{name: "sequence key", data: "a: 1\n? [a, b]\n: 2\n", wantErr: "yaml: line 2: mapping key must be a scalar"},
+		{name: "mapping key", data: "? {c: d}\n: 1\n", wantErr: "yaml: line 1: mapping key must be a scalar"},
+		{name: "alias of a mapping", data: "x: &m {c: d}\n*m : 1\n", wantErr: "yaml: line 2: mapping key must be a scalar"},
+		{name: "duplicate key", data: "a: 1\nb: 2\na: 3\n", wantErr: `yaml: line 3: mapping key "a" already defined at line 1`},
+		{name: "alias of a scalar", data: "x: &k name\n*k : 1\n", want: "x: name\nname: 1\n"},
+		{name: "merged keys", data: "b: &b {x: 1}\nd:\n    <<: *b\n    x: 2\n", want: "b:\n    x: 1\nd:\n    x: 2\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := DecodeYaml([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("DecodeYaml() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeYaml() error = %v", err)
+			}
+			got, err := NodeToYaml(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("NodeToYaml() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeYamlNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		worth string
+		kind  node.NumberKind
+	}{
+		{name: "large integer", data: "v: 9007199254740993", worth: "9007199254740993", kind: node.NumberInt},
+		{name: "uint64", data: "v: 18446744073709551615", worth: "18446744073709551615", kind: node.NumberUint},
+		{name: "big integer", data: "v: 123456789012345678901234567890", worth: "123456789012345678901234567890", kind: node.NumberBig},
+		{name: "decimal", data: "v: 0.30000000000000000001", worth: "0.30000000000000000001", kind: node.NumberDecimal},
+		{name: "trailing zero", data: "v: 12.50", worth: "12.50", kind: node.NumberFloat},
+		{name: "hex", data: "v: 0x1F", worth: "31", kind: node.NumberInt},
+		{name: "octal", data: "v: 0o17", worth: "15", kind: node.NumberInt},
+		{name: "underscores", data: "v: 1_000", worth: "1000", kind: node.NumberInt},
+		{name: "plus sign", data: "v: +1.5", worth: "1.5", kind: node.NumberFloat},
+		{name: "bare dot", data: "v: .5", worth: "0.5", kind: node.NumberFloat},
+		{name: "negative bare dot", data: "v: -.5", worth: "-0.5", kind: node.NumberFloat},
+		{name: "trailing dot", data: "v: 1.", worth: "1.0", kind: node.NumberFloat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := DecodeYaml([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("DecodeYaml() error = %v", err)
+			}
+			v, err := n.GetByPath("v")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Type != node.TypeNumber || v.Worth != tt.worth || v.Kind != tt.kind {
+				t.Errorf("DecodeYaml() value = %s %q %v, want number %q %v", v.Type, v.Worth, v.Kind, tt.worth, tt.kind)
+			}
+
+			got, err := NodeToYaml(n)
+			if err != nil {
+				t.Fatalf("NodeToYaml() error = %v", err)
+			}
+			if want := "v: " + tt.worth + "\n"; got != want {
+				t.Errorf("NodeToYaml() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeYamlExcessiveAliasing(t *testing.T) {
+	data := []byte(`a: &a ["x","x","x","x","x","x","x","x","x","x"]
+b: &b [*a,*a,*a,*a,*a,*a,*a,*a,*a,*a]
+c: &c [*b,*b,*b,*b,*b,*b,*b,*b,*b,*b]
+d: &d [*c,*c,*c,*c,*c,*c,*c,*c,*c,*c]
+e: &e [*d,*d,*d,*d,*d,*d,*d,*d,*d,*d]
+f: &f [*e,*e,*e,*e,*e,*e,*e,*e,*e,*e]
+g: &g [*f,*f,*f,*f,*f,*f,*f,*f,*f,*f]
+`)
+	if _, err := DecodeYaml(data); err == nil {
+		t.Error("DecodeYaml() expected excessive aliasing error")
+	}
+}
+
+func TestDecodeYamlReader(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("name: John\n"))
+		pw.Write([]byte("age: 30\n"))
+		pw.Close()
+	}()
+
+	n, err := DecodeYamlReader(pr)
+	if err != nil {
+		t.Fatalf("DecodeYamlReader() error = %v", err)
+	}
+	if n.Value.Node == nil || n.Value.Node.Next == nil || n.Value.Node.Next.Key != "age" {
+		t.Errorf("DecodeYamlReader() did not decode all keys")
+	}
+
+	empty, err := DecodeYamlReader(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("DecodeYamlReader() error = %v for empty input", err)
+	}
+	if empty.Type() != node.TypeNull {
+		t.Errorf("DecodeYamlReader() type = %v for empty input, want null", empty.Type())
+	}
+}
+
+func TestEncodeTo(t *testing.T) {
+	n, err := DecodeYaml([]byte("name: John\narray:\n  - a\n  - b\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeTo(&buf, n); err != nil {
+		t.Fatalf("EncodeTo() error = %v", err)
+	}
+	if want := "name: John\narray:\n- a\n- b\n"; buf.String() != want {
+		t.Errorf("EncodeTo() = %q, want %q", buf.String(), want)
+	}
+
+	if err := EncodeTo(errWriter{}, n); err == nil {
+		t.Error("EncodeTo() expected error from failing writer")
+	}
+}
+
+// errWriter is an io.Writer that always fails
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}